@@ -31,6 +31,7 @@ import (
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/mautrix-gmessages/pkg/connector"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 )
 
 type Error struct {
@@ -44,14 +45,14 @@ func legacyProvListContacts(w http.ResponseWriter, r *http.Request) {
 	if login == nil {
 		return
 	}
-	if contacts, err := login.Client.(*connector.GMClient).Client.ListContacts(); err != nil {
+	if contacts, err := login.Client.(*connector.GMClient).Client.ListAllContacts(r.Context()); err != nil {
 		hlog.FromRequest(r).Err(err).Msg("Failed to fetch user's contacts")
 		jsonResponse(w, http.StatusInternalServerError, Error{
 			Error:   "Internal server error while fetching contact list",
 			ErrCode: "failed to get contacts",
 		})
 	} else {
-		jsonResponse(w, http.StatusOK, contacts)
+		jsonResponse(w, http.StatusOK, &gmproto.ListContactsResponse{Contacts: contacts})
 	}
 }
 
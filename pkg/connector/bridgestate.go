@@ -18,6 +18,9 @@ package connector
 
 import (
 	"maunium.net/go/mautrix/bridgev2/status"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/events"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 )
 
 const (
@@ -67,38 +70,59 @@ func (gc *GMClient) FillBridgeState(state status.BridgeState) status.BridgeState
 	if state.Info == nil {
 		state.Info = make(map[string]any)
 	}
-	if state.StateEvent == status.StateConnected {
+	if state.StateEvent == status.StateConnected && gc.Client != nil {
+		connStatus := gc.Client.ConnectionStatus()
 		state.Info["sims"] = gc.Meta.GetSIMsForBridgeState()
 		state.Info["settings"] = gc.Meta.Settings
 		state.Info["battery_low"] = gc.batteryLow
 		state.Info["mobile_data"] = gc.mobileData
-		state.Info["browser_active"] = gc.browserInactiveType == ""
+		state.Info["browser_active"] = connStatus.State != events.StateBrowserInactive
 		state.Info["google_account_pairing"] = gc.SwitchedToGoogleLogin
-		if !gc.ready {
-			state.StateEvent = status.StateConnecting
-			state.Error = GMConnecting
-		}
-		if !gc.PhoneResponding {
+		switch connStatus.State {
+		case events.StateConnecting:
+			if connStatus.Error != nil {
+				state.StateEvent = status.StateTransientDisconnect
+				state.Error = GMListenError
+				state.Info["go_error"] = connStatus.Error.Error()
+			} else {
+				state.StateEvent = status.StateConnecting
+				state.Error = GMConnecting
+			}
+		case events.StatePhoneUnreachable:
 			state.StateEvent = status.StateBadCredentials
 			state.Error = GMPhoneNotResponding
 			state.UserAction = status.UserActionOpenNative
-		}
-		if gc.SwitchedToGoogleLogin {
-			state.StateEvent = status.StateBadCredentials
-			state.Error = GMSwitchedToGoogleLogin
-		}
-		if gc.longPollingError != nil {
-			state.StateEvent = status.StateTransientDisconnect
-			state.Error = GMListenError
-			state.Info["go_error"] = gc.longPollingError.Error()
-		}
-		if gc.browserInactiveType != "" {
+		case events.StateBrowserInactive:
 			if gc.Main.Config.AggressiveReconnect {
 				state.StateEvent = status.StateTransientDisconnect
 			} else {
 				state.StateEvent = status.StateBadCredentials
 			}
-			state.Error = gc.browserInactiveType
+			switch connStatus.InactiveAlert {
+			case gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT:
+				state.Error = GMBrowserInactiveTimeout
+			case gmproto.AlertType_BROWSER_INACTIVE_FROM_INACTIVITY:
+				state.Error = GMBrowserInactiveInactivity
+			default:
+				state.Error = GMBrowserInactive
+			}
+		case events.StateLoggedOut:
+			state.StateEvent = status.StateBadCredentials
+			state.Error = GMUnpaired
+		case events.StateDisconnected:
+			if connStatus.Error != nil {
+				state.StateEvent = status.StateUnknownError
+				state.Error = GMFatalError
+				state.Info["go_error"] = connStatus.Error.Error()
+			} else {
+				state.StateEvent = status.StateTransientDisconnect
+				state.Error = GMListenError
+				state.Info["go_error"] = "not connected"
+			}
+		}
+		if gc.SwitchedToGoogleLogin && (state.StateEvent == status.StateConnected || state.Error == GMConnecting || state.Error == GMPhoneNotResponding) {
+			state.StateEvent = status.StateBadCredentials
+			state.Error = GMSwitchedToGoogleLogin
 		}
 	}
 	return state
@@ -22,6 +22,7 @@ import (
 
 	"go.mau.fi/util/ffmpeg"
 	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/database"
 	"maunium.net/go/mautrix/event"
 
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
@@ -42,7 +43,7 @@ var generalCaps = &bridgev2.NetworkGeneralCapabilities{
 			CreateDM:    true,
 			LookupPhone: false, // There's no lookup, you can just DM any phone number
 			AnyPhone:    true,
-			ContactList: false, // we don't support pagination yet
+			ContactList: true,
 		},
 		GroupCreation: map[string]bridgev2.GroupTypeCapabilities{
 			// TODO allow choosing rcs or mms?
@@ -59,7 +60,7 @@ func (gc *GMConnector) GetCapabilities() *bridgev2.NetworkGeneralCapabilities {
 }
 
 func (gc *GMConnector) GetBridgeInfoVersion() (info, caps int) {
-	return 1, 5
+	return 1, 6
 }
 
 // The phone will compress outgoing media on MMS, so we don't need to limit it
@@ -157,6 +158,17 @@ var rcsCaps = &event.RoomFeatures{
 	DeleteChat:          true,
 }
 
+var rcsGroupCaps = func() *event.RoomFeatures {
+	caps := rcsCaps.Clone()
+	caps.ID = capID("rcs_group")
+	// The libgm methods for changing members are experimental, so don't claim full support
+	caps.MemberActions = event.MemberFeatureMap{
+		event.MemberActionInvite: event.CapLevelPartialSupport,
+		event.MemberActionLeave:  event.CapLevelPartialSupport,
+	}
+	return caps
+}()
+
 var smsCaps = &event.RoomFeatures{
 	ID: capID("sms"),
 	File: event.FileFeatureMap{
@@ -198,9 +210,20 @@ var smsCaps = &event.RoomFeatures{
 }
 
 func (gc *GMClient) GetCapabilities(ctx context.Context, portal *bridgev2.Portal) *event.RoomFeatures {
+	var caps *event.RoomFeatures
 	if portal.Metadata.(*PortalMetadata).Type == gmproto.ConversationType_RCS {
-		return rcsCaps
+		if portal.RoomType != database.RoomTypeDM && gc.Main.Config.RCSGroupMembership {
+			caps = rcsGroupCaps
+		} else {
+			caps = rcsCaps
+		}
 	} else {
-		return smsCaps
+		caps = smsCaps
+	}
+	if caps.TypingNotifications && !gc.canSendTyping() {
+		caps = caps.Clone()
+		caps.TypingNotifications = false
+		caps.ID += "+notyping"
 	}
+	return caps
 }
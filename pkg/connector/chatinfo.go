@@ -53,7 +53,7 @@ func (gc *GMClient) GetChatInfo(ctx context.Context, portal *bridgev2.Portal) (*
 		return nil, err
 	}
 	zerolog.Ctx(ctx).Info().Str("conversation_id", conversationID).Msg("Manually fetching chat info")
-	conv, err := gc.Client.GetConversation(conversationID)
+	conv, err := gc.Client.GetConversation(ctx, conversationID)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +69,20 @@ func (gc *GMClient) GetUserInfo(ctx context.Context, ghost *bridgev2.Ghost) (*br
 	return nil, nil
 }
 
+func isArchived(conv *gmproto.Conversation) bool {
+	return conv.GetStatus() == gmproto.ConversationStatus_ARCHIVED || conv.GetStatus() == gmproto.ConversationStatus_KEEP_ARCHIVED
+}
+
+// conversationTag returns the Matrix room tag that represents the folder of the conversation on the phone.
+func conversationTag(conv *gmproto.Conversation) event.RoomTag {
+	if conv.GetPinned() {
+		return event.RoomTagFavourite
+	} else if isArchived(conv) {
+		return event.RoomTagLowPriority
+	}
+	return ""
+}
+
 func (gc *GMClient) wrapChatInfo(ctx context.Context, conv *gmproto.Conversation) (*bridgev2.ChatInfo, error) {
 	log := zerolog.Ctx(ctx)
 	roomType := database.RoomTypeDefault
@@ -140,17 +154,21 @@ func (gc *GMClient) wrapChatInfo(ctx context.Context, conv *gmproto.Conversation
 		members.PowerLevels.Events[event.EventReaction] = 0
 		members.PowerLevels.EventsDefault = ptr.Ptr(0)
 	}
+	// Participants can be added to RCS groups from Matrix, but there's no way to remove other participants
+	if gc.Main.Config.RCSGroupMembership && conv.IsGroupChat && conv.Type == gmproto.ConversationType_RCS && hasSelf {
+		members.PowerLevels.Invite = ptr.Ptr(0)
+	}
 	if userLoginChanged {
 		err := gc.UserLogin.Save(ctx)
 		if err != nil {
 			log.Warn().Msg("Failed to save user login")
 		}
 	}
-	var tag event.RoomTag
-	if conv.Pinned {
-		tag = event.RoomTagFavourite
-	} else if conv.Status == gmproto.ConversationStatus_ARCHIVED || conv.Status == gmproto.ConversationStatus_KEEP_ARCHIVED {
-		tag = event.RoomTagLowPriority
+	tag := conversationTag(conv)
+	mutedUntil := &bridgev2.Unmuted
+	if conv.GetMuted().GetIsMuted() != 0 {
+		// The phone doesn't have timed mutes
+		mutedUntil = &event.MutedForever
 	}
 	var avatar *bridgev2.Avatar
 	if conv.GroupAvatarURL != "" {
@@ -162,7 +180,8 @@ func (gc *GMClient) wrapChatInfo(ctx context.Context, conv *gmproto.Conversation
 		Type:    &roomType,
 		Avatar:  avatar,
 		UserLocal: &bridgev2.UserLocalPortalInfo{
-			Tag: &tag,
+			MutedUntil: mutedUntil,
+			Tag:        &tag,
 		},
 		CanBackfill: true,
 		ExtraUpdates: func(ctx context.Context, portal *bridgev2.Portal) (changed bool) {
@@ -179,11 +198,45 @@ func (gc *GMClient) wrapChatInfo(ctx context.Context, conv *gmproto.Conversation
 				meta.OutgoingID = conv.DefaultOutgoingID
 				changed = true
 			}
+			if gc.updatePortalSIM(ctx, portal) {
+				changed = true
+			}
 			return
 		},
 	}, nil
 }
 
+// StateSIM is a room state event containing the carrier name, color and phone number of
+// the SIM that messages in the room are sent from. It's only set if the phone has multiple SIMs.
+var StateSIM = event.Type{Type: "fi.mau.gmessages.sim", Class: event.StateEventType}
+
+// updatePortalSIM shows the SIM that the portal sends messages from in the SIM state event.
+// The portal metadata is changed, but not saved.
+func (gc *GMClient) updatePortalSIM(ctx context.Context, portal *bridgev2.Portal) (changed bool) {
+	if portal.MXID == "" {
+		return false
+	}
+	meta := portal.Metadata.(*PortalMetadata)
+	var shownSIM *bridgeStateSIMMeta
+	if sim := gc.GetSIM(portal); sim != nil && gc.Meta.SIMCount() > 1 {
+		shownSIM = ptr.Ptr(makeBridgeStateSIMMeta(sim))
+	}
+	if shownSIM == meta.ShownSIM || (shownSIM != nil && meta.ShownSIM != nil && *shownSIM == *meta.ShownSIM) {
+		return false
+	}
+	content := &event.Content{Raw: map[string]any{}}
+	if shownSIM != nil {
+		content = &event.Content{Parsed: shownSIM}
+	}
+	_, err := portal.Bridge.Bot.SendState(ctx, portal.MXID, StateSIM, "", content, time.Time{})
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to send SIM state event")
+		return false
+	}
+	meta.ShownSIM = shownSIM
+	return true
+}
+
 func (gc *GMClient) makeGroupAvatarFromURL(url string) *bridgev2.Avatar {
 	avatarID := networkid.AvatarID(url)
 	return &bridgev2.Avatar{
@@ -207,7 +260,7 @@ func (gc *GMClient) updateGhostAvatar(ctx context.Context, ghost *bridgev2.Ghost
 	if err != nil {
 		return false, err
 	}
-	resp, err := gc.Client.GetParticipantThumbnail(participantID)
+	resp, err := gc.Client.GetParticipantThumbnail(ctx, participantID)
 	if err != nil {
 		return false, fmt.Errorf("failed to get participant thumbnail: %w", err)
 	}
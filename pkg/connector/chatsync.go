@@ -17,7 +17,9 @@
 package connector
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -33,7 +35,7 @@ import (
 func (gc *GMClient) SyncConversations(ctx context.Context, lastDataReceived time.Time, minimalSync bool) {
 	log := zerolog.Ctx(ctx)
 	log.Info().Msg("Fetching conversation list")
-	resp, err := gc.Client.ListConversations(gc.Main.Config.InitialChatSyncCount, gmproto.ListConversationsRequest_INBOX)
+	resp, err := gc.Client.ListConversations(ctx, gc.Main.Config.InitialChatSyncCount, gmproto.ListConversationsRequest_INBOX)
 	if err != nil {
 		log.Err(err).Msg("Failed to get conversation list")
 		return
@@ -61,9 +63,118 @@ func (gc *GMClient) SyncConversations(ctx context.Context, lastDataReceived time
 		gc.chatInfoCache.GetOrSet(conv.ConversationID, conv)
 		gc.syncConversation(ctx, conv, "sync")
 	}
+	if gc.Main.Config.FullChatSync.Enabled {
+		go gc.fullChatSync(ctx)
+	}
+}
+
+var fullChatSyncFolders = []gmproto.ListConversationsRequest_Folder{
+	gmproto.ListConversationsRequest_INBOX,
+	gmproto.ListConversationsRequest_ARCHIVE,
+	gmproto.ListConversationsRequest_SPAM_BLOCKED,
+}
+
+// fullChatSync goes through every conversation on the phone folder by folder, so that portals get created
+// for old chats too. The progress is saved after every page, so an interrupted sync continues where it left off
+// the next time conversations are synced.
+func (gc *GMClient) fullChatSync(ctx context.Context) {
+	// The sync is cancelled when the client disconnects
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	if !gc.cancelFullChatSync.CompareAndSwap(nil, &cancel) {
+		return
+	}
+	defer gc.cancelFullChatSync.CompareAndSwap(&cancel, nil)
+	cfg := gc.Main.Config.FullChatSync
+	state := gc.Meta.GetFullChatSync()
+	if state.Done {
+		return
+	}
+	log := zerolog.Ctx(ctx).With().Str("action", "full chat sync").Logger()
+	ctx = log.WithContext(ctx)
+	cli := gc.Client
+	if cli == nil {
+		return
+	}
+	folders, cursor := state.remainingFolders()
+	for _, folder := range folders {
+		if folder == gmproto.ListConversationsRequest_SPAM_BLOCKED && !cfg.IncludeSpam {
+			log.Debug().Msg("Inbox and archive synced, not syncing spam folder")
+			return
+		}
+		log.Info().Stringer("folder", folder).Any("cursor", cursor).Msg("Syncing conversations in folder")
+		count := 0
+		for page, err := range cli.PaginateConversations(ctx, folder, cmp.Or(cfg.PageSize, 50), cursor) {
+			if ctx.Err() != nil {
+				log.Debug().Msg("Full chat sync cancelled")
+				return
+			} else if err != nil {
+				log.Err(err).Stringer("folder", folder).Msg("Failed to fetch conversations, will retry on next sync")
+				return
+			}
+			for _, conv := range page.GetConversations() {
+				gc.chatInfoCache.GetOrSet(conv.ConversationID, conv)
+				gc.syncConversation(ctx, conv, "full sync")
+			}
+			count += len(page.GetConversations())
+			state = FullChatSyncState{
+				Folder:   folder,
+				CursorID: page.GetCursor().GetLastItemID(),
+				CursorTS: page.GetCursor().GetLastItemTimestamp(),
+			}
+			gc.saveFullChatSync(ctx, state)
+			if page.GetCursor() == nil {
+				break
+			}
+			select {
+			case <-time.After(cfg.PageDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		log.Info().Stringer("folder", folder).Int("count", count).Msg("Finished syncing conversations in folder")
+		state = fullChatSyncFolderDone(folder)
+		gc.saveFullChatSync(ctx, state)
+		cursor = nil
+	}
+	log.Info().Msg("Full chat sync complete")
 }
 
-func (gc *GMClient) syncConversationMeta(v *gmproto.Conversation) (meta *conversationMeta, suspiciousUnmarkedSpam bool) {
+// remainingFolders returns the folders that haven't been fully synced yet,
+// and the cursor to continue the first one from.
+func (fcs *FullChatSyncState) remainingFolders() ([]gmproto.ListConversationsRequest_Folder, *gmproto.Cursor) {
+	if fcs.Done {
+		return nil, nil
+	}
+	idx := slices.Index(fullChatSyncFolders, fcs.Folder)
+	if idx < 0 {
+		return fullChatSyncFolders, nil
+	}
+	return fullChatSyncFolders[idx:], fcs.Cursor()
+}
+
+// fullChatSyncFolderDone returns the sync state after all conversations in the given folder have been synced.
+func fullChatSyncFolderDone(folder gmproto.ListConversationsRequest_Folder) FullChatSyncState {
+	if nextIdx := slices.Index(fullChatSyncFolders, folder) + 1; nextIdx < len(fullChatSyncFolders) {
+		return FullChatSyncState{Folder: fullChatSyncFolders[nextIdx]}
+	}
+	return FullChatSyncState{Folder: folder, Done: true}
+}
+
+func (gc *GMClient) saveFullChatSync(ctx context.Context, state FullChatSyncState) {
+	gc.Meta.SetFullChatSync(state)
+	err := gc.UserLogin.Save(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to save full chat sync progress")
+	}
+}
+
+// isMarkedUnread checks if the conversation was manually marked as unread on the phone.
+func isMarkedUnread(conv *gmproto.Conversation) bool {
+	return conv.GetUnread() && conv.GetUnknownTimestamp() > conv.GetLastMessageTimestamp()
+}
+
+func (gc *GMClient) syncConversationMeta(v *gmproto.Conversation) (meta *conversationMeta, suspiciousUnmarkedSpam, markedUnreadChanged bool) {
 	gc.conversationMetaLock.Lock()
 	defer gc.conversationMetaLock.Unlock()
 	var ok bool
@@ -73,6 +184,9 @@ func (gc *GMClient) syncConversationMeta(v *gmproto.Conversation) (meta *convers
 		gc.conversationMeta[v.ConversationID] = meta
 	}
 	meta.unread = v.Unread
+	markedUnread := isMarkedUnread(v)
+	markedUnreadChanged = meta.markedUnread != markedUnread
+	meta.markedUnread = markedUnread
 	if !v.Unread {
 		meta.readUpTo = v.LatestMessageID
 		meta.readUpToTS = time.UnixMicro(v.LastMessageTimestamp)
@@ -93,8 +207,18 @@ func (gc *GMClient) syncConversationMeta(v *gmproto.Conversation) (meta *convers
 	return
 }
 
+// clearMarkedSpam forgets that a conversation was in the spam or blocked folder,
+// so that it moving back to the inbox isn't dropped as a race condition.
+func (gc *GMClient) clearMarkedSpam(conversationID string) {
+	gc.conversationMetaLock.Lock()
+	defer gc.conversationMetaLock.Unlock()
+	if meta, ok := gc.conversationMeta[conversationID]; ok {
+		meta.markedSpamAt = time.Time{}
+	}
+}
+
 func (gc *GMClient) syncConversation(ctx context.Context, v *gmproto.Conversation, source string) {
-	meta, suspiciousUnmarkedSpam := gc.syncConversationMeta(v)
+	meta, suspiciousUnmarkedSpam, markedUnreadChanged := gc.syncConversationMeta(v)
 
 	log := zerolog.Ctx(ctx).With().
 		Str("action", "sync conversation").
@@ -134,6 +258,16 @@ func (gc *GMClient) syncConversation(ctx context.Context, v *gmproto.Conversatio
 		// Don't send read/backfill events if the chat is being deleted
 		return
 	}
+	if markedUnreadChanged {
+		gc.Main.br.QueueRemoteEvent(gc.UserLogin, &simplevent.MarkUnread{
+			EventMeta: simplevent.EventMeta{
+				Type:      bridgev2.RemoteEventMarkUnread,
+				PortalKey: gc.MakePortalKey(v.ConversationID),
+				Sender:    bridgev2.EventSender{IsFromMe: true},
+			},
+			Unread: isMarkedUnread(v),
+		})
+	}
 	if !evt.AllowBackfill {
 		backfillEvt := &GMChatResync{
 			g:             gc,
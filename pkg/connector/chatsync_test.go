@@ -0,0 +1,67 @@
+package connector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+func TestFullChatSyncResume(t *testing.T) {
+	var state FullChatSyncState
+	folders, cursor := state.remainingFolders()
+	assert.Equal(t, fullChatSyncFolders, folders)
+	assert.Nil(t, cursor)
+
+	// Progress is saved in the user login metadata after every page
+	meta := &UserLoginMetadata{}
+	meta.SetFullChatSync(FullChatSyncState{
+		Folder:   gmproto.ListConversationsRequest_ARCHIVE,
+		CursorID: "42",
+		CursorTS: 1234,
+	})
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+	var loaded UserLoginMetadata
+	require.NoError(t, json.Unmarshal(data, &loaded))
+	state = loaded.GetFullChatSync()
+	folders, cursor = state.remainingFolders()
+	assert.Equal(t, []gmproto.ListConversationsRequest_Folder{
+		gmproto.ListConversationsRequest_ARCHIVE,
+		gmproto.ListConversationsRequest_SPAM_BLOCKED,
+	}, folders)
+	assert.Equal(t, "42", cursor.GetLastItemID())
+	assert.Equal(t, int64(1234), cursor.GetLastItemTimestamp())
+
+	state = fullChatSyncFolderDone(gmproto.ListConversationsRequest_ARCHIVE)
+	folders, cursor = state.remainingFolders()
+	assert.Equal(t, []gmproto.ListConversationsRequest_Folder{gmproto.ListConversationsRequest_SPAM_BLOCKED}, folders)
+	assert.Nil(t, cursor)
+
+	state = fullChatSyncFolderDone(gmproto.ListConversationsRequest_SPAM_BLOCKED)
+	assert.True(t, state.Done)
+	folders, _ = state.remainingFolders()
+	assert.Empty(t, folders)
+}
+
+func TestIsMarkedUnread(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		conv     *gmproto.Conversation
+		expected bool
+	}{
+		{"Read", &gmproto.Conversation{LastMessageTimestamp: 100, UnknownTimestamp: 200}, false},
+		{"NewMessage", &gmproto.Conversation{Unread: true, LastMessageTimestamp: 200, UnknownTimestamp: 100}, false},
+		{"NewMessageSameTimestamp", &gmproto.Conversation{Unread: true, LastMessageTimestamp: 200, UnknownTimestamp: 200}, false},
+		{"MarkedUnread", &gmproto.Conversation{Unread: true, LastMessageTimestamp: 100, UnknownTimestamp: 200}, true},
+		{"NoTimestamps", &gmproto.Conversation{Unread: true}, false},
+		{"Nil", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isMarkedUnread(tc.conv))
+		})
+	}
+}
@@ -19,6 +19,9 @@ package connector
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -38,6 +41,7 @@ type conversationMeta struct {
 	markedSpamAt          time.Time
 	cancelPendingBackfill atomic.Pointer[context.CancelFunc]
 	unread                bool
+	markedUnread          bool
 	readUpTo              string
 	readUpToTS            time.Time
 }
@@ -50,24 +54,26 @@ type GMClient struct {
 
 	fullMediaRequests *exsync.Set[fullMediaRequestKey]
 
-	longPollingError            error
-	browserInactiveType         status.BridgeStateErrorCode
 	SwitchedToGoogleLogin       bool
 	batteryLow                  bool
 	mobileData                  bool
-	PhoneResponding             bool
-	ready                       bool
 	sessionID                   string
 	batteryLowAlertSent         time.Time
 	pollErrorAlertSent          bool
 	phoneNotRespondingAlertSent bool
-	didHackySetActive           bool
+	didHackySetActive           atomic.Bool
 	noDataReceivedRecently      bool
 	lastDataReceived            time.Time
+	cancelFullChatSync          atomic.Pointer[context.CancelFunc]
+
+	unsubscribeEvents []func()
+	captureRecorder   *libgm.Recorder
 
 	chatInfoCache        *exsync.Map[string, *gmproto.Conversation]
 	conversationMeta     map[string]*conversationMeta
 	conversationMetaLock sync.Mutex
+	typingSentAt         map[string]time.Time
+	typingLock           sync.Mutex
 }
 
 var _ bridgev2.NetworkAPI = &GMClient{}
@@ -78,10 +84,9 @@ func (gc *GMConnector) LoadUserLogin(ctx context.Context, login *bridgev2.UserLo
 		UserLogin: login,
 		Meta:      login.Metadata.(*UserLoginMetadata),
 
-		longPollingError:  errors.New("not connected"),
-		PhoneResponding:   true,
 		fullMediaRequests: exsync.NewSet[fullMediaRequestKey](),
 		conversationMeta:  make(map[string]*conversationMeta),
+		typingSentAt:      make(map[string]time.Time),
 		chatInfoCache:     exsync.NewMap[string, *gmproto.Conversation](),
 	}
 	gcli.NewClient()
@@ -103,6 +108,9 @@ func (gc *GMClient) Connect(ctx context.Context) {
 		})
 		return
 	}
+	if gc.Main.Config.CaptureDir != "" {
+		gc.Client.SetRecorder(gc.getCaptureRecorder())
+	}
 	err := gc.Client.FetchConfig(ctx)
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Msg("Failed to fetch config")
@@ -153,21 +161,30 @@ func (gc *GMClient) Connect(ctx context.Context) {
 }
 
 func (gc *GMClient) Disconnect() {
-	gc.longPollingError = errors.New("not connected")
-	gc.PhoneResponding = true
 	gc.batteryLow = false
 	gc.SwitchedToGoogleLogin = false
-	gc.ready = false
-	gc.browserInactiveType = ""
 	if cli := gc.Client; cli != nil {
 		cli.Disconnect()
 	}
+	if cancel := gc.cancelFullChatSync.Swap(nil); cancel != nil {
+		(*cancel)()
+	}
+	if gc.captureRecorder != nil {
+		err := gc.captureRecorder.Close()
+		if err != nil {
+			gc.UserLogin.Log.Err(err).Msg("Failed to close capture file")
+		}
+		gc.captureRecorder = nil
+	}
 }
 
 func (gc *GMClient) ResetClient() {
 	gc.Disconnect()
 	if cli := gc.Client; cli != nil {
-		cli.SetEventHandler(nil)
+		for _, unsubscribe := range gc.unsubscribeEvents {
+			unsubscribe()
+		}
+		gc.unsubscribeEvents = nil
 		gc.Client = nil
 	}
 	gc.NewClient()
@@ -178,8 +195,30 @@ func (gc *GMClient) NewClient() {
 	if sess != nil {
 		gc.Client = libgm.NewClient(sess, gc.Meta.PublicPushKeys(), gc.UserLogin.Log.With().Str("component", "libgm").Logger())
 		gc.Client.SetPingInterval(gc.Main.Config.PingInterval)
-		gc.Client.SetEventHandler(gc.handleGMEvent)
+		gc.Client.SetEndpoints(gc.Main.Config.Endpoints.Endpoints())
+		gc.Client.SetAckStore(gc.Main.DB.PendingAcks(gc.UserLogin.ID))
+		if gc.Main.metrics != nil {
+			gc.Client.SetMetrics(gc.Main.metrics)
+		}
+		gc.unsubscribeEvents = gc.subscribeEvents(gc.Client)
+	}
+}
+
+// getCaptureRecorder opens the capture file of the login. The file is kept open until the client
+// is disconnected, and reconnecting appends to the same file.
+func (gc *GMClient) getCaptureRecorder() *libgm.Recorder {
+	if gc.captureRecorder != nil {
+		return gc.captureRecorder
+	}
+	path := filepath.Join(gc.Main.Config.CaptureDir, fmt.Sprintf("%s.jsonl", gc.UserLogin.ID))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		gc.UserLogin.Log.Err(err).Str("path", path).Msg("Failed to open capture file")
+		return nil
 	}
+	gc.UserLogin.Log.Warn().Str("path", path).Msg("Recording protocol traffic to capture file")
+	gc.captureRecorder = libgm.NewRecorder(file)
+	return gc.captureRecorder
 }
 
 func (gc *GMClient) IsLoggedIn() bool {
@@ -188,12 +227,16 @@ func (gc *GMClient) IsLoggedIn() bool {
 
 func (gc *GMClient) LogoutRemote(ctx context.Context) {
 	if cli := gc.Client; cli != nil {
-		err := cli.Unpair()
+		err := cli.Unpair(ctx)
 		if err != nil {
 			zerolog.Ctx(ctx).Err(err).Msg("Failed to send unpair request")
 		}
 	}
 	gc.Disconnect()
+	err := gc.Main.DB.PendingAcks(gc.UserLogin.ID).RemoveAll(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to delete pending acks")
+	}
 	gc.Meta.Session = nil
 	gc.Client = nil
 }
@@ -203,6 +246,23 @@ func (gc *GMClient) IsThisUser(ctx context.Context, userID networkid.UserID) boo
 	return err == nil && (participantID == "1" || gc.Meta.IsSelfParticipantID(participantID))
 }
 
+// GetOutgoingID returns the participant ID of the SIM that messages in the given portal are sent from.
+// A SIM chosen with the sim command is preferred over the conversation's default as long as it's still in the phone.
+func (gc *GMClient) GetOutgoingID(portal *bridgev2.Portal) string {
+	meta := portal.Metadata.(*PortalMetadata)
+	if meta.SIMOverride != "" && gc.Meta.GetSIM(meta.SIMOverride) != nil {
+		return meta.SIMOverride
+	}
+	return meta.OutgoingID
+}
+
 func (gc *GMClient) GetSIM(portal *bridgev2.Portal) *gmproto.SIMCard {
-	return gc.Meta.GetSIM(portal.Metadata.(*PortalMetadata).OutgoingID)
+	return gc.Meta.GetSIM(gc.GetOutgoingID(portal))
+}
+
+// canSendTyping checks whether typing notifications from Matrix should be sent to the phone.
+// The phone's RCS setting is assumed to be enabled until the settings have been received.
+func (gc *GMClient) canSendTyping() bool {
+	phoneSetting := !gc.Meta.Settings.SettingsReceived || gc.Meta.Settings.TypingNotifications
+	return gc.Main.Config.Privacy.ForLogin(gc.UserLogin.ID).TypingNotifications.Allowed(phoneSetting)
 }
@@ -0,0 +1,604 @@
+// mautrix-gmessages - A Matrix-Google Messages puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package connector
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/commands"
+	"maunium.net/go/mautrix/bridgev2/database"
+	"maunium.net/go/mautrix/bridgev2/status"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+var HelpSectionConnectionManagement = commands.HelpSection{Name: "Connection management", Order: 11}
+
+var (
+	cmdSetActive = &commands.FullHandler{
+		Func: fnSetActive,
+		Name: "set-active",
+		Help: commands.HelpMeta{
+			Section:     HelpSectionConnectionManagement,
+			Description: "Set the bridge as the active session if Google Messages was opened in another browser.",
+		},
+		RequiresLogin: true,
+	}
+	cmdPingPhone = &commands.FullHandler{
+		Func: fnPingPhone,
+		Name: "ping-phone",
+		Help: commands.HelpMeta{
+			Section:     HelpSectionConnectionManagement,
+			Description: "Check if your phone is reachable and how long it takes to respond.",
+		},
+		RequiresLogin: true,
+	}
+	cmdReconnect = &commands.FullHandler{
+		Func: fnReconnect,
+		Name: "reconnect",
+		Help: commands.HelpMeta{
+			Section:     HelpSectionConnectionManagement,
+			Description: "Recreate the connection to Google Messages.",
+		},
+		RequiresLogin: true,
+	}
+	cmdDeleteSession = &commands.FullHandler{
+		Func: fnDeleteSession,
+		Name: "delete-session",
+		Help: commands.HelpMeta{
+			Section: HelpSectionConnectionManagement,
+			Description: "Delete the stored session and disconnect without unpairing. " +
+				"The bridge stays in the phone's paired devices list until it's removed there.",
+		},
+		RequiresLogin: true,
+	}
+	cmdSIMs = &commands.FullHandler{
+		Func: fnSIMs,
+		Name: "sims",
+		Help: commands.HelpMeta{
+			Section:     HelpSectionConnectionManagement,
+			Description: "List the SIMs in your phone.",
+		},
+		RequiresLogin: true,
+	}
+	cmdResync = &commands.FullHandler{
+		Func: fnResync,
+		Name: "resync",
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "Resync the info of a chat, or the chat list if used outside a chat without a conversation ID.",
+			Args:        "[_conversation ID_]",
+		},
+		RequiresLogin: true,
+	}
+	cmdBackfill = &commands.FullHandler{
+		Func: fnBackfill,
+		Name: "backfill",
+		Help: commands.HelpMeta{
+			Section: commands.HelpSectionChats,
+			Description: "Fetch older messages in the current chat from the phone. " +
+				"Messages are fetched in batches, so the count is rounded up to the batch size in the backfill section of the bridge config.",
+			Args: "<_count_>",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+	cmdResend = &commands.FullHandler{
+		Func:    fnResend,
+		Name:    "resend",
+		Aliases: []string{"retry"},
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "Ask the phone to resend a message that failed to send. Use this command as a reply to the failed message.",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+	cmdDownload = &commands.FullHandler{
+		Func: fnDownload,
+		Name: "download",
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "Ask the phone to download an MMS message that's waiting for a manual download. Use this command as a reply to the message.",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+	cmdBlock = &commands.FullHandler{
+		Func: fnBlock,
+		Name: "block",
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "Block the other participant of the current direct chat. The chat is moved to the blocked folder on your phone and the room is removed.",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+	cmdReportSpam = &commands.FullHandler{
+		Func:    fnReportSpam,
+		Name:    "report-spam",
+		Aliases: []string{"spam"},
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "Block the other participant of the current direct chat and report the chat as spam.",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+	cmdUnblock = &commands.FullHandler{
+		Func: fnUnblock,
+		Name: "unblock",
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "Unblock a phone number, or the other participant of the current chat if no number is given.",
+			Args:        "[_phone number_]",
+		},
+		RequiresLogin: true,
+	}
+	cmdSIM = &commands.FullHandler{
+		Func: fnSIM,
+		Name: "sim",
+		Help: commands.HelpMeta{
+			Section:     commands.HelpSectionChats,
+			Description: "View the SIMs in your phone or choose which one sends messages in the current chat.",
+			Args:        "[_number_ | default]",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+	cmdSendMode = &commands.FullHandler{
+		Func: fnSendMode,
+		Name: "send-mode",
+		Help: commands.HelpMeta{
+			Section: commands.HelpSectionChats,
+			Description: "View or change whether messages in the current RCS chat are forced to be sent using RCS. " +
+				"`rcs` always asks the phone to use RCS, `auto` lets the phone decide.",
+			Args: "[auto | rcs]",
+		},
+		RequiresPortal: true,
+		RequiresLogin:  true,
+	}
+)
+
+func getDefaultClient(ce *commands.Event) *GMClient {
+	login := ce.User.GetDefaultLogin()
+	if login == nil {
+		ce.Reply("You're not logged in")
+		return nil
+	}
+	gc, ok := login.Client.(*GMClient)
+	if !ok || gc.Client == nil {
+		ce.Reply("You're not logged in")
+		return nil
+	}
+	return gc
+}
+
+func getPortalClient(ce *commands.Event) *GMClient {
+	login, _, err := ce.Portal.FindPreferredLogin(ce.Ctx, ce.User, false)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to find login for portal")
+		ce.Reply("Failed to find your login in this chat: %v", err)
+		return nil
+	}
+	gc, ok := login.Client.(*GMClient)
+	if !ok || gc.Client == nil {
+		ce.Reply("You're not logged in")
+		return nil
+	}
+	return gc
+}
+
+// getReplyTarget finds the bridged message that the command is a reply to.
+func getReplyTarget(ce *commands.Event) *database.Message {
+	if ce.ReplyTo == "" {
+		ce.Reply("Use `$cmdprefix %s` as a reply to a message", ce.Command)
+		return nil
+	}
+	msg, err := ce.Bridge.DB.Message.GetPartByMXID(ce.Ctx, ce.ReplyTo)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to get reply target message")
+		ce.Reply("Failed to get message: %v", err)
+		return nil
+	} else if msg == nil || msg.Room != ce.Portal.PortalKey {
+		ce.Reply("That message isn't bridged to this chat")
+		return nil
+	}
+	return msg
+}
+
+func fnResend(ce *commands.Event) {
+	msg := getReplyTarget(ce)
+	if msg == nil {
+		return
+	}
+	gc := getPortalClient(ce)
+	if gc == nil {
+		return
+	}
+	err := gc.resendMessage(ce.Ctx, msg)
+	if errors.Is(err, ErrMessageNotRetryable) {
+		ce.Reply("Can't resend that message: %v", err)
+	} else if err != nil {
+		ce.Log.Err(err).Msg("Failed to resend message")
+		ce.Reply("Failed to resend message: %v", err)
+	} else {
+		ce.React("✅️")
+	}
+}
+
+func fnDownload(ce *commands.Event) {
+	msg := getReplyTarget(ce)
+	if msg == nil {
+		return
+	}
+	gc := getPortalClient(ce)
+	if gc == nil {
+		return
+	}
+	if msg.Metadata.(*MessageMetadata).Type != gmproto.MessageStatusType_INCOMING_YET_TO_MANUAL_DOWNLOAD {
+		ce.Reply("That message isn't waiting for a manual download")
+		return
+	}
+	msgID, err := gc.ParseMessageID(msg.ID)
+	if err != nil {
+		ce.Reply("Failed to parse message ID: %v", err)
+		return
+	}
+	// The message will be edited when the phone has downloaded it
+	err = gc.Client.DownloadMessage(ce.Ctx, msgID)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to request message download")
+		ce.Reply("Failed to request download: %v", err)
+	} else {
+		ce.React("✅️")
+	}
+}
+
+func fnBlock(ce *commands.Event) {
+	blockPortal(ce, false)
+}
+
+func fnReportSpam(ce *commands.Event) {
+	blockPortal(ce, true)
+}
+
+func blockPortal(ce *commands.Event, report bool) {
+	if ce.Portal.RoomType != database.RoomTypeDM {
+		ce.Reply("Only direct chats can be blocked")
+		return
+	}
+	gc := getPortalClient(ce)
+	if gc == nil {
+		return
+	}
+	convID, err := gc.ParsePortalID(ce.Portal.ID)
+	if err != nil {
+		ce.Reply("Failed to parse conversation ID: %v", err)
+		return
+	}
+	if report {
+		err = gc.Client.ReportSpam(ce.Ctx, convID)
+	} else {
+		err = gc.Client.BlockConversation(ce.Ctx, convID)
+	}
+	if err != nil {
+		ce.Log.Err(err).Bool("report", report).Msg("Failed to block conversation")
+		ce.Reply("Failed to block chat: %v", err)
+	} else if report {
+		ce.Reply("Blocked and reported chat as spam. This room will be removed shortly.")
+	} else {
+		ce.Reply("Blocked chat. This room will be removed shortly.")
+	}
+}
+
+func fnUnblock(ce *commands.Event) {
+	var gc *GMClient
+	var convID string
+	var err error
+	if len(ce.Args) > 0 {
+		if gc = getDefaultClient(ce); gc == nil {
+			return
+		}
+		var phone string
+		phone, err = bridgev2.CleanNonInternationalPhoneNumber(ce.RawArgs)
+		if err != nil {
+			ce.Reply("Invalid phone number: %v", err)
+			return
+		}
+		var resp *gmproto.GetOrCreateConversationResponse
+		resp, err = gc.Client.GetOrCreateConversation(ce.Ctx, &gmproto.GetOrCreateConversationRequest{
+			Numbers: []*gmproto.ContactNumber{{
+				MysteriousInt: 2,
+				Number:        phone,
+				Number2:       phone,
+			}},
+		})
+		if err != nil {
+			ce.Log.Err(err).Msg("Failed to get conversation to unblock")
+			ce.Reply("Failed to find chat with %s: %v", phone, err)
+			return
+		}
+		convID = resp.GetConversation().GetConversationID()
+		if convID == "" {
+			ce.Reply("Failed to find chat with %s", phone)
+			return
+		}
+	} else if ce.Portal != nil {
+		if gc = getPortalClient(ce); gc == nil {
+			return
+		} else if convID, err = gc.ParsePortalID(ce.Portal.ID); err != nil {
+			ce.Reply("Failed to parse conversation ID: %v", err)
+			return
+		}
+	} else {
+		ce.Reply("**Usage:** `$cmdprefix unblock <phone number>`")
+		return
+	}
+	gc.clearMarkedSpam(convID)
+	err = gc.Client.UnblockConversation(ce.Ctx, convID)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to unblock conversation")
+		ce.Reply("Failed to unblock chat: %v", err)
+	} else {
+		ce.Reply("Unblocked chat")
+	}
+}
+
+func formatSIMList(sims []bridgeStateSIMMeta, activeID string) []string {
+	lines := make([]string, len(sims))
+	for i, sim := range sims {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, cmp.Or(sim.CarrierName, "Unknown carrier"))
+		if sim.PhoneNumber != "" {
+			lines[i] += fmt.Sprintf(" (%s)", sim.PhoneNumber)
+		}
+		if sim.ParticipantID == activeID {
+			lines[i] += " - **active**"
+		}
+	}
+	return lines
+}
+
+func fnSIM(ce *commands.Event) {
+	gc := getPortalClient(ce)
+	if gc == nil {
+		return
+	}
+	meta := ce.Portal.Metadata.(*PortalMetadata)
+	sims := gc.Meta.GetSIMsForBridgeState()
+	if len(ce.Args) == 0 {
+		if len(sims) == 0 {
+			ce.Reply("No SIMs found. The phone may not have sent its settings yet.")
+			return
+		}
+		lines := formatSIMList(sims, gc.GetOutgoingID(ce.Portal))
+		if meta.SIMOverride != "" {
+			lines = append(lines, "", "The SIM was chosen manually. Use `$cmdprefix sim default` to go back to the chat's default SIM.")
+		}
+		ce.Reply(strings.Join(lines, "\n"))
+		return
+	}
+	if strings.ToLower(ce.Args[0]) == "default" {
+		meta.SIMOverride = ""
+	} else if idx, err := strconv.Atoi(ce.Args[0]); err != nil || idx < 1 || idx > len(sims) {
+		ce.Reply("**Usage:** `$cmdprefix sim [number | default]`, where number is from the list shown by `$cmdprefix sim`")
+		return
+	} else {
+		meta.SIMOverride = sims[idx-1].ParticipantID
+	}
+	gc.updatePortalSIM(ce.Ctx, ce.Portal)
+	err := ce.Portal.Save(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to save portal after changing SIM")
+		ce.Reply("Failed to save SIM choice: %v", err)
+		return
+	}
+	if meta.SIMOverride == "" {
+		ce.Reply("Messages in this chat will be sent using the chat's default SIM")
+	} else {
+		ce.Reply("Messages in this chat will be sent using %s", cmp.Or(gc.GetSIM(ce.Portal).GetSIMData().GetCarrierName(), "the selected SIM"))
+	}
+}
+
+func fnSendMode(ce *commands.Event) {
+	if getPortalClient(ce) == nil {
+		return
+	}
+	meta := ce.Portal.Metadata.(*PortalMetadata)
+	if len(ce.Args) == 0 {
+		ce.Reply("Send mode: %s (chat type on phone: %s, phone send mode: %s)",
+			cmp.Or(meta.SendModeOverride, "auto"), meta.Type, meta.SendMode)
+		return
+	}
+	var newMode SendModeOverride
+	switch strings.ToLower(ce.Args[0]) {
+	case "auto":
+		newMode = SendModeOverrideAuto
+	case "rcs":
+		if meta.Type != gmproto.ConversationType_RCS {
+			ce.Reply("This chat isn't an RCS chat on your phone, so RCS can't be forced")
+			return
+		}
+		newMode = SendModeOverrideRCS
+	case "sms", "mms":
+		ce.Reply("Forcing SMS/MMS isn't supported, as the way to ask the phone for it isn't known")
+		return
+	default:
+		ce.Reply("**Usage:** `$cmdprefix send-mode [auto | rcs]`")
+		return
+	}
+	meta.SendModeOverride = newMode
+	err := ce.Portal.Save(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to save portal after changing send mode")
+		ce.Reply("Failed to save send mode: %v", err)
+		return
+	}
+	ce.Reply("Send mode changed to %s", cmp.Or(newMode, "auto"))
+}
+
+func fnSetActive(ce *commands.Event) {
+	gc := getDefaultClient(ce)
+	if gc == nil {
+		return
+	}
+	err := gc.Client.SetActiveSession(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to set active session")
+		ce.Reply("Failed to set active session: %v", err)
+	} else {
+		ce.Reply("Set bridge as the active session")
+	}
+}
+
+func fnPingPhone(ce *commands.Event) {
+	gc := getDefaultClient(ce)
+	if gc == nil {
+		return
+	}
+	start := time.Now()
+	// There's no dedicated ping request, so use the cheapest request that the phone answers
+	resp, err := gc.Client.IsBugleDefault(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to ping phone")
+		ce.Reply("Failed to ping phone: %v", err)
+		return
+	}
+	ce.Reply("Phone responded in %d ms (default SMS app: %t)", time.Since(start).Milliseconds(), resp.GetSuccess())
+}
+
+func fnReconnect(ce *commands.Event) {
+	gc := getDefaultClient(ce)
+	if gc == nil {
+		return
+	}
+	ce.Reply("Reconnecting...")
+	gc.ResetClient()
+	gc.didHackySetActive.Store(false)
+	gc.Connect(gc.UserLogin.Log.WithContext(context.Background()))
+}
+
+func fnDeleteSession(ce *commands.Event) {
+	gc := getDefaultClient(ce)
+	if gc == nil {
+		return
+	}
+	gc.invalidateSession(ce.Ctx, status.BridgeState{
+		StateEvent: status.StateBadCredentials,
+		Error:      GMNotLoggedIn,
+	}, true)
+	ce.Reply("Session deleted. Remove the bridge from the paired devices list on your phone to finish logging out.")
+}
+
+func fnSIMs(ce *commands.Event) {
+	gc := getDefaultClient(ce)
+	if gc == nil {
+		return
+	}
+	sims := gc.Meta.GetSIMsForBridgeState()
+	if len(sims) == 0 {
+		ce.Reply("No SIMs found. The phone may not have sent its settings yet.")
+		return
+	}
+	lines := formatSIMList(sims, "")
+	for i, sim := range sims {
+		if sim.RCSEnabled {
+			lines[i] += " - RCS enabled"
+		}
+	}
+	ce.Reply(strings.Join(lines, "\n"))
+}
+
+func fnResync(ce *commands.Event) {
+	var gc *GMClient
+	var convID string
+	if len(ce.Args) > 0 {
+		if gc = getDefaultClient(ce); gc == nil {
+			return
+		}
+		convID = ce.Args[0]
+	} else if ce.Portal != nil {
+		if gc = getPortalClient(ce); gc == nil {
+			return
+		}
+		var err error
+		if convID, err = gc.ParsePortalID(ce.Portal.ID); err != nil {
+			ce.Reply("Failed to parse conversation ID: %v", err)
+			return
+		}
+	} else {
+		if gc = getDefaultClient(ce); gc == nil {
+			return
+		}
+		go gc.SyncConversations(gc.UserLogin.Log.WithContext(context.Background()), time.Time{}, false)
+		ce.Reply("Started resyncing chat list")
+		return
+	}
+	conv, err := gc.Client.GetConversation(ce.Ctx, convID)
+	if err != nil {
+		ce.Log.Err(err).Str("conversation_id", convID).Msg("Failed to get conversation to resync")
+		ce.Reply("Failed to get conversation: %v", err)
+		return
+	}
+	gc.chatInfoCache.Set(convID, conv)
+	gc.syncConversation(ce.Ctx, conv, "command")
+	ce.Reply("Resync queued")
+}
+
+func fnBackfill(ce *commands.Event) {
+	count, err := strconv.Atoi(ce.RawArgs)
+	if err != nil || count <= 0 {
+		ce.Reply("**Usage:** `$cmdprefix backfill <count>`")
+		return
+	} else if !ce.Bridge.Matrix.GetCapabilities().BatchSending {
+		ce.Reply("Backfilling old messages requires a homeserver that supports batch sending")
+		return
+	}
+	gc := getPortalClient(ce)
+	if gc == nil {
+		return
+	}
+	task := &database.BackfillTask{
+		BridgeID:    ce.Bridge.ID,
+		PortalKey:   ce.Portal.PortalKey,
+		UserLoginID: gc.UserLogin.ID,
+	}
+	// Each backwards backfill fetches one full batch, so the count can't be more precise than that
+	batchSize := max(ce.Bridge.Config.Backfill.Queue.BatchSize, 1)
+	batches := (count + batchSize - 1) / batchSize
+	ce.Reply("Backfilling up to %d messages in %d batches", batches*batchSize, batches)
+	for i := 0; i < batches && !task.IsDone; i++ {
+		err = ce.Portal.DoBackwardsBackfill(ce.Ctx, gc.UserLogin, task)
+		if err != nil {
+			ce.Log.Err(err).Msg("Failed to backfill")
+			ce.Reply("Failed to backfill: %v", err)
+			return
+		}
+	}
+	if task.IsDone {
+		ce.Reply("Backfill finished, there are no older messages")
+	} else {
+		ce.Reply("Backfill finished")
+	}
+}
@@ -17,6 +17,7 @@
 package connector
 
 import (
+	"cmp"
 	_ "embed"
 	"strings"
 	"text/template"
@@ -24,6 +25,9 @@ import (
 
 	up "go.mau.fi/util/configupgrade"
 	"gopkg.in/yaml.v3"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/util"
 )
 
 //go:embed example-config.yaml
@@ -35,13 +39,83 @@ type DeviceMetaConfig struct {
 	Type    string `yaml:"type"`
 }
 
+type EndpointConfig struct {
+	InstantMessaging       string `yaml:"instant_messaging"`
+	InstantMessagingGoogle string `yaml:"instant_messaging_google"`
+	MessagesWeb            string `yaml:"messages_web"`
+}
+
+func (ec *EndpointConfig) Endpoints() *util.Endpoints {
+	return util.NewEndpoints(
+		cmp.Or(strings.TrimRight(ec.InstantMessaging, "/"), util.InstantMessagingBaseURL),
+		cmp.Or(strings.TrimRight(ec.InstantMessagingGoogle, "/"), util.InstantMessagingBaseURLGoogle),
+		cmp.Or(strings.TrimRight(ec.MessagesWeb, "/"), util.MessagesBaseURL),
+	)
+}
+
+type FullChatSyncConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	IncludeSpam bool          `yaml:"include_spam"`
+	PageSize    int           `yaml:"page_size"`
+	PageDelay   time.Duration `yaml:"page_delay"`
+}
+
+// PrivacyMode decides whether something is sent to the phone based on the phone's own setting.
+type PrivacyMode string
+
+const (
+	PrivacyAuto   PrivacyMode = "auto"
+	PrivacyAlways PrivacyMode = "always"
+	PrivacyNever  PrivacyMode = "never"
+)
+
+// Allowed returns whether sending is allowed given the corresponding setting on the phone.
+func (pm PrivacyMode) Allowed(phoneSetting bool) bool {
+	switch pm {
+	case PrivacyAlways:
+		return true
+	case PrivacyNever:
+		return false
+	default:
+		return phoneSetting
+	}
+}
+
+// PrivacyOptions only covers things that the bridge sends to other users itself. Read receipts aren't included,
+// because marking messages as read always has to reach the phone, which then decides by its own setting
+// whether the sender is told about it.
+type PrivacyOptions struct {
+	TypingNotifications PrivacyMode `yaml:"typing_notifications"`
+}
+
+type PrivacyConfig struct {
+	PrivacyOptions `yaml:",inline"`
+	Logins         map[networkid.UserLoginID]PrivacyOptions `yaml:"logins"`
+}
+
+// ForLogin returns the privacy options for the given login, with per-login overrides applied.
+func (pc *PrivacyConfig) ForLogin(loginID networkid.UserLoginID) PrivacyOptions {
+	opts := pc.PrivacyOptions
+	if override, ok := pc.Logins[loginID]; ok {
+		opts.TypingNotifications = cmp.Or(override.TypingNotifications, opts.TypingNotifications)
+	}
+	return opts
+}
+
 type Config struct {
-	DisplaynameTemplate   string           `yaml:"displayname_template"`
-	DeviceMeta            DeviceMetaConfig `yaml:"device_meta"`
-	AggressiveReconnect   bool             `yaml:"aggressive_reconnect"`
-	InitialChatSyncCount  int              `yaml:"initial_chat_sync_count"`
-	DeterministicIDPrefix bool             `yaml:"deterministic_id_prefix"`
-	PingInterval          time.Duration    `yaml:"ping_interval"`
+	DisplaynameTemplate   string             `yaml:"displayname_template"`
+	DeviceMeta            DeviceMetaConfig   `yaml:"device_meta"`
+	AggressiveReconnect   bool               `yaml:"aggressive_reconnect"`
+	InitialChatSyncCount  int                `yaml:"initial_chat_sync_count"`
+	FullChatSync          FullChatSyncConfig `yaml:"full_chat_sync"`
+	DeterministicIDPrefix bool               `yaml:"deterministic_id_prefix"`
+	PingInterval          time.Duration      `yaml:"ping_interval"`
+	AutoDownloadMMS       bool               `yaml:"auto_download_mms"`
+	RCSGroupMembership    bool               `yaml:"rcs_group_membership"`
+	Privacy               PrivacyConfig      `yaml:"privacy"`
+	Endpoints             EndpointConfig     `yaml:"endpoints"`
+	Metrics               MetricsConfig      `yaml:"metrics"`
+	CaptureDir            string             `yaml:"capture_dir"`
 
 	displaynameTemplate *template.Template `yaml:"-"`
 }
@@ -88,5 +162,19 @@ func upgradeConfig(helper up.Helper) {
 	helper.Copy(up.Str, "device_meta", "type")
 	helper.Copy(up.Bool, "aggressive_reconnect")
 	helper.Copy(up.Int, "initial_chat_sync_count")
+	helper.Copy(up.Bool, "full_chat_sync", "enabled")
+	helper.Copy(up.Bool, "full_chat_sync", "include_spam")
+	helper.Copy(up.Int, "full_chat_sync", "page_size")
+	helper.Copy(up.Str|up.Int, "full_chat_sync", "page_delay")
 	helper.Copy(up.Str|up.Int, "ping_interval")
+	helper.Copy(up.Bool, "auto_download_mms")
+	helper.Copy(up.Bool, "rcs_group_membership")
+	helper.Copy(up.Str, "privacy", "typing_notifications")
+	helper.Copy(up.Map, "privacy", "logins")
+	helper.Copy(up.Str|up.Null, "endpoints", "instant_messaging")
+	helper.Copy(up.Str|up.Null, "endpoints", "instant_messaging_google")
+	helper.Copy(up.Str|up.Null, "endpoints", "messages_web")
+	helper.Copy(up.Bool, "metrics", "enabled")
+	helper.Copy(up.Str|up.Null, "metrics", "listen")
+	helper.Copy(up.Str|up.Null, "capture_dir")
 }
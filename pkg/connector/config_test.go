@@ -0,0 +1,32 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPrivacyConfigForLogin(t *testing.T) {
+	var cfg PrivacyConfig
+	err := yaml.Unmarshal([]byte(`
+typing_notifications: never
+logins:
+    override:
+        typing_notifications: always
+    empty: {}
+`), &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, PrivacyNever, cfg.ForLogin("other").TypingNotifications)
+	assert.Equal(t, PrivacyAlways, cfg.ForLogin("override").TypingNotifications)
+	assert.Equal(t, PrivacyNever, cfg.ForLogin("empty").TypingNotifications, "unset options should fall back to the global value")
+}
+
+func TestPrivacyModeAllowed(t *testing.T) {
+	for _, phoneSetting := range []bool{true, false} {
+		assert.Equal(t, phoneSetting, PrivacyAuto.Allowed(phoneSetting))
+		assert.Equal(t, phoneSetting, PrivacyMode("").Allowed(phoneSetting))
+		assert.True(t, PrivacyAlways.Allowed(phoneSetting))
+		assert.False(t, PrivacyNever.Allowed(phoneSetting))
+	}
+}
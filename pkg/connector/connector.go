@@ -19,7 +19,9 @@ package connector
 import (
 	"context"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/commands"
 
 	"go.mau.fi/mautrix-gmessages/pkg/connector/gmdb"
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
@@ -30,6 +32,8 @@ type GMConnector struct {
 	br     *bridgev2.Bridge
 	DB     *gmdb.GMDB
 	Config Config
+
+	metrics *PrometheusMetrics
 }
 
 var _ bridgev2.NetworkConnector = (*GMConnector)(nil)
@@ -37,6 +41,10 @@ var _ bridgev2.NetworkConnector = (*GMConnector)(nil)
 func (gc *GMConnector) Init(bridge *bridgev2.Bridge) {
 	gc.DB = gmdb.New(bridge.DB.Database, bridge.Log.With().Str("db_section", "gmessages").Logger())
 	gc.br = bridge
+	gc.br.Commands.(*commands.Processor).AddHandlers(
+		cmdSetActive, cmdPingPhone, cmdReconnect, cmdDeleteSession, cmdSIMs,
+		cmdResync, cmdBackfill, cmdResend, cmdDownload, cmdBlock, cmdReportSpam, cmdUnblock, cmdSIM, cmdSendMode,
+	)
 
 	util.BrowserDetailsMessage.OS = gc.Config.DeviceMeta.OS
 	browserVal, ok := gmproto.BrowserType_value[gc.Config.DeviceMeta.Browser]
@@ -51,10 +59,24 @@ func (gc *GMConnector) Init(bridge *bridgev2.Bridge) {
 	} else {
 		util.BrowserDetailsMessage.DeviceType = gmproto.DeviceType(deviceVal)
 	}
+	if gc.Config.Metrics.Enabled {
+		gc.metrics = NewPrometheusMetrics()
+		err := gc.metrics.Register(prometheus.DefaultRegisterer)
+		if err != nil {
+			gc.br.Log.Err(err).Msg("Failed to register metrics")
+		}
+	}
 }
 
 func (gc *GMConnector) Start(ctx context.Context) error {
-	return gc.DB.Upgrade(ctx)
+	err := gc.DB.Upgrade(ctx)
+	if err != nil {
+		return err
+	}
+	if gc.metrics != nil && gc.Config.Metrics.Listen != "" {
+		gc.startMetricsListener()
+	}
+	return nil
 }
 
 func (gc *GMConnector) GetName() bridgev2.BridgeName {
@@ -54,12 +54,34 @@ func (gc *GMConnector) GetDBMetaTypes() database.MetaTypes {
 	}
 }
 
+// SendModeOverride is a manually chosen send mode for a portal, set with the send-mode command.
+type SendModeOverride string
+
+const (
+	SendModeOverrideAuto SendModeOverride = ""
+	SendModeOverrideRCS  SendModeOverride = "rcs"
+)
+
 type PortalMetadata struct {
-	Type     gmproto.ConversationType     `json:"type"`
-	SendMode gmproto.ConversationSendMode `json:"send_mode"`
-	ForceRCS bool                         `json:"force_rcs"`
+	Type             gmproto.ConversationType     `json:"type"`
+	SendMode         gmproto.ConversationSendMode `json:"send_mode"`
+	ForceRCS         bool                         `json:"force_rcs"`
+	SendModeOverride SendModeOverride             `json:"send_mode_override,omitempty"`
+
+	OutgoingID  string              `json:"outgoing_id"`
+	SIMOverride string              `json:"sim_override,omitempty"`
+	ShownSIM    *bridgeStateSIMMeta `json:"shown_sim,omitempty"`
+}
 
-	OutgoingID string `json:"outgoing_id"`
+// ShouldForceRCS returns whether outgoing messages should have the force RCS flag set.
+// RCS is never forced in chats that the phone doesn't consider RCS, as the recipients may not support it.
+func (pm *PortalMetadata) ShouldForceRCS() bool {
+	if pm.Type != gmproto.ConversationType_RCS {
+		return false
+	} else if pm.SendModeOverride == SendModeOverrideRCS {
+		return true
+	}
+	return pm.SendMode == gmproto.ConversationSendMode_SEND_MODE_AUTO && pm.ForceRCS
 }
 
 type GhostMetadata struct {
@@ -106,6 +128,23 @@ type UserLoginMetadata struct {
 	Settings           UserSettings
 	IDPrefix           string
 	PushKeys           *PushKeys
+	fullChatSync       FullChatSyncState
+}
+
+// FullChatSyncState is the progress of the background sync of all conversations.
+type FullChatSyncState struct {
+	// Folder is the folder currently being synced. Folders are synced in the order of fullChatSyncFolders.
+	Folder   gmproto.ListConversationsRequest_Folder `json:"folder,omitempty"`
+	CursorID string                                  `json:"cursor_id,omitempty"`
+	CursorTS int64                                   `json:"cursor_ts,omitempty"`
+	Done     bool                                    `json:"done,omitempty"`
+}
+
+func (fcs *FullChatSyncState) Cursor() *gmproto.Cursor {
+	if fcs.CursorID == "" && fcs.CursorTS == 0 {
+		return nil
+	}
+	return &gmproto.Cursor{LastItemID: fcs.CursorID, LastItemTimestamp: fcs.CursorTS}
 }
 
 type PushKeys struct {
@@ -148,6 +187,7 @@ type serializableUserLoginMetadata struct {
 	Settings           UserSettings                `json:"settings"`
 	IDPrefix           string                      `json:"id_prefix"`
 	PushKeys           *PushKeys                   `json:"push_keys"`
+	FullChatSync       FullChatSyncState           `json:"full_chat_sync,omitzero"`
 }
 
 func (ulm *UserLoginMetadata) CopyFrom(other any) {
@@ -168,6 +208,7 @@ func (ulm *UserLoginMetadata) MarshalJSON() ([]byte, error) {
 		Settings:           ulm.Settings,
 		IDPrefix:           ulm.IDPrefix,
 		PushKeys:           ulm.PushKeys,
+		FullChatSync:       ulm.fullChatSync,
 	})
 }
 
@@ -185,9 +226,22 @@ func (ulm *UserLoginMetadata) UnmarshalJSON(data []byte) error {
 	ulm.Settings = sulm.Settings
 	ulm.IDPrefix = sulm.IDPrefix
 	ulm.PushKeys = sulm.PushKeys
+	ulm.fullChatSync = sulm.FullChatSync
 	return nil
 }
 
+func (ulm *UserLoginMetadata) GetFullChatSync() FullChatSyncState {
+	ulm.lock.RLock()
+	defer ulm.lock.RUnlock()
+	return ulm.fullChatSync
+}
+
+func (ulm *UserLoginMetadata) SetFullChatSync(state FullChatSyncState) {
+	ulm.lock.Lock()
+	ulm.fullChatSync = state
+	ulm.lock.Unlock()
+}
+
 func (ulm *UserLoginMetadata) AddSelfParticipantID(id string) bool {
 	if id == "" {
 		return false
@@ -213,18 +267,22 @@ func (ulm *UserLoginMetadata) SIMCount() int {
 	return len(ulm.simMetadata)
 }
 
+func makeBridgeStateSIMMeta(sim *gmproto.SIMCard) bridgeStateSIMMeta {
+	return bridgeStateSIMMeta{
+		CarrierName:   sim.GetSIMData().GetCarrierName(),
+		ColorHex:      sim.GetSIMData().GetColorHex(),
+		ParticipantID: sim.GetSIMParticipant().GetID(),
+		RCSEnabled:    sim.GetRCSChats().GetEnabled(),
+		PhoneNumber:   sim.GetSIMData().GetFormattedPhoneNumber(),
+	}
+}
+
 func (ulm *UserLoginMetadata) GetSIMsForBridgeState() []bridgeStateSIMMeta {
 	ulm.lock.RLock()
 	defer ulm.lock.RUnlock()
 	data := make([]bridgeStateSIMMeta, 0, len(ulm.simMetadata))
 	for _, sim := range ulm.simMetadata {
-		data = append(data, bridgeStateSIMMeta{
-			CarrierName:   sim.GetSIMData().GetCarrierName(),
-			ColorHex:      sim.GetSIMData().GetColorHex(),
-			ParticipantID: sim.GetSIMParticipant().GetID(),
-			RCSEnabled:    sim.GetRCSChats().GetEnabled(),
-			PhoneNumber:   sim.GetSIMData().GetFormattedPhoneNumber(),
-		})
+		data = append(data, makeBridgeStateSIMMeta(sim))
 	}
 	slices.SortFunc(data, func(a, b bridgeStateSIMMeta) int {
 		return strings.Compare(a.ParticipantID, b.ParticipantID)
@@ -0,0 +1,30 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+func TestShouldForceRCS(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		meta     PortalMetadata
+		expected bool
+	}{
+		{"RCS", PortalMetadata{Type: gmproto.ConversationType_RCS}, false},
+		{"RCSForced", PortalMetadata{Type: gmproto.ConversationType_RCS, ForceRCS: true}, true},
+		{"RCSForcedXMSMode", PortalMetadata{Type: gmproto.ConversationType_RCS, ForceRCS: true, SendMode: gmproto.ConversationSendMode_SEND_MODE_XMS}, false},
+		{"RCSOverride", PortalMetadata{Type: gmproto.ConversationType_RCS, SendModeOverride: SendModeOverrideRCS}, true},
+		{"RCSOverrideXMSMode", PortalMetadata{Type: gmproto.ConversationType_RCS, SendModeOverride: SendModeOverrideRCS, SendMode: gmproto.ConversationSendMode_SEND_MODE_XMS}, true},
+		{"SMS", PortalMetadata{Type: gmproto.ConversationType_SMS}, false},
+		{"SMSForced", PortalMetadata{Type: gmproto.ConversationType_SMS, ForceRCS: true}, false},
+		{"SMSOverride", PortalMetadata{Type: gmproto.ConversationType_SMS, SendModeOverride: SendModeOverrideRCS}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.meta.ShouldForceRCS())
+		})
+	}
+}
@@ -17,13 +17,22 @@
 package connector
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	"maunium.net/go/mautrix/bridgev2"
+
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 )
 
-var ()
+var (
+	ErrMessageNotRetryable = errors.New("message has already been sent or can't be retried")
+
+	ErrMembershipRequiresRCSGroup = bridgev2.WrapErrorInStatus(errors.New("members can only be changed in RCS group chats")).WithErrorAsMessage().WithIsCertain(true).WithSendNotice(true)
+	ErrContactNotRCSCapable       = bridgev2.WrapErrorInStatus(errors.New("contact can't be added to RCS group chats")).WithErrorAsMessage().WithIsCertain(true).WithSendNotice(true)
+	ErrMarkUnreadNotSupported     = bridgev2.WrapErrorInStatus(errors.New("marking chats as unread isn't supported")).WithErrorAsMessage().WithIsCertain(true).WithSendNotice(false)
+)
 
 type responseStatusError gmproto.SendMessageResponse
 
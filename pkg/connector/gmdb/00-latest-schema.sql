@@ -1,4 +1,4 @@
--- v0 -> v1 (compatible with v1+): Latest schema
+-- v0 -> v2 (compatible with v1+): Latest schema
 CREATE TABLE gmessages_login_prefix(
     -- only: postgres
     prefix BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
@@ -8,3 +8,10 @@ CREATE TABLE gmessages_login_prefix(
 
     CONSTRAINT gmessages_login_prefix_login_id_key UNIQUE (login_id)
 );
+
+CREATE TABLE gmessages_pending_ack(
+    login_id   TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+
+    PRIMARY KEY (login_id, message_id)
+);
@@ -0,0 +1,7 @@
+-- v2 (compatible with v1+): Add table for unacknowledged messages
+CREATE TABLE gmessages_pending_ack(
+    login_id   TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+
+    PRIMARY KEY (login_id, message_id)
+);
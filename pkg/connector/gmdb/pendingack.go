@@ -0,0 +1,82 @@
+// mautrix-gmessages - A Matrix-Google Messages puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gmdb
+
+import (
+	"context"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm"
+)
+
+const (
+	addPendingAckQuery = `
+		INSERT INTO gmessages_pending_ack (login_id, message_id) VALUES ($1, $2)
+		ON CONFLICT (login_id, message_id) DO NOTHING
+	`
+	removePendingAckQuery     = `DELETE FROM gmessages_pending_ack WHERE login_id=$1 AND message_id=$2`
+	removeAllPendingAcksQuery = `DELETE FROM gmessages_pending_ack WHERE login_id=$1`
+	getPendingAcksQuery       = `SELECT message_id FROM gmessages_pending_ack WHERE login_id=$1`
+)
+
+// PendingAckStore implements [libgm.AckStore] for a single user login.
+type PendingAckStore struct {
+	db      *GMDB
+	loginID networkid.UserLoginID
+}
+
+var _ libgm.AckStore = (*PendingAckStore)(nil)
+
+func (db *GMDB) PendingAcks(loginID networkid.UserLoginID) *PendingAckStore {
+	return &PendingAckStore{db: db, loginID: loginID}
+}
+
+func (pas *PendingAckStore) AddPendingAcks(ctx context.Context, messageIDs []string) error {
+	return pas.db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		for _, id := range messageIDs {
+			_, err := pas.db.Exec(ctx, addPendingAckQuery, pas.loginID, id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pas *PendingAckStore) RemovePendingAcks(ctx context.Context, messageIDs []string) error {
+	return pas.db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		for _, id := range messageIDs {
+			_, err := pas.db.Exec(ctx, removePendingAckQuery, pas.loginID, id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pas *PendingAckStore) GetPendingAcks(ctx context.Context) ([]string, error) {
+	rows, err := pas.db.Query(ctx, getPendingAcksQuery, pas.loginID)
+	return dbutil.NewRowIterWithError(rows, dbutil.ScanSingleColumn[string], err).AsList()
+}
+
+func (pas *PendingAckStore) RemoveAll(ctx context.Context) error {
+	_, err := pas.db.Exec(ctx, removeAllPendingAcksQuery, pas.loginID)
+	return err
+}
@@ -23,6 +23,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -45,9 +47,44 @@ import (
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 )
 
-func (gc *GMClient) handleGMEvent(rawEvt any) {
-	log := gc.UserLogin.Log.With().Str("action", "handle gmessages event").Logger()
-	ctx := log.WithContext(context.TODO())
+// gmEventHandler wraps an event handler so that it gets a context with the user login's logger.
+func gmEventHandler[T any](gc *GMClient, handler func(ctx context.Context, evt T)) func(T) {
+	return func(evt T) {
+		log := gc.UserLogin.Log.With().Str("action", "handle gmessages event").Logger()
+		handler(log.WithContext(context.TODO()), evt)
+	}
+}
+
+func (gc *GMClient) subscribeEvents(cli *libgm.Client) []func() {
+	return []func(){
+		cli.OnConnectionState(gmEventHandler(gc, gc.handleConnectionEvent)),
+		cli.OnConversation(gmEventHandler(gc, gc.handleConversationEvent)),
+		cli.OnMessage(gmEventHandler(gc, gc.handleMessageEvent)),
+		cli.OnTyping(gmEventHandler(gc, gc.handleTypingEvent)),
+		cli.OnUserAlert(gmEventHandler(gc, gc.handleUserAlert)),
+		cli.OnSettings(gmEventHandler(gc, gc.handleSettingsEvent)),
+		libgm.Subscribe(cli, gmEventHandler(gc, gc.handleAccountChange)),
+		libgm.Subscribe(cli, gmEventHandler(gc, gc.handlePairRevoked)),
+		libgm.Subscribe(cli, gmEventHandler(gc, gc.handleGaiaLoggedOut)),
+		libgm.Subscribe(cli, gmEventHandler(gc, gc.handleAuthTokenRefreshed)),
+		libgm.Subscribe(cli, gmEventHandler(gc, gc.handleUnexpectedPairSuccess)),
+		cli.AddEventHandler(gmEventHandler(gc, gc.handleUnknownEvent)),
+	}
+}
+
+// handleUnknownEvent logs events that none of the typed handlers above receive.
+func (gc *GMClient) handleUnknownEvent(ctx context.Context, rawEvt any) {
+	switch rawEvt.(type) {
+	case events.ConnectionEvent, *gmproto.Conversation, *libgm.WrappedMessage, *gmproto.TypingData,
+		*gmproto.UserAlertEvent, *gmproto.Settings, *events.AccountChange, *gmproto.RevokePairData,
+		*events.GaiaLoggedOut, *events.AuthTokenRefreshed, *events.PairSuccessful:
+	default:
+		zerolog.Ctx(ctx).Trace().Any("data", rawEvt).Type("data_type", rawEvt).Msg("Unknown event")
+	}
+}
+
+func (gc *GMClient) handleConnectionEvent(ctx context.Context, rawEvt events.ConnectionEvent) {
+	log := zerolog.Ctx(ctx)
 	switch evt := rawEvt.(type) {
 	case *events.ListenFatalError:
 		if errors.Is(evt.Error, events.ErrInvalidCredentials) || evt.Error.Error() == "http 401 while polling" {
@@ -67,39 +104,34 @@ func (gc *GMClient) handleGMEvent(rawEvt any) {
 				Info:       map[string]any{"go_error": evt.Error.Error()},
 			})
 		}
+	case *events.ConnectionStateChanged:
+		// Logouts and fatal errors are handled above with more specific error codes
+		if evt.State != events.StateDisconnected && evt.State != events.StateLoggedOut {
+			gc.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
+		}
 	case *events.ListenTemporaryError:
-		gc.longPollingError = evt.Error
-		gc.UserLogin.BridgeState.Send(status.BridgeState{
-			StateEvent: status.StateTransientDisconnect,
-			Error:      GMListenError,
-			Info:       map[string]any{"go_error": evt.Error.Error()},
-		})
 		if !gc.pollErrorAlertSent {
 			//go gc.sendMarkdownBridgeAlert(ctx, false, "Temporary error while listening to Google Messages: %v", evt.Error)
 			gc.pollErrorAlertSent = true
 		}
 	case *events.ListenRecovered:
-		gc.longPollingError = nil
-		gc.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
 		if gc.pollErrorAlertSent {
 			//go gc.sendMarkdownBridgeAlert(ctx, false, "Reconnected to Google Messages")
 			gc.pollErrorAlertSent = false
 		}
 	case *events.PhoneNotResponding:
-		gc.PhoneResponding = false
-		gc.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
 		// TODO make this properly configurable
 		if log.Trace().Enabled() && !gc.phoneNotRespondingAlertSent {
 			//go gc.sendMarkdownBridgeAlert(ctx, false, "Phone is not responding")
 			gc.phoneNotRespondingAlertSent = true
 		}
 	case *events.PhoneRespondingAgain:
-		gc.PhoneResponding = true
-		gc.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
 		if gc.phoneNotRespondingAlertSent {
 			//go gc.sendMarkdownBridgeAlert(ctx, false, "Phone is responding again")
 			gc.phoneNotRespondingAlertSent = false
 		}
+	case *events.NoDataReceived:
+		gc.noDataReceivedRecently = true
 	case *events.HackySetActiveMayFail:
 		go gc.hackyResetActive()
 	case *events.PingFailed:
@@ -120,107 +152,138 @@ func (gc *GMClient) handleGMEvent(rawEvt any) {
 		} else {
 			log.Debug().Msg("Not sending unknown error for first ping fail")
 		}
-	case *gmproto.RevokePairData:
-		log.Info().Any("revoked_device", evt.GetRevokedDevice()).Msg("Got pair revoked event")
-		go gc.invalidateSession(ctx, status.BridgeState{
-			StateEvent: status.StateBadCredentials,
-			Error:      GMUnpaired,
-		}, true)
-		//go gc.sendMarkdownBridgeAlert(ctx, true, "Unpaired from Google Messages. Log in again to continue using the bridge.")
-	case *events.GaiaLoggedOut:
-		log.Info().Msg("Got gaia logout event")
-		go gc.invalidateSession(ctx, status.BridgeState{
-			StateEvent: status.StateBadCredentials,
-			Error:      GMUnpaired,
-		}, true)
-		//go gc.sendMarkdownBridgeAlert(ctx, true, "Unpaired from Google Messages. Log in again to continue using the bridge.")
-	case *events.AuthTokenRefreshed:
-		go func() {
-			err := gc.UserLogin.Save(ctx)
-			if err != nil {
-				log.Err(err).Msg("Failed to update session in database")
-			}
-		}()
-	case *gmproto.Conversation:
-		gc.chatInfoCache.Set(evt.ConversationID, evt)
-		gc.noDataReceivedRecently = false
-		gc.lastDataReceived = time.Now()
-		go gc.syncConversation(ctx, evt, "event")
-	//case *gmproto.Message:
-	case *libgm.WrappedMessage:
-		gc.noDataReceivedRecently = false
-		gc.lastDataReceived = time.Now()
-		if evt.GetTimestamp() > gc.lastDataReceived.UnixMicro() {
-			gc.lastDataReceived = time.UnixMicro(evt.GetTimestamp())
+	}
+}
+
+func (gc *GMClient) handlePairRevoked(ctx context.Context, evt *gmproto.RevokePairData) {
+	log := zerolog.Ctx(ctx)
+	log.Info().Any("revoked_device", evt.GetRevokedDevice()).Msg("Got pair revoked event")
+	go gc.invalidateSession(ctx, status.BridgeState{
+		StateEvent: status.StateBadCredentials,
+		Error:      GMUnpaired,
+	}, true)
+	//go gc.sendMarkdownBridgeAlert(ctx, true, "Unpaired from Google Messages. Log in again to continue using the bridge.")
+}
+
+func (gc *GMClient) handleGaiaLoggedOut(ctx context.Context, _ *events.GaiaLoggedOut) {
+	log := zerolog.Ctx(ctx)
+	log.Info().Msg("Got gaia logout event")
+	go gc.invalidateSession(ctx, status.BridgeState{
+		StateEvent: status.StateBadCredentials,
+		Error:      GMUnpaired,
+	}, true)
+	//go gc.sendMarkdownBridgeAlert(ctx, true, "Unpaired from Google Messages. Log in again to continue using the bridge.")
+}
+
+func (gc *GMClient) handleAuthTokenRefreshed(ctx context.Context, _ *events.AuthTokenRefreshed) {
+	log := zerolog.Ctx(ctx)
+	go func() {
+		err := gc.UserLogin.Save(ctx)
+		if err != nil {
+			log.Err(err).Msg("Failed to update session in database")
 		}
+	}()
+}
+
+func (gc *GMClient) handleUnexpectedPairSuccess(ctx context.Context, evt *events.PairSuccessful) {
+	zerolog.Ctx(ctx).Warn().Any("data", evt).Msg("Unexpected pair successful event")
+}
+
+func (gc *GMClient) handleConversationEvent(ctx context.Context, evt *gmproto.Conversation) {
+	gc.chatInfoCache.Set(evt.ConversationID, evt)
+	gc.noDataReceivedRecently = false
+	gc.lastDataReceived = time.Now()
+	go gc.syncConversation(ctx, evt, "event")
+}
+
+func (gc *GMClient) handleMessageEvent(ctx context.Context, evt *libgm.WrappedMessage) {
+	log := zerolog.Ctx(ctx)
+	gc.noDataReceivedRecently = false
+	gc.lastDataReceived = time.Now()
+	if evt.GetTimestamp() > gc.lastDataReceived.UnixMicro() {
+		gc.lastDataReceived = time.UnixMicro(evt.GetTimestamp())
+	}
+	log.Debug().
+		Str("conversation_id", evt.GetConversationID()).
+		Str("participant_id", evt.GetParticipantID()).
+		Str("message_id", evt.GetMessageID()).
+		Str("message_status", evt.GetMessageStatus().GetStatus().String()).
+		Int64("message_ts", evt.GetTimestamp()).
+		Int64("message_type", evt.GetType()).
+		Str("tmp_id", evt.GetTmpID()).
+		Bool("is_old", evt.IsOld).
+		Bool("is_redelivered", evt.IsRedelivered).
+		Msg("Received message")
+	gc.Main.br.QueueRemoteEvent(gc.UserLogin, &MessageEvent{
+		WrappedMessage: evt,
+		g:              gc,
+	})
+	if gc.Main.Config.AutoDownloadMMS && !evt.IsRedelivered &&
+		evt.GetMessageStatus().GetStatus() == gmproto.MessageStatusType_INCOMING_YET_TO_MANUAL_DOWNLOAD {
+		go gc.downloadMessage(ctx, evt.GetMessageID())
+	}
+}
+
+func (gc *GMClient) downloadMessage(ctx context.Context, messageID string) {
+	log := zerolog.Ctx(ctx).With().Str("message_id", messageID).Logger()
+	cli := gc.Client
+	if cli == nil {
+		log.Debug().Msg("Not requesting message download as client is not logged in anymore")
+		return
+	}
+	log.Debug().Msg("Requesting phone to download message")
+	err := cli.DownloadMessage(ctx, messageID)
+	if err != nil {
+		log.Err(err).Msg("Failed to request message download")
+	}
+}
+
+func (gc *GMClient) handleTypingEvent(ctx context.Context, evt *gmproto.TypingData) {
+	log := zerolog.Ctx(ctx)
+	timeout := 15 * time.Second
+	if evt.Type == gmproto.TypingTypes_STOPPED_TYPING {
+		timeout = 0
+	}
+	chatInfo, ok := gc.chatInfoCache.Get(evt.ConversationID)
+	if !ok {
 		log.Debug().
 			Str("conversation_id", evt.GetConversationID()).
-			Str("participant_id", evt.GetParticipantID()).
-			Str("message_id", evt.GetMessageID()).
-			Str("message_status", evt.GetMessageStatus().GetStatus().String()).
-			Int64("message_ts", evt.GetTimestamp()).
-			Int64("message_type", evt.GetType()).
-			Str("tmp_id", evt.GetTmpID()).
-			Bool("is_old", evt.IsOld).
-			Msg("Received message")
-		gc.Main.br.QueueRemoteEvent(gc.UserLogin, &MessageEvent{
-			WrappedMessage: evt,
-			g:              gc,
-		})
-	case *gmproto.TypingData:
-		timeout := 15 * time.Second
-		if evt.Type == gmproto.TypingTypes_STOPPED_TYPING {
-			timeout = 0
-		}
-		chatInfo, ok := gc.chatInfoCache.Get(evt.ConversationID)
-		if !ok {
-			log.Debug().
-				Str("conversation_id", evt.GetConversationID()).
-				Str("number", evt.GetUser().GetNumber()).
-				Msg("Didn't find cached conversation info to find participant ID for typing notification")
-			return
-		}
-		participantID := getPhoneNumberParticipantID(chatInfo, evt.GetUser().GetNumber())
-		if participantID == "" {
-			log.Debug().
-				Str("conversation_id", evt.GetConversationID()).
-				Str("number", evt.GetUser().GetNumber()).
-				Msg("Didn't find participant ID for typing notification")
-			return
-		}
-		gc.Main.br.QueueRemoteEvent(gc.UserLogin, &simplevent.Typing{
-			EventMeta: simplevent.EventMeta{
-				Type: bridgev2.RemoteEventTyping,
-				LogContext: func(c zerolog.Context) zerolog.Context {
-					return c.
-						Str("conversation_id", evt.GetConversationID()).
-						Str("participant_id", participantID).
-						Str("number", evt.GetUser().GetNumber())
-				},
-				PortalKey: gc.MakePortalKey(evt.ConversationID),
-				Sender:    gc.makeEventSender("", participantID, false, false),
+			Str("number", evt.GetUser().GetNumber()).
+			Msg("Didn't find cached conversation info to find participant ID for typing notification")
+		return
+	}
+	participantID := getPhoneNumberParticipantID(chatInfo, evt.GetUser().GetNumber())
+	if participantID == "" {
+		log.Debug().
+			Str("conversation_id", evt.GetConversationID()).
+			Str("number", evt.GetUser().GetNumber()).
+			Msg("Didn't find participant ID for typing notification")
+		return
+	}
+	gc.Main.br.QueueRemoteEvent(gc.UserLogin, &simplevent.Typing{
+		EventMeta: simplevent.EventMeta{
+			Type: bridgev2.RemoteEventTyping,
+			LogContext: func(c zerolog.Context) zerolog.Context {
+				return c.
+					Str("conversation_id", evt.GetConversationID()).
+					Str("participant_id", participantID).
+					Str("number", evt.GetUser().GetNumber())
 			},
-			Timeout: timeout,
-			Type:    bridgev2.TypingTypeText,
-		})
-	case *gmproto.UserAlertEvent:
-		gc.handleUserAlert(ctx, evt)
-	case *gmproto.Settings:
-		// Don't reset last data received until a BROWSER_ACTIVE event if there hasn't been data recently,
-		// otherwise the resync won't have the right timestamp.
-		if !gc.noDataReceivedRecently {
-			gc.lastDataReceived = time.Now()
-		}
-		gc.handleSettings(ctx, evt)
-	case *events.AccountChange:
-		gc.handleAccountChange(ctx, evt)
-	case *events.NoDataReceived:
-		gc.noDataReceivedRecently = true
-	case *events.PairSuccessful:
-		log.Warn().Any("data", evt).Msg("Unexpected pair successful event")
-	default:
-		log.Trace().Any("data", evt).Type("data_type", evt).Msg("Unknown event")
+			PortalKey: gc.MakePortalKey(evt.ConversationID),
+			Sender:    gc.makeEventSender("", participantID, false, false),
+		},
+		Timeout: timeout,
+		Type:    bridgev2.TypingTypeText,
+	})
+}
+
+func (gc *GMClient) handleSettingsEvent(ctx context.Context, evt *gmproto.Settings) {
+	// Don't reset last data received until a BROWSER_ACTIVE event if there hasn't been data recently,
+	// otherwise the resync won't have the right timestamp.
+	if !gc.noDataReceivedRecently {
+		gc.lastDataReceived = time.Now()
 	}
+	gc.handleSettings(ctx, evt)
 }
 
 func (gc *GMClient) handleAccountChange(ctx context.Context, v *events.AccountChange) {
@@ -236,8 +299,6 @@ func (gc *GMClient) handleAccountChange(ctx context.Context, v *events.AccountCh
 			//go gc.sendMarkdownBridgeAlert(ctx, true, "Switched to Google account pairing, please switch back or relogin with `login-google`.")
 		} else {
 			//go gc.sendMarkdownBridgeAlert(ctx, false, "Switched back to QR pairing, bridge should be reconnected")
-			// Assume connection is ready now even if it wasn't before
-			gc.ready = true
 		}
 	}
 	gc.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
@@ -253,14 +314,12 @@ func (gc *GMClient) handleUserAlert(ctx context.Context, v *gmproto.UserAlertEve
 		gc.lastDataReceived = time.Now()
 	}
 	switch v.GetAlertType() {
-	case gmproto.AlertType_BROWSER_INACTIVE:
-		gc.browserInactiveType = GMBrowserInactive
+	case gmproto.AlertType_BROWSER_INACTIVE, gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT, gmproto.AlertType_BROWSER_INACTIVE_FROM_INACTIVITY:
 		becameInactive = true
 	case gmproto.AlertType_BROWSER_ACTIVE:
-		wasInactive := gc.browserInactiveType != "" || !gc.ready
+		// libgm only updates the connection state after user alert handlers have run
+		wasInactive := gc.Client.ConnectionState() != events.StateLongPollOpen
 		gc.pollErrorAlertSent = false
-		gc.browserInactiveType = ""
-		gc.ready = true
 		newSessionID := gc.Client.CurrentSessionID()
 		sessionIDChanged := gc.sessionID != newSessionID
 		if sessionIDChanged || wasInactive || gc.noDataReceivedRecently {
@@ -287,12 +346,6 @@ func (gc *GMClient) handleUserAlert(ctx context.Context, v *gmproto.UserAlertEve
 	case gmproto.AlertType_MOBILE_DATABASE_SYNC_COMPLETE:
 		log.Debug().Msg("Making minimal sync due to mobile database sync complete event")
 		go gc.SyncConversations(ctx, gc.lastDataReceived, true)
-	case gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT:
-		gc.browserInactiveType = GMBrowserInactiveTimeout
-		becameInactive = true
-	case gmproto.AlertType_BROWSER_INACTIVE_FROM_INACTIVITY:
-		gc.browserInactiveType = GMBrowserInactiveInactivity
-		becameInactive = true
 	case gmproto.AlertType_MOBILE_DATA_CONNECTION:
 		gc.mobileData = true
 	case gmproto.AlertType_MOBILE_WIFI_CONNECTION:
@@ -330,12 +383,12 @@ func (gc *GMClient) aggressiveSetActive() {
 			Int("sleep_seconds", int(sleep.Seconds())).
 			Msg("Aggressively reactivating bridge session after sleep")
 		time.Sleep(sleep)
-		if gc.browserInactiveType == "" {
+		if gc.Client.ConnectionState() != events.StateBrowserInactive {
 			gc.UserLogin.Log.Info().Msg("Bridge session became active on its own, not reactivating")
 			return
 		}
 		gc.UserLogin.Log.Info().Msg("Now reactivating bridge session")
-		err := gc.Client.SetActiveSession()
+		err := gc.Client.SetActiveSession(gc.UserLogin.Log.WithContext(context.Background()))
 		if err != nil {
 			gc.UserLogin.Log.Warn().Err(err).Msg("Failed to set self as active session")
 		} else {
@@ -398,21 +451,20 @@ func (gc *GMClient) invalidateSession(ctx context.Context, state status.BridgeSt
 }
 
 func (gc *GMClient) hackyResetActive() {
-	if gc.didHackySetActive {
+	if !gc.didHackySetActive.CompareAndSwap(false, true) {
 		return
 	}
-	gc.didHackySetActive = true
 	gc.noDataReceivedRecently = false
 	gc.lastDataReceived = time.Time{}
 	time.Sleep(7 * time.Second)
-	if !gc.ready && gc.PhoneResponding && gc.Client != nil {
+	if gc.Client != nil && gc.Client.ConnectionState() == events.StateConnecting {
 		gc.UserLogin.Log.Warn().Msg("Client is still not ready, trying to re-set active session")
-		err := gc.Client.SetActiveSession()
+		err := gc.Client.SetActiveSession(gc.UserLogin.Log.WithContext(context.Background()))
 		if err != nil {
 			gc.UserLogin.Log.Err(err).Msg("Failed to re-set active session")
 		}
 		time.Sleep(7 * time.Second)
-		if !gc.ready && gc.PhoneResponding && gc.Client != nil {
+		if gc.Client != nil && gc.Client.ConnectionState() == events.StateConnecting {
 			gc.UserLogin.Log.Warn().Msg("Client is still not ready, reconnecting")
 			gc.ResetClient()
 			gc.Connect(gc.UserLogin.Log.WithContext(context.TODO()))
@@ -841,7 +893,7 @@ func downloadStatusRank(status gmproto.MessageStatusType) int {
 func downloadPendingStatusMessage(status gmproto.MessageStatusType) string {
 	switch status {
 	case gmproto.MessageStatusType_INCOMING_YET_TO_MANUAL_DOWNLOAD:
-		return "Attachment message (auto-download is disabled, use Messages on Android to download)"
+		return "Attachment message (auto-download is disabled, reply with the download command or use Messages on Android to download)"
 	case gmproto.MessageStatusType_INCOMING_MANUAL_DOWNLOADING,
 		gmproto.MessageStatusType_INCOMING_AUTO_DOWNLOADING,
 		gmproto.MessageStatusType_INCOMING_RETRYING_MANUAL_DOWNLOAD,
@@ -1143,6 +1195,7 @@ func (m *MessageEvent) HandleExisting(ctx context.Context, portal *bridgev2.Port
 		}
 
 	}
+	existingMeta.Type = newStatus
 	result.SaveParts = true
 	return result, nil
 }
@@ -1328,22 +1381,16 @@ func (gc *GMClient) ConvertGoogleMessage(ctx context.Context, portal *bridgev2.P
 }
 
 func (gc *GMClient) convertGoogleMedia(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, msg *gmproto.MediaContent) (content *event.MessageEventContent, mediaID string, isThumbnail bool, err error) {
-	var data []byte
+	var key []byte
 	if msg.MediaID != "" {
-		mediaID = msg.MediaID
-		data, err = gc.Client.DownloadMedia(msg.MediaID, msg.DecryptionKey)
+		mediaID, key = msg.MediaID, msg.DecryptionKey
 	} else if msg.ThumbnailMediaID != "" {
-		mediaID = msg.ThumbnailMediaID
-		data, err = gc.Client.DownloadMedia(msg.ThumbnailMediaID, msg.ThumbnailDecryptionKey)
+		mediaID, key = msg.ThumbnailMediaID, msg.ThumbnailDecryptionKey
 		isThumbnail = true
 	} else if len(msg.GetMediaData()) > 0 {
 		mediaID = "inline"
-		data = msg.GetMediaData()
 	} else {
-		err = fmt.Errorf("no media ID found")
-	}
-	if err != nil {
-		err = fmt.Errorf("%w: %w", bridgev2.ErrMediaDownloadFailed, err)
+		err = fmt.Errorf("%w: no media ID found", bridgev2.ErrMediaDownloadFailed)
 		return
 	}
 	content = &event.MessageEventContent{
@@ -1351,15 +1398,86 @@ func (gc *GMClient) convertGoogleMedia(ctx context.Context, portal *bridgev2.Por
 		Body:    msg.MediaName,
 		Info: &event.FileInfo{
 			MimeType: libgm.FormatToMediaType[msg.GetFormat()].Format,
-			Size:     len(data),
 		},
 	}
+	// Converting audio with ffmpeg needs the file on disk. If the mime type isn't known yet,
+	// it'll only be detected after downloading, so assume it may be audio.
+	mimeClass := strings.Split(content.Info.MimeType, "/")[0]
+	requireFile := ffmpeg.Supported() && (content.Info.MimeType == "" || (mimeClass == "audio" && content.Info.MimeType != "audio/ogg"))
+	// The size lets small files skip the temp file. The phone's size is only for the full media, not thumbnails.
+	var size int64
+	if mediaID == "inline" {
+		size = int64(len(msg.GetMediaData()))
+	} else if !isThumbnail {
+		size = msg.GetSize()
+	}
+	content.URL, content.File, err = intent.UploadMediaStream(ctx, portal.MXID, size, requireFile, func(file io.Writer) (*bridgev2.FileStreamResult, error) {
+		return gc.writeGoogleMedia(ctx, msg, mediaID, key, content, file, requireFile)
+	})
+	if err != nil && !errors.Is(err, bridgev2.ErrMediaDownloadFailed) && !errors.Is(err, bridgev2.ErrMediaConvertFailed) {
+		err = fmt.Errorf("%w: %w", bridgev2.ErrMediaReuploadFailed, err)
+	}
+	return
+}
+
+// mediaHeadWriter keeps the beginning of the written data for detecting the mime type.
+type mediaHeadWriter struct {
+	data []byte
+}
+
+const mediaHeadSize = 3072
+
+func (mhw *mediaHeadWriter) Write(p []byte) (int, error) {
+	if remaining := mediaHeadSize - len(mhw.data); remaining > 0 {
+		mhw.data = append(mhw.data, p[:min(remaining, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (gc *GMClient) writeGoogleMedia(
+	ctx context.Context,
+	msg *gmproto.MediaContent,
+	mediaID string,
+	key []byte,
+	content *event.MessageEventContent,
+	file io.Writer,
+	requireFile bool,
+) (*bridgev2.FileStreamResult, error) {
+	var head mediaHeadWriter
+	writer := io.MultiWriter(file, &head)
+	var size int64
+	if mediaID == "inline" {
+		n, err := writer.Write(msg.GetMediaData())
+		if err != nil {
+			return nil, fmt.Errorf("failed to write inline media: %w", err)
+		}
+		size = int64(n)
+	} else {
+		stream, err := gc.Client.DownloadMediaStream(ctx, mediaID, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", bridgev2.ErrMediaDownloadFailed, err)
+		}
+		size, err = io.Copy(writer, stream)
+		_ = stream.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", bridgev2.ErrMediaDownloadFailed, err)
+		}
+	}
+	// Temp files are preallocated to the size reported by the phone, so cut off anything past the real end
+	if osFile, ok := file.(*os.File); ok {
+		err := osFile.Truncate(size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to truncate media file: %w", err)
+		}
+	}
+	content.Info.Size = int(size)
 	if content.Info.MimeType == "" {
-		content.Info.MimeType = mimetype.Detect(data).String()
+		content.Info.MimeType = mimetype.Detect(head.data).String()
 	}
 	if !strings.ContainsRune(content.Body, '.') {
 		content.Body += mimetype.Lookup(content.Info.MimeType).Extension()
 	}
+	var result bridgev2.FileStreamResult
 	switch strings.Split(content.Info.MimeType, "/")[0] {
 	case "image":
 		content.MsgType = event.MsgImage
@@ -1368,22 +1486,23 @@ func (gc *GMClient) convertGoogleMedia(ctx context.Context, portal *bridgev2.Por
 		// TODO convert weird formats to mp4
 	case "audio":
 		content.MsgType = event.MsgAudio
-		if content.Info.MimeType != "audio/ogg" && ffmpeg.Supported() {
-			data, err = ffmpeg.ConvertBytes(ctx, data, ".ogg", []string{}, []string{"-c:a", "libopus"}, content.Info.MimeType)
+		if osFile, ok := file.(*os.File); ok && requireFile && content.Info.MimeType != "audio/ogg" {
+			convertedPath, err := ffmpeg.ConvertPath(ctx, osFile.Name(), ".ogg", []string{}, []string{"-c:a", "libopus"}, false)
 			if err != nil {
-				err = fmt.Errorf("%w (%s to ogg): %w", bridgev2.ErrMediaConvertFailed, content.Info.MimeType, err)
-				return
+				return nil, fmt.Errorf("%w (%s to ogg): %w", bridgev2.ErrMediaConvertFailed, content.Info.MimeType, err)
+			}
+			result.ReplacementFile = convertedPath
+			if stat, err := os.Stat(convertedPath); err == nil {
+				content.Info.Size = int(stat.Size())
 			}
 			content.Body += ".ogg"
 			content.Info.MimeType = "audio/ogg"
 		}
 		content.MSC3245Voice = &event.MSC3245Voice{}
 	}
-	content.URL, content.File, err = intent.UploadMedia(ctx, portal.MXID, data, content.Body, content.Info.MimeType)
-	if err != nil {
-		err = fmt.Errorf("%w: %w", bridgev2.ErrMediaReuploadFailed, err)
-	}
-	return
+	result.FileName = content.Body
+	result.MimeType = content.Info.MimeType
+	return &result, nil
 }
 
 type fullMediaRequestKey struct {
@@ -1405,7 +1524,7 @@ func (gc *GMClient) requestFullMedia(ctx context.Context, messageID, actionMessa
 			Msg("Not re-requesting full size media")
 		return
 	}
-	_, err := gc.Client.GetFullSizeImage(messageID, actionMessageID)
+	_, err := gc.Client.GetFullSizeImage(ctx, messageID, actionMessageID)
 	if err != nil {
 		log.Err(err).
 			Str("action", "request full size media").
@@ -20,6 +20,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"time"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/rs/zerolog"
@@ -36,11 +39,15 @@ import (
 )
 
 var (
-	_ bridgev2.ReactionHandlingNetworkAPI    = (*GMClient)(nil)
-	_ bridgev2.RedactionHandlingNetworkAPI   = (*GMClient)(nil)
-	_ bridgev2.ReadReceiptHandlingNetworkAPI = (*GMClient)(nil)
-	_ bridgev2.TypingHandlingNetworkAPI      = (*GMClient)(nil)
-	_ bridgev2.DeleteChatHandlingNetworkAPI  = (*GMClient)(nil)
+	_ bridgev2.ReactionHandlingNetworkAPI     = (*GMClient)(nil)
+	_ bridgev2.RedactionHandlingNetworkAPI    = (*GMClient)(nil)
+	_ bridgev2.ReadReceiptHandlingNetworkAPI  = (*GMClient)(nil)
+	_ bridgev2.TypingHandlingNetworkAPI       = (*GMClient)(nil)
+	_ bridgev2.DeleteChatHandlingNetworkAPI   = (*GMClient)(nil)
+	_ bridgev2.MembershipHandlingNetworkAPI   = (*GMClient)(nil)
+	_ bridgev2.MuteHandlingNetworkAPI         = (*GMClient)(nil)
+	_ bridgev2.TagHandlingNetworkAPI          = (*GMClient)(nil)
+	_ bridgev2.MarkedUnreadHandlingNetworkAPI = (*GMClient)(nil)
 )
 
 var _ bridgev2.TransactionIDGeneratingNetwork = (*GMConnector)(nil)
@@ -66,7 +73,7 @@ func (gc *GMClient) HandleMatrixMessage(ctx context.Context, msg *bridgev2.Matri
 		Str("tmp_id", string(txnID)).
 		Str("participant_id", req.GetMessagePayload().GetParticipantID()).
 		Msg("Sending Matrix message to Google Messages")
-	resp, err := gc.Client.SendMessage(req)
+	resp, err := gc.Client.SendMessage(ctx, req)
 	if err != nil {
 		msg.RemovePending(txnID)
 		return nil, err
@@ -75,9 +82,45 @@ func (gc *GMClient) HandleMatrixMessage(ctx context.Context, msg *bridgev2.Matri
 		return nil, bridgev2.WrapErrorInStatus((*responseStatusError)(resp)).
 			WithIsCertain(true).WithSendNotice(true).WithErrorAsMessage()
 	}
+	err = gc.setTyping(ctx, msg.Portal, req.GetConversationID(), false)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to stop typing after sending message")
+	}
 	return &bridgev2.MatrixMessageResponse{Pending: true}, nil
 }
 
+// resendMessage asks the phone to resend an existing message that failed to send.
+// The message will be updated when the phone reports the new status.
+func (gc *GMClient) resendMessage(ctx context.Context, existing *database.Message) error {
+	meta, ok := existing.Metadata.(*MessageMetadata)
+	if !ok || !meta.IsOutgoing {
+		return ErrMessageNotRetryable
+	} else if !isRetryableStatus(meta.Type) {
+		return fmt.Errorf("%w (status: %s)", ErrMessageNotRetryable, meta.Type)
+	} else if gc.Client == nil {
+		return bridgev2.ErrNotLoggedIn
+	}
+	messageID, err := gc.ParseMessageID(existing.ID)
+	if err != nil {
+		return err
+	}
+	zerolog.Ctx(ctx).Debug().
+		Str("message_id", messageID).
+		Stringer("message_status", meta.Type).
+		Msg("Resending failed message")
+	err = gc.Client.ResendMessage(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	// Allow a new failure status to be sent if the retry fails too
+	meta.MSSFailSent = false
+	err = gc.Main.br.DB.Message.Update(ctx, existing)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to save message after resending")
+	}
+	return nil
+}
+
 func (gc *GMClient) handleRemoteEcho(rawEvt bridgev2.RemoteMessage, dbMessage *database.Message) (saveMessage bool, statusErr error) {
 	evt := rawEvt.(*MessageEvent)
 	_, textHash := getTextPart(evt.Message)
@@ -113,15 +156,13 @@ func (gc *GMClient) ConvertMatrixMessage(ctx context.Context, msg *bridgev2.Matr
 			TmpID:                 string(txnID),
 			MessagePayloadContent: nil,
 			ConversationID:        conversationID,
-			ParticipantID:         portalMeta.OutgoingID,
+			ParticipantID:         gc.GetOutgoingID(msg.Portal),
 			TmpID2:                string(txnID),
 		},
 		SIMPayload: sim.GetSIMData().GetSIMPayload(),
 		TmpID:      string(txnID),
-		ForceRCS: portalMeta.Type == gmproto.ConversationType_RCS &&
-			portalMeta.SendMode == gmproto.ConversationSendMode_SEND_MODE_AUTO &&
-			portalMeta.ForceRCS,
-		Reply: nil,
+		ForceRCS:   portalMeta.ShouldForceRCS(),
+		Reply:      nil,
 	}
 	if msg.ReplyTo != nil {
 		replyToID, err := gc.ParseMessageID(msg.ReplyTo.ID)
@@ -165,31 +206,51 @@ func (gc *GMClient) ConvertMatrixMessage(ctx context.Context, msg *bridgev2.Matr
 	return req, nil
 }
 
-func (gc *GMClient) reuploadMedia(ctx context.Context, content *event.MessageEventContent) (*gmproto.MediaContent, error) {
-	data, err := gc.Main.br.Bot.DownloadMedia(ctx, content.URL, content.File)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", bridgev2.ErrMediaDownloadFailed, err)
-	}
-	if content.Info.MimeType == "" {
-		content.Info.MimeType = mimetype.Detect(data).String()
-	}
+func (gc *GMClient) reuploadMedia(ctx context.Context, content *event.MessageEventContent) (resp *gmproto.MediaContent, err error) {
 	fileName := content.Body
 	if content.FileName != "" {
 		fileName = content.FileName
 	}
-	if content.MsgType == event.MsgAudio && content.MSC3245Voice != nil && content.Info.MimeType != "audio/mp4" && ffmpeg.Supported() {
-		data, err = ffmpeg.ConvertBytes(ctx, data, ".m4a", []string{}, []string{"-c:a", "aac"}, content.Info.MimeType)
+	err = gc.Main.br.Bot.DownloadMediaToFile(ctx, content.URL, content.File, false, func(file *os.File) error {
+		if content.Info.MimeType == "" {
+			mime, err := mimetype.DetectReader(file)
+			if err != nil {
+				return fmt.Errorf("failed to detect mime type: %w", err)
+			}
+			content.Info.MimeType = mime.String()
+			_, err = file.Seek(0, io.SeekStart)
+			if err != nil {
+				return fmt.Errorf("failed to seek to start of file: %w", err)
+			}
+		}
+		if content.MsgType == event.MsgAudio && content.MSC3245Voice != nil && content.Info.MimeType != "audio/mp4" && ffmpeg.Supported() {
+			convertedPath, err := ffmpeg.ConvertPath(ctx, file.Name(), ".m4a", []string{}, []string{"-c:a", "aac"}, false)
+			if err != nil {
+				return fmt.Errorf("%w (ogg to m4a): %w", bridgev2.ErrMediaConvertFailed, err)
+			}
+			defer os.Remove(convertedPath)
+			file, err = os.Open(convertedPath)
+			if err != nil {
+				return fmt.Errorf("%w (ogg to m4a): %w", bridgev2.ErrMediaConvertFailed, err)
+			}
+			defer file.Close()
+			fileName += ".m4a"
+			content.Info.MimeType = "audio/mp4"
+		}
+		stat, err := file.Stat()
 		if err != nil {
-			return nil, fmt.Errorf("%w (ogg to m4a): %w", bridgev2.ErrMediaConvertFailed, err)
+			return fmt.Errorf("failed to stat file: %w", err)
 		}
-		fileName += ".m4a"
-		content.Info.MimeType = "audio/mp4"
-	}
-	resp, err := gc.Client.UploadMedia(data, fileName, content.Info.MimeType)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", bridgev2.ErrMediaReuploadFailed, err)
+		resp, err = gc.Client.UploadMediaStream(ctx, file, stat.Size(), fileName, content.Info.MimeType)
+		if err != nil {
+			return fmt.Errorf("%w: %w", bridgev2.ErrMediaReuploadFailed, err)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, bridgev2.ErrMediaReuploadFailed) && !errors.Is(err, bridgev2.ErrMediaConvertFailed) {
+		err = fmt.Errorf("%w: %w", bridgev2.ErrMediaDownloadFailed, err)
 	}
-	return resp, nil
+	return
 }
 
 var ErrNonSuccessResponse = bridgev2.WrapErrorInStatus(errors.New("got non-success response")).WithErrorAsMessage().WithSendNotice(true)
@@ -202,7 +263,7 @@ func (gc *GMClient) HandleMatrixMessageRemove(ctx context.Context, msg *bridgev2
 	if err != nil {
 		return err
 	}
-	resp, err := gc.Client.DeleteMessage(msgID)
+	resp, err := gc.Client.DeleteMessage(ctx, msgID)
 	if err != nil {
 		return err
 	} else if !resp.Success {
@@ -230,7 +291,7 @@ func (gc *GMClient) HandleMatrixReaction(ctx context.Context, msg *bridgev2.Matr
 	if err != nil {
 		return nil, err
 	}
-	resp, err := gc.Client.SendReaction(&gmproto.SendReactionRequest{
+	resp, err := gc.Client.SendReaction(ctx, &gmproto.SendReactionRequest{
 		MessageID:    msgID,
 		ReactionData: gmproto.MakeReactionData(msg.PreHandleResp.Emoji),
 		Action:       action,
@@ -252,7 +313,7 @@ func (gc *GMClient) HandleMatrixReactionRemove(ctx context.Context, msg *bridgev
 	if err != nil {
 		return err
 	}
-	resp, err := gc.Client.SendReaction(&gmproto.SendReactionRequest{
+	resp, err := gc.Client.SendReaction(ctx, &gmproto.SendReactionRequest{
 		MessageID:    msgID,
 		ReactionData: gmproto.MakeReactionData(msg.TargetReaction.Emoji),
 		Action:       gmproto.SendReactionRequest_REMOVE,
@@ -288,21 +349,54 @@ func (gc *GMClient) HandleMatrixReadReceipt(ctx context.Context, msg *bridgev2.M
 	if err != nil {
 		return err
 	}
-	return gc.Client.MarkRead(convID, msgID)
+	return gc.Client.MarkRead(ctx, convID, msgID)
 }
 
+// typingRefreshInterval is how often the typing notification is resent to the phone while the user keeps typing.
+const typingRefreshInterval = 10 * time.Second
+
 func (gc *GMClient) HandleMatrixTyping(ctx context.Context, msg *bridgev2.MatrixTyping) error {
-	if !msg.IsTyping {
-		return nil
-	}
 	if gc.Client == nil {
 		return bridgev2.ErrNotLoggedIn
+	} else if !gc.canSendTyping() {
+		return nil
 	}
 	convID, err := gc.ParsePortalID(msg.Portal.ID)
 	if err != nil {
 		return err
 	}
-	return gc.Client.SetTyping(convID, gc.GetSIM(msg.Portal).GetSIMData().GetSIMPayload())
+	return gc.setTyping(ctx, msg.Portal, convID, msg.IsTyping)
+}
+
+func (gc *GMClient) setTyping(ctx context.Context, portal *bridgev2.Portal, convID string, typing bool) error {
+	if !gc.updateTypingState(convID, typing) {
+		return nil
+	}
+	err := gc.Client.SetTyping(ctx, convID, typing, gc.GetSIM(portal).GetSIMData().GetSIMPayload())
+	if err != nil && typing {
+		// Allow the next typing event to retry immediately
+		gc.updateTypingState(convID, false)
+	}
+	return err
+}
+
+// updateTypingState stores the typing state of a conversation and returns whether the change should be sent to the phone.
+// Repeated typing events are only sent once per typing refresh interval, and stopping is only sent if typing was started.
+func (gc *GMClient) updateTypingState(convID string, typing bool) bool {
+	gc.typingLock.Lock()
+	defer gc.typingLock.Unlock()
+	sentAt, ok := gc.typingSentAt[convID]
+	if typing {
+		if ok && time.Since(sentAt) < typingRefreshInterval {
+			return false
+		}
+		gc.typingSentAt[convID] = time.Now()
+		return true
+	} else if !ok {
+		return false
+	}
+	delete(gc.typingSentAt, convID)
+	return true
 }
 
 func (gc *GMClient) HandleMatrixDeleteChat(ctx context.Context, chat *bridgev2.MatrixDeleteChat) error {
@@ -325,7 +419,7 @@ func (gc *GMClient) HandleMatrixDeleteChat(ctx context.Context, chat *bridgev2.M
 		phone = ghost.Metadata.(*GhostMetadata).Phone
 		if phone == "" {
 			// Fallback: fetch conversation from Google to get phone number
-			if conv, err := gc.Client.GetConversation(convID); err != nil {
+			if conv, err := gc.Client.GetConversation(ctx, convID); err != nil {
 				return fmt.Errorf("failed to get conversation for phone number: %w", err)
 			} else if conv != nil {
 				for _, pcp := range conv.Participants {
@@ -340,8 +434,172 @@ func (gc *GMClient) HandleMatrixDeleteChat(ctx context.Context, chat *bridgev2.M
 			return fmt.Errorf("phone number not available for conversation %s", convID)
 		}
 	}
-	if err := gc.Client.DeleteConversation(convID, phone); err != nil {
+	if err := gc.Client.DeleteConversation(ctx, convID, phone); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (gc *GMClient) HandleMute(ctx context.Context, msg *bridgev2.MatrixMute) error {
+	if gc.Client == nil {
+		return bridgev2.ErrNotLoggedIn
+	}
+	convID, err := gc.ParsePortalID(msg.Portal.ID)
+	if err != nil {
 		return err
 	}
+	// The phone only supports muting indefinitely, so timed mutes are bridged as permanent ones
+	return gc.Client.SetConversationMuted(ctx, convID, msg.Content.IsMuted())
+}
+
+// HandleRoomTag archives or unarchives the conversation when the low priority tag is changed.
+// Other tags, including favourites, aren't bridged, as the action for pinning chats isn't known.
+func (gc *GMClient) HandleRoomTag(ctx context.Context, msg *bridgev2.MatrixRoomTag) error {
+	if gc.Client == nil {
+		return bridgev2.ErrNotLoggedIn
+	}
+	convID, err := gc.ParsePortalID(msg.Portal.ID)
+	if err != nil {
+		return err
+	}
+	conv, ok := gc.chatInfoCache.Get(convID)
+	if !ok && msg.PrevContent == nil {
+		// Without the previous tags, the current status is needed to know whether anything changed
+		conv, err = gc.Client.GetConversation(ctx, convID)
+		if err != nil {
+			return fmt.Errorf("failed to get conversation to check status: %w", err)
+		}
+		gc.chatInfoCache.Set(convID, conv)
+	}
+	newStatus, ok := roomTagStatusChange(conv, msg.Content, msg.PrevContent)
+	if !ok {
+		return nil
+	}
+	zerolog.Ctx(ctx).Debug().Stringer("new_status", newStatus).Msg("Updating conversation status after room tag change")
+	return gc.Client.SetConversationStatus(ctx, convID, newStatus)
+}
+
+// roomTagStatusChange returns the status that the conversation should be changed to after a room tag change.
+// The cached conversation may be nil if it's not known, in which case nothing is changed without the previous tags.
+func roomTagStatusChange(conv *gmproto.Conversation, content, prevContent *event.TagEventContent) (gmproto.ConversationStatus, bool) {
+	_, lowPriority := content.Tags[event.RoomTagLowPriority]
+	var prevLowPriority bool
+	if prevContent != nil {
+		_, prevLowPriority = prevContent.Tags[event.RoomTagLowPriority]
+	} else if conv != nil {
+		prevLowPriority = conversationTag(conv) == event.RoomTagLowPriority
+	} else {
+		return 0, false
+	}
+	if lowPriority == prevLowPriority {
+		return 0, false
+	}
+	// Tags set by the bridge itself come back here too, so only send the change if the phone isn't already in that state.
+	// Conversations in other folders (e.g. spam) are never archived or unarchived from Matrix.
+	if lowPriority && (conv == nil || conv.GetStatus() == gmproto.ConversationStatus_ACTIVE) {
+		return gmproto.ConversationStatus_ARCHIVED, true
+	} else if !lowPriority && (conv == nil || isArchived(conv)) {
+		return gmproto.ConversationStatus_ACTIVE, true
+	}
+	return 0, false
+}
+
+// HandleMarkedUnread only supports removing the marked unread flag, which is done by reading the conversation.
+// Marking chats as unread from Matrix is rejected, as the action for it isn't known,
+// which is also why the mark_as_unread room feature isn't advertised.
+func (gc *GMClient) HandleMarkedUnread(ctx context.Context, msg *bridgev2.MatrixMarkedUnread) error {
+	if gc.Client == nil {
+		return bridgev2.ErrNotLoggedIn
+	}
+	convID, err := gc.ParsePortalID(msg.Portal.ID)
+	if err != nil {
+		return err
+	}
+	if msg.Content.Unread {
+		// The mark unread action hasn't been found in the web app yet
+		return ErrMarkUnreadNotSupported
+	}
+	gc.conversationMetaLock.Lock()
+	meta, ok := gc.conversationMeta[convID]
+	markedUnread := ok && meta.markedUnread
+	gc.conversationMetaLock.Unlock()
+	if !markedUnread {
+		return nil
+	}
+	// Reading the conversation is what clears the marked unread flag on the phone
+	lastMessage, err := msg.Portal.Bridge.DB.Message.GetLastPartAtOrBeforeTime(ctx, msg.Portal.PortalKey, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get last message: %w", err)
+	} else if lastMessage == nil {
+		return nil
+	}
+	msgID, err := gc.ParseMessageID(lastMessage.ID)
+	if err != nil {
+		return err
+	}
+	zerolog.Ctx(ctx).Debug().Str("message_id", msgID).Msg("Marking conversation as read after marked unread flag was removed")
+	return gc.Client.MarkRead(ctx, convID, msgID)
+}
+
+func (gc *GMClient) HandleMatrixMembership(ctx context.Context, msg *bridgev2.MatrixMembershipChange) (*bridgev2.MatrixMembershipResult, error) {
+	if !gc.Main.Config.RCSGroupMembership {
+		return nil, bridgev2.ErrMembershipNotSupported
+	} else if gc.Client == nil {
+		return nil, bridgev2.ErrNotLoggedIn
+	}
+	if msg.Portal.RoomType == database.RoomTypeDM || msg.Portal.Metadata.(*PortalMetadata).Type != gmproto.ConversationType_RCS {
+		return nil, ErrMembershipRequiresRCSGroup
+	}
+	convID, err := gc.ParsePortalID(msg.Portal.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch msg.Type {
+	case bridgev2.Invite:
+		ghost, ok := msg.Target.(*bridgev2.Ghost)
+		if !ok {
+			return nil, bridgev2.ErrMembershipNotSupported
+		}
+		return nil, gc.addRCSGroupParticipant(ctx, convID, ghost)
+	case bridgev2.Leave:
+		resp, err := gc.Client.LeaveRCSGroup(ctx, convID)
+		if err != nil {
+			return nil, err
+		} else if !resp.GetSuccess() {
+			return nil, fmt.Errorf("phone refused to leave group")
+		}
+		return nil, nil
+	default:
+		return nil, bridgev2.ErrMembershipNotSupported
+	}
+}
+
+func (gc *GMClient) addRCSGroupParticipant(ctx context.Context, convID string, ghost *bridgev2.Ghost) error {
+	phone := ghost.Metadata.(*GhostMetadata).Phone
+	if phone == "" {
+		return fmt.Errorf("phone number of ghost %s not known", ghost.ID)
+	}
+	number := &gmproto.ContactNumber{
+		MysteriousInt: 2,
+		Number:        phone,
+		Number2:       phone,
+	}
+	// Adding a contact without RCS would fail on the phone, so check first to give a better error
+	status, err := gc.Client.GetContactRCSGroupStatus(ctx, number)
+	if err != nil {
+		return fmt.Errorf("failed to check RCS status of contact: %w", err)
+	} else if !status.GetRCSGroupCapable() {
+		return ErrContactNotRCSCapable
+	}
+	resp, err := gc.Client.AddParticipantsToRCSGroup(ctx, convID, number)
+	if err != nil {
+		return err
+	} else if !resp.GetSuccess() {
+		return fmt.Errorf("phone refused to add participant")
+	}
+	// The phone also sends a conversation event with the new participant list, which will make the ghost join
+	zerolog.Ctx(ctx).Debug().
+		Str("ghost_id", string(ghost.ID)).
+		Msg("Added participant to RCS group")
 	return nil
 }
@@ -0,0 +1,91 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/database"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+func TestResendMessageStatus(t *testing.T) {
+	gc := &GMClient{}
+	for _, tc := range []struct {
+		name string
+		meta *MessageMetadata
+		err  error
+	}{
+		{"Incoming", &MessageMetadata{Type: gmproto.MessageStatusType_INCOMING_COMPLETE}, ErrMessageNotRetryable},
+		{"Sent", &MessageMetadata{IsOutgoing: true, Type: gmproto.MessageStatusType_OUTGOING_COMPLETE}, ErrMessageNotRetryable},
+		{"TooLarge", &MessageMetadata{IsOutgoing: true, Type: gmproto.MessageStatusType_OUTGOING_FAILED_TOO_LARGE}, ErrMessageNotRetryable},
+		// Retryable messages get past the status check and only fail because there's no libgm client
+		{"FailedGeneric", &MessageMetadata{IsOutgoing: true, Type: gmproto.MessageStatusType_OUTGOING_FAILED_GENERIC}, bridgev2.ErrNotLoggedIn},
+		{"AwaitingRetry", &MessageMetadata{IsOutgoing: true, Type: gmproto.MessageStatusType_OUTGOING_AWAITING_RETRY}, bridgev2.ErrNotLoggedIn},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := gc.resendMessage(context.Background(), &database.Message{ID: "1", Metadata: tc.meta})
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestRoomTagStatusChange(t *testing.T) {
+	lowPriority := &event.TagEventContent{Tags: map[event.RoomTag]event.TagMetadata{event.RoomTagLowPriority: {}}}
+	favourite := &event.TagEventContent{Tags: map[event.RoomTag]event.TagMetadata{event.RoomTagFavourite: {}}}
+	noTags := &event.TagEventContent{}
+	active := &gmproto.Conversation{Status: gmproto.ConversationStatus_ACTIVE}
+	archived := &gmproto.Conversation{Status: gmproto.ConversationStatus_ARCHIVED}
+	spam := &gmproto.Conversation{Status: gmproto.ConversationStatus_SPAM_FOLDER}
+	for _, tc := range []struct {
+		name    string
+		conv    *gmproto.Conversation
+		content *event.TagEventContent
+		prev    *event.TagEventContent
+		status  gmproto.ConversationStatus
+		changed bool
+	}{
+		{"Archive", active, lowPriority, noTags, gmproto.ConversationStatus_ARCHIVED, true},
+		{"Unarchive", archived, noTags, lowPriority, gmproto.ConversationStatus_ACTIVE, true},
+		{"ArchiveUnknownConversation", nil, lowPriority, noTags, gmproto.ConversationStatus_ARCHIVED, true},
+		{"UnarchiveUnknownConversation", nil, noTags, lowPriority, gmproto.ConversationStatus_ACTIVE, true},
+		// Tag events for unknown conversations without the previous tags can't be compared to anything
+		{"ArchiveUnknownWithoutPrevContent", nil, lowPriority, nil, 0, false},
+		{"UnarchiveUnknownWithoutPrevContent", nil, noTags, nil, 0, false},
+		// The bridge tagging the room after the conversation was archived on the phone shouldn't be sent back
+		{"EchoArchive", archived, lowPriority, noTags, 0, false},
+		{"EchoUnarchive", active, noTags, lowPriority, 0, false},
+		{"EchoWithoutPrevContent", archived, lowPriority, nil, 0, false},
+		{"Unchanged", active, lowPriority, lowPriority, 0, false},
+		{"Favourite", active, favourite, noTags, 0, false},
+		{"Spam", spam, lowPriority, noTags, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, changed := roomTagStatusChange(tc.conv, tc.content, tc.prev)
+			assert.Equal(t, tc.changed, changed)
+			assert.Equal(t, tc.status, status)
+		})
+	}
+}
+
+func TestUpdateTypingState(t *testing.T) {
+	gc := &GMClient{conversationMeta: make(map[string]*conversationMeta), typingSentAt: make(map[string]time.Time)}
+	assert.False(t, gc.updateTypingState("1", false), "stopping without starting shouldn't be sent")
+	assert.True(t, gc.updateTypingState("1", true))
+	assert.False(t, gc.updateTypingState("1", true), "repeated typing should be throttled")
+	assert.True(t, gc.updateTypingState("2", true), "other conversations shouldn't be throttled")
+
+	// Pretend the refresh interval has passed
+	gc.typingSentAt["1"] = time.Now().Add(-typingRefreshInterval)
+	assert.True(t, gc.updateTypingState("1", true))
+	assert.False(t, gc.updateTypingState("1", true))
+
+	assert.True(t, gc.updateTypingState("1", false))
+	assert.False(t, gc.updateTypingState("1", false), "stopping twice shouldn't be sent")
+	assert.True(t, gc.updateTypingState("1", true), "typing after stopping shouldn't be throttled")
+	assert.Empty(t, gc.conversationMeta, "typing shouldn't create conversation metadata")
+}
@@ -114,6 +114,7 @@ var _ bridgev2.LoginProcessDisplayAndWait = (*QRLoginProcess)(nil)
 func (ql *QRLoginProcess) Start(ctx context.Context) (*bridgev2.LoginStep, error) {
 	ql.PairSuccess = make(chan *gmproto.PairedData)
 	ql.Client = libgm.NewClient(libgm.NewAuthData(), nil, ql.User.Log.With().Str("component", "libgm").Str("parent_action", "qr pair").Logger())
+	ql.Client.SetEndpoints(ql.Main.Config.Endpoints.Endpoints())
 	ql.Client.SetEventHandler(func(evt any) {
 		ql.Client.Logger.Warn().Type("event_type", evt).Msg("Unexpected pre-pairing event")
 	})
@@ -125,7 +126,7 @@ func (ql *QRLoginProcess) Start(ctx context.Context) (*bridgev2.LoginStep, error
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrPairStartUnknown, err)
 	}
-	qr, err := ql.Client.StartLogin()
+	qr, err := ql.Client.StartLogin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrPairStartUnknown, err)
 	}
@@ -160,7 +161,7 @@ func (ql *QRLoginProcess) Wait(ctx context.Context) (*bridgev2.LoginStep, error)
 			ql.Client.Disconnect()
 			return nil, ErrPairQRTimeout
 		}
-		newQR, err := ql.Client.RefreshPhoneRelay()
+		newQR, err := ql.Client.RefreshPhoneRelay(ctx)
 		if err != nil {
 			ql.Client.Disconnect()
 			return nil, fmt.Errorf("%w: %w", ErrPairQRRefreshUnknown, err)
@@ -291,6 +292,7 @@ func (gl *GoogleLoginProcess) SubmitCookies(ctx context.Context, cookies map[str
 	ad := libgm.NewAuthData()
 	ad.Cookies = cookies
 	gl.Client = libgm.NewClient(ad, nil, gl.User.Log.With().Str("component", "libgm").Str("parent_action", "google pair").Logger())
+	gl.Client.SetEndpoints(gl.Main.Config.Endpoints.Endpoints())
 	gl.Client.SetEventHandler(func(evt any) {
 		gl.Client.Logger.Warn().Type("event_type", evt).Msg("Unexpected pre-pairing event")
 	})
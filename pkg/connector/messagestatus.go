@@ -34,6 +34,16 @@ func isSuccessfullySentStatus(status gmproto.MessageStatusType) bool {
 	}
 }
 
+// isRetryableStatus returns true if the message failed in a way that can be fixed by resending it.
+func isRetryableStatus(status gmproto.MessageStatusType) bool {
+	switch status {
+	case gmproto.MessageStatusType_OUTGOING_FAILED_GENERIC, gmproto.MessageStatusType_OUTGOING_AWAITING_RETRY:
+		return true
+	default:
+		return false
+	}
+}
+
 func getFailMessage(status gmproto.MessageStatusType) string {
 	switch status {
 	case gmproto.MessageStatusType_OUTGOING_FAILED_TOO_LARGE:
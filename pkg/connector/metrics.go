@@ -0,0 +1,169 @@
+// mautrix-gmessages - A Matrix-Google Messages puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package connector
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+}
+
+// PrometheusMetrics implements [libgm.Metrics] using Prometheus collectors.
+// A single instance is shared by all logins.
+type PrometheusMetrics struct {
+	rpcLatency         *prometheus.HistogramVec
+	rpcTimeouts        *prometheus.CounterVec
+	longPollReconnects *prometheus.CounterVec
+	pingRTT            prometheus.Histogram
+	pingFailures       *prometheus.CounterVec
+	ackBatchSize       prometheus.Histogram
+	ackFailures        prometheus.Counter
+	dedupHits          prometheus.Counter
+	tokenRefreshes     *prometheus.CounterVec
+}
+
+var _ libgm.Metrics = (*PrometheusMetrics)(nil)
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		rpcLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gmessages_rpc_response_seconds",
+			Help:    "Time from sending a request to the phone until receiving its response",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"action"}),
+		rpcTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gmessages_rpc_timeouts_total",
+			Help: "Number of requests that were abandoned before the phone responded",
+		}, []string{"action"}),
+		longPollReconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gmessages_long_poll_reconnects_total",
+			Help: "Number of times the long-polling connection was reopened",
+		}, []string{"reason"}),
+		pingRTT: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gmessages_ditto_ping_rtt_seconds",
+			Help:    "Round-trip time of ditto pings to the phone",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}),
+		pingFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gmessages_ditto_ping_failures_total",
+			Help: "Number of ditto pings that failed to send or timed out",
+		}, []string{"type"}),
+		ackBatchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gmessages_ack_batch_size",
+			Help:    "Number of message IDs in each ack request",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250},
+		}),
+		ackFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gmessages_ack_failures_total",
+			Help: "Number of ack requests that failed",
+		}),
+		dedupHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gmessages_dedup_hits_total",
+			Help: "Number of updates dropped as duplicates",
+		}),
+		tokenRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gmessages_token_refreshes_total",
+			Help: "Number of tachyon auth token refresh attempts",
+		}, []string{"result"}),
+	}
+}
+
+// Register registers all collectors in the given registerer.
+func (pm *PrometheusMetrics) Register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(pm.rpcLatency),
+		reg.Register(pm.rpcTimeouts),
+		reg.Register(pm.longPollReconnects),
+		reg.Register(pm.pingRTT),
+		reg.Register(pm.pingFailures),
+		reg.Register(pm.ackBatchSize),
+		reg.Register(pm.ackFailures),
+		reg.Register(pm.dedupHits),
+		reg.Register(pm.tokenRefreshes),
+	)
+}
+
+func (pm *PrometheusMetrics) RPCResponse(action gmproto.ActionType, duration time.Duration) {
+	pm.rpcLatency.WithLabelValues(action.String()).Observe(duration.Seconds())
+}
+
+func (pm *PrometheusMetrics) RPCTimeout(action gmproto.ActionType) {
+	pm.rpcTimeouts.WithLabelValues(action.String()).Inc()
+}
+
+func (pm *PrometheusMetrics) LongPollReconnect(reason libgm.LongPollReconnectReason) {
+	pm.longPollReconnects.WithLabelValues(string(reason)).Inc()
+}
+
+func (pm *PrometheusMetrics) PingResponse(rtt time.Duration) {
+	pm.pingRTT.Observe(rtt.Seconds())
+}
+
+func (pm *PrometheusMetrics) PingFailure(timeout bool) {
+	if timeout {
+		pm.pingFailures.WithLabelValues("timeout").Inc()
+	} else {
+		pm.pingFailures.WithLabelValues("send_error").Inc()
+	}
+}
+
+func (pm *PrometheusMetrics) AckBatch(size int, err error) {
+	pm.ackBatchSize.Observe(float64(size))
+	if err != nil {
+		pm.ackFailures.Inc()
+	}
+}
+
+func (pm *PrometheusMetrics) DedupHit() {
+	pm.dedupHits.Inc()
+}
+
+func (pm *PrometheusMetrics) TokenRefresh(err error) {
+	if err != nil {
+		pm.tokenRefreshes.WithLabelValues("error").Inc()
+	} else {
+		pm.tokenRefreshes.WithLabelValues("success").Inc()
+	}
+}
+
+func (gc *GMConnector) startMetricsListener() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{
+		Addr:              gc.Config.Metrics.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		gc.br.Log.Info().Str("listen", srv.Addr).Msg("Starting metrics listener")
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			gc.br.Log.Err(err).Msg("Metrics listener failed")
+		}
+	}()
+}
@@ -109,7 +109,7 @@ func (gc *GMClient) ResolveIdentifier(ctx context.Context, identifier string, cr
 			UserID: networkid.UserID(phone),
 		}, nil
 	}
-	resp, err := gc.Client.GetOrCreateConversation(&gmproto.GetOrCreateConversationRequest{
+	resp, err := gc.Client.GetOrCreateConversation(ctx, &gmproto.GetOrCreateConversationRequest{
 		Numbers: []*gmproto.ContactNumber{{
 			// This should maybe sometimes be 7
 			MysteriousInt: 2,
@@ -209,13 +209,13 @@ func (gc *GMClient) CreateGroup(ctx context.Context, params *bridgev2.GroupCreat
 			Number2:       phone,
 		}
 	}
-	resp, err := gc.Client.GetOrCreateConversation(reqData)
+	resp, err := gc.Client.GetOrCreateConversation(ctx, reqData)
 	if resp.GetStatus() == gmproto.GetOrCreateConversationResponse_CREATE_RCS {
 		if reqData.RCSGroupName == nil {
 			reqData.RCSGroupName = ptr.Ptr("")
 		}
 		reqData.CreateRCSGroup = ptr.Ptr(true)
-		resp, err = gc.Client.GetOrCreateConversation(reqData)
+		resp, err = gc.Client.GetOrCreateConversation(ctx, reqData)
 	}
 	if err != nil {
 		return nil, err
@@ -265,12 +265,12 @@ func (gc *GMClient) CreateGroup(ctx context.Context, params *bridgev2.GroupCreat
 }
 
 func (gc *GMClient) GetContactList(ctx context.Context) ([]*bridgev2.ResolveIdentifierResponse, error) {
-	contacts, err := gc.Client.ListContacts()
+	contacts, err := gc.Client.ListAllContacts(ctx)
 	if err != nil {
 		return nil, err
 	}
-	resp := make([]*bridgev2.ResolveIdentifierResponse, len(contacts.Contacts))
-	for i, contact := range contacts.Contacts {
+	resp := make([]*bridgev2.ResolveIdentifierResponse, len(contacts))
+	for i, contact := range contacts {
 		userID := gc.MakeUserID(contact.GetParticipantID())
 		ghost, err := gc.Main.br.GetGhostByID(ctx, userID)
 		if err != nil {
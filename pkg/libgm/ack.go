@@ -0,0 +1,215 @@
+package libgm
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/util"
+)
+
+// AckStore persists the IDs of received messages that haven't been acknowledged to the server yet.
+//
+// Without a store, pending acks only live in memory, so a restart before the next ack request
+// makes the server redeliver everything that was received since the last successful ack.
+type AckStore interface {
+	// AddPendingAcks is called when a message is received, before it's handled.
+	AddPendingAcks(ctx context.Context, messageIDs []string) error
+	// RemovePendingAcks is called after acks for the given messages have been sent successfully.
+	RemovePendingAcks(ctx context.Context, messageIDs []string) error
+	// GetPendingAcks returns all stored message IDs. It's called once when connecting.
+	GetPendingAcks(ctx context.Context) ([]string, error)
+}
+
+const (
+	ackInterval        = 5 * time.Second
+	maxAckRetryBackoff = 5 * time.Minute
+	ackFlushTimeout    = 5 * time.Second
+)
+
+// SetAckStore sets the store used to persist pending acks across restarts. It must be called before connecting.
+func (c *Client) SetAckStore(store AckStore) {
+	c.sessionHandler.ackMapLock.Lock()
+	c.sessionHandler.ackStore = store
+	c.sessionHandler.ackStoreRead = false
+	c.sessionHandler.ackMapLock.Unlock()
+}
+
+// loadPendingAcks queues acks for messages that were received but not acked before the previous shutdown.
+// Messages that the server redelivers are then recognized as already queued.
+func (s *SessionHandler) loadPendingAcks(ctx context.Context) {
+	s.ackMapLock.Lock()
+	store := s.ackStore
+	if store == nil || s.ackStoreRead {
+		s.ackMapLock.Unlock()
+		return
+	}
+	s.ackStoreRead = true
+	s.ackMapLock.Unlock()
+	ids, err := store.GetPendingAcks(ctx)
+	if err != nil {
+		s.client.Logger.Err(err).Msg("Failed to load pending acks from store")
+		return
+	} else if len(ids) == 0 {
+		return
+	}
+	s.ackMapLock.Lock()
+	for _, id := range ids {
+		if !slices.Contains(s.ackMap, id) {
+			s.ackMap = append(s.ackMap, id)
+		}
+	}
+	s.ackMapLock.Unlock()
+	s.client.Logger.Debug().Int("count", len(ids)).Msg("Loaded pending acks from store")
+}
+
+// queueMessageAck queues an ack for the given message ID.
+// The return value is true if an ack for the message was already pending, i.e. the message was redelivered.
+func (s *SessionHandler) queueMessageAck(messageID string) (alreadyQueued bool) {
+	s.ackMapLock.Lock()
+	alreadyQueued = slices.Contains(s.ackMap, messageID)
+	store := s.ackStore
+	if !alreadyQueued {
+		s.ackMap = append(s.ackMap, messageID)
+	}
+	s.ackMapLock.Unlock()
+	if alreadyQueued {
+		s.client.Logger.Trace().Any("message_id", messageID).Msg("Ack for message was already queued")
+		return
+	}
+	s.client.Logger.Trace().Any("message_id", messageID).Msg("Queued ack for message")
+	if store != nil {
+		err := store.AddPendingAcks(s.client.Logger.WithContext(context.TODO()), []string{messageID})
+		if err != nil {
+			s.client.Logger.Err(err).Str("message_id", messageID).Msg("Failed to persist pending ack")
+		}
+	}
+	return
+}
+
+func (s *SessionHandler) startAckInterval() {
+	s.ackMapLock.Lock()
+	defer s.ackMapLock.Unlock()
+	if s.ackTicker != nil {
+		return
+	}
+	ticker := time.NewTicker(ackInterval)
+	ctx, cancel := context.WithCancel(s.client.Logger.WithContext(context.Background()))
+	done := make(chan struct{})
+	s.ackTicker = ticker
+	s.ackCancel = cancel
+	s.ackDone = done
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ticker.C:
+				_ = s.sendAckRequest(ctx, false)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *SessionHandler) stopAckInterval() {
+	s.ackMapLock.Lock()
+	if s.ackTicker == nil {
+		s.ackMapLock.Unlock()
+		return
+	}
+	s.ackTicker.Stop()
+	s.ackCancel()
+	done := s.ackDone
+	s.ackTicker = nil
+	s.ackCancel = nil
+	s.ackDone = nil
+	s.ackMapLock.Unlock()
+	// Wait for an in-flight ack request to finish requeueing its acks, so that they're included in the flush
+	<-done
+}
+
+// flushAcks stops the ack interval and sends all pending acks immediately, ignoring any retry backoff.
+func (s *SessionHandler) flushAcks() {
+	s.stopAckInterval()
+	if s.client.AuthData == nil || s.client.AuthData.Browser == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.client.Logger.WithContext(context.Background()), ackFlushTimeout)
+	defer cancel()
+	_ = s.sendAckRequest(ctx, true)
+}
+
+// sendAckRequest sends all queued acks to the server. If the request fails, the acks are queued again
+// and further non-forced attempts are delayed with exponential backoff.
+func (s *SessionHandler) sendAckRequest(ctx context.Context, force bool) error {
+	s.ackMapLock.Lock()
+	if !force && time.Now().Before(s.ackRetryAfter) {
+		s.ackMapLock.Unlock()
+		return nil
+	}
+	dataToAck := s.ackMap
+	s.ackMap = nil
+	store := s.ackStore
+	s.ackMapLock.Unlock()
+	if len(dataToAck) == 0 {
+		return nil
+	}
+	ackMessages := make([]*gmproto.AckMessageRequest_Message, len(dataToAck))
+	for i, reqID := range dataToAck {
+		ackMessages[i] = &gmproto.AckMessageRequest_Message{
+			RequestID: reqID,
+			Device:    s.client.AuthData.Browser,
+		}
+	}
+	payload := &gmproto.AckMessageRequest{
+		AuthData: &gmproto.AuthMessage{
+			RequestID:        uuid.NewString(),
+			TachyonAuthToken: s.client.AuthData.TachyonAuthToken,
+			Network:          s.client.AuthData.AuthNetwork(),
+			ConfigVersion:    util.ConfigMessage,
+		},
+		EmptyArr: &gmproto.EmptyArr{},
+		Acks:     ackMessages,
+	}
+	url := s.client.endpoints.AckMessagesURL(s.client.AuthData.HasCookies())
+	_, err := typedHTTPResponse[*gmproto.OutgoingRPCResponse](
+		s.client.makeProtobufHTTPRequest(ctx, url, payload, ContentTypePBLite),
+	)
+	s.client.metrics.AckBatch(len(dataToAck), err)
+	if err != nil {
+		s.ackMapLock.Lock()
+		for _, id := range s.ackMap {
+			if !slices.Contains(dataToAck, id) {
+				dataToAck = append(dataToAck, id)
+			}
+		}
+		s.ackMap = dataToAck
+		s.ackFailures++
+		backoff := min(ackInterval<<min(s.ackFailures, 10), maxAckRetryBackoff)
+		s.ackRetryAfter = time.Now().Add(backoff)
+		failures := s.ackFailures
+		s.ackMapLock.Unlock()
+		s.client.Logger.Err(err).
+			Strs("message_ids", dataToAck).
+			Int("failure_count", failures).
+			Stringer("retry_in", backoff).
+			Msg("Failed to send acks")
+		return err
+	}
+	s.ackMapLock.Lock()
+	s.ackFailures = 0
+	s.ackRetryAfter = time.Time{}
+	s.ackMapLock.Unlock()
+	s.client.Logger.Trace().Strs("message_ids", dataToAck).Msg("Sent acks")
+	if store != nil {
+		err = store.RemovePendingAcks(ctx, dataToAck)
+		if err != nil {
+			s.client.Logger.Err(err).Strs("message_ids", dataToAck).Msg("Failed to remove sent acks from store")
+		}
+	}
+	return nil
+}
@@ -113,12 +113,16 @@ type updateDedupItem struct {
 
 type Client struct {
 	Logger         zerolog.Logger
-	evHandler      EventHandler
+	eventBus       eventBus
+	metrics        Metrics
+	recorder       *Recorder
 	sessionHandler *SessionHandler
+	connState      connectionTracker
+	connStateLock  sync.Mutex
 
 	longPollingConn io.Closer
 	listenID        int
-	skipCount       int
+	skipCount       atomic.Int32
 	disconnecting   bool
 
 	pingInterval             time.Duration
@@ -139,6 +143,7 @@ type Client struct {
 	PushKeys *PushKeys
 	Config   *gmproto.Config
 
+	endpoints     *util.Endpoints
 	httpTransport *http.Transport
 	http          *http.Client
 	lphttp        *http.Client
@@ -153,7 +158,7 @@ func NewAuthData() *AuthData {
 
 func NewClient(authData *AuthData, pk *PushKeys, logger zerolog.Logger) *Client {
 	sessionHandler := &SessionHandler{
-		responseWaiters: make(map[string]chan<- *IncomingRPCMessage),
+		responseWaiters: make(map[string]*responseWaiter),
 	}
 	transport := &http.Transport{
 		DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
@@ -165,7 +170,9 @@ func NewClient(authData *AuthData, pk *PushKeys, logger zerolog.Logger) *Client
 		PushKeys:       pk,
 		Logger:         logger,
 		sessionHandler: sessionHandler,
+		metrics:        NoopMetrics{},
 
+		endpoints:     util.DefaultEndpoints(),
 		httpTransport: transport,
 		http:          &http.Client{Transport: transport, Timeout: 2 * time.Minute},
 		lphttp:        &http.Client{Transport: transport, Timeout: 30 * time.Minute},
@@ -181,16 +188,22 @@ func (c *Client) CurrentSessionID() string {
 	return c.sessionHandler.sessionID
 }
 
-func (c *Client) SetEventHandler(eventHandler EventHandler) {
-	c.evHandler = eventHandler
-}
-
 func (c *Client) SetPingInterval(interval time.Duration) {
 	if interval >= 1*time.Minute && interval < 4*time.Hour {
 		c.pingInterval = interval
 	}
 }
 
+// SetEndpoints changes the URLs the client uses to talk to Google Messages.
+// This is meant for testing against a fake server or running behind a reverse proxy,
+// and must be called before connecting.
+func (c *Client) SetEndpoints(endpoints *util.Endpoints) {
+	if endpoints == nil {
+		endpoints = util.DefaultEndpoints()
+	}
+	c.endpoints = endpoints
+}
+
 func (c *Client) SetProxy(proxy string) error {
 	proxyParsed, err := url.Parse(proxy)
 	if err != nil {
@@ -208,11 +221,14 @@ func (c *Client) Connect() error {
 		return fmt.Errorf("not logged in")
 	}
 
-	err := c.refreshAuthToken(nil)
+	ctx := c.Logger.WithContext(context.TODO())
+	err := c.refreshAuthToken(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to refresh auth token: %w", err)
 	}
+	c.sessionHandler.loadPendingAcks(ctx)
 	c.bumpNextDataReceiveCheck(10 * time.Minute)
+	c.resetConnState(true)
 
 	//webEncryptionKeyResponse, err := c.GetWebEncryptionKey()
 	//if err != nil {
@@ -230,8 +246,10 @@ func (c *Client) ConnectBackground() error {
 	} else if c.AuthData.Browser == nil {
 		return fmt.Errorf("not logged in")
 	}
+	ctx := c.Logger.WithContext(context.TODO())
+	c.sessionHandler.loadPendingAcks(ctx)
 	cleanExit := c.doLongPoll(true, true, nil)
-	c.sessionHandler.sendAckRequest()
+	_ = c.sessionHandler.sendAckRequest(ctx, true)
 	if !cleanExit {
 		return fmt.Errorf("polling exited uncleanly")
 	}
@@ -239,22 +257,23 @@ func (c *Client) ConnectBackground() error {
 }
 
 func (c *Client) postConnect() {
+	ctx := c.Logger.WithContext(context.Background())
 	time.Sleep(2 * time.Second)
-	if c.skipCount > 0 {
-		c.Logger.Warn().Int("skip_count", c.skipCount).Msg("Skip count is non-zero in postConnect, waiting longer")
-		for i := 0; i < 3 && c.skipCount > 0; i++ {
+	if skipCount := c.skipCount.Load(); skipCount > 0 {
+		c.Logger.Warn().Int32("skip_count", skipCount).Msg("Skip count is non-zero in postConnect, waiting longer")
+		for i := 0; i < 3 && c.skipCount.Load() > 0; i++ {
 			time.Sleep(1 * time.Second)
 		}
-		if c.skipCount > 0 {
-			c.Logger.Warn().Int("skip_count", c.skipCount).Msg("Skip count is still non-zero")
+		if skipCount = c.skipCount.Load(); skipCount > 0 {
+			c.Logger.Warn().Int32("skip_count", skipCount).Msg("Skip count is still non-zero")
 		}
 		c.triggerEvent(&events.HackySetActiveMayFail{})
 	}
 	c.Logger.Debug().Msg("Sending acks before get updates request")
-	c.sessionHandler.sendAckRequest()
+	_ = c.sessionHandler.sendAckRequest(ctx, true)
 	time.Sleep(1 * time.Second)
 	c.Logger.Debug().Msg("Sending get updates request")
-	err := c.SetActiveSession()
+	err := c.SetActiveSession(ctx)
 	if err != nil {
 		c.Logger.Err(err).Msg("Failed to set active session")
 		c.triggerEvent(&events.PingFailed{
@@ -272,7 +291,7 @@ func (c *Client) postConnect() {
 		case <-doneChan:
 		}
 	}()
-	bugleRes, err := c.IsBugleDefault()
+	bugleRes, err := c.IsBugleDefault(ctx)
 	close(doneChan)
 	if err != nil {
 		c.Logger.Err(err).Msg("Failed to check bugle default")
@@ -282,13 +301,17 @@ func (c *Client) postConnect() {
 }
 
 func (c *Client) Disconnect() {
+	c.resetConnState(false)
 	c.closeLongPolling()
+	c.sessionHandler.flushAcks()
 	c.http.CloseIdleConnections()
 }
 
+// IsConnected returns true if the long-polling connection is currently open, regardless of whether the phone is reachable.
 func (c *Client) IsConnected() bool {
-	// TODO add better check (longPollingConn is set to nil while the polling reconnects)
-	return c.longPollingConn != nil
+	c.connStateLock.Lock()
+	defer c.connStateLock.Unlock()
+	return c.connState.running && !c.connState.loggedOut && c.connState.pollOpen
 }
 
 func (c *Client) IsLoggedIn() bool {
@@ -307,9 +330,7 @@ func (c *Client) Reconnect() error {
 }
 
 func (c *Client) triggerEvent(evt interface{}) {
-	if c.evHandler != nil {
-		c.evHandler(evt)
-	}
+	c.eventBus.dispatch(evt)
 }
 
 func (c *Client) FetchConfig(ctx context.Context) error {
@@ -328,7 +349,7 @@ func (c *Client) FetchConfig(ctx context.Context) error {
 }
 
 func (c *Client) fetchConfig(ctx context.Context) (*gmproto.Config, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, util.ConfigURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoints.ConfigURL(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
@@ -387,14 +408,14 @@ type PushKeys struct {
 	Auth   []byte
 }
 
-func (c *Client) RegisterPush(keys *PushKeys) error {
+func (c *Client) RegisterPush(ctx context.Context, keys *PushKeys) error {
 	if c.PushKeys == nil || c.PushKeys.URL != keys.URL {
-		err := c.refreshAuthToken(keys)
+		err := c.refreshAuthToken(ctx, keys)
 		if err != nil {
 			return fmt.Errorf("failed to refresh auth token: %w", err)
 		}
 	}
-	err := c.UpdateSettings(&gmproto.SettingsUpdateRequest{
+	err := c.UpdateSettings(ctx, &gmproto.SettingsUpdateRequest{
 		PushSettings: &gmproto.SettingsUpdateRequest_PushSettings{
 			Enabled: true,
 		},
@@ -406,7 +427,7 @@ func (c *Client) RegisterPush(keys *PushKeys) error {
 	return nil
 }
 
-func (c *Client) refreshAuthToken(pushKeyOverride *PushKeys) error {
+func (c *Client) refreshAuthToken(ctx context.Context, pushKeyOverride *PushKeys) error {
 	if c.AuthData.Browser == nil || (time.Until(c.AuthData.TachyonExpiry) > RefreshTachyonBuffer && pushKeyOverride == nil) {
 		return nil
 	}
@@ -460,16 +481,16 @@ func (c *Client) refreshAuthToken(pushKeyOverride *PushKeys) error {
 	}
 
 	resp, err := typedHTTPResponse[*gmproto.RegisterRefreshResponse](
-		c.makeProtobufHTTPRequest(util.RegisterRefreshURL, payload, ContentTypePBLite),
+		c.makeProtobufHTTPRequest(ctx, c.endpoints.RegisterRefreshURL(), payload, ContentTypePBLite),
 	)
+	if err == nil && resp.GetTokenData().GetTachyonAuthToken() == nil {
+		err = fmt.Errorf("no tachyon auth token in refresh response")
+	}
+	c.metrics.TokenRefresh(err)
 	if err != nil {
 		return err
 	}
 
-	if resp.GetTokenData().GetTachyonAuthToken() == nil {
-		return fmt.Errorf("no tachyon auth token in refresh response")
-	}
-
 	c.updateTachyonAuthToken(resp.GetTokenData())
 	c.triggerEvent(&events.AuthTokenRefreshed{})
 	return nil
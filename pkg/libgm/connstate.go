@@ -0,0 +1,120 @@
+package libgm
+
+import (
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/events"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+// connectionTracker keeps track of the individual conditions that make up the connection state.
+// The state itself is always derived from the conditions, so that e.g. the phone starting to respond
+// again while the long polling is reconnecting doesn't make the client look connected.
+type connectionTracker struct {
+	running          bool
+	loggedOut        bool
+	pollOpen         bool
+	phoneUnreachable bool
+	sessionActive    bool
+	inactiveAlert    gmproto.AlertType
+	pollErr          error
+	fatalErr         error
+
+	state events.ConnectionState
+}
+
+func (ct *connectionTracker) compute() events.ConnectionStatus {
+	switch {
+	case !ct.running:
+		return events.ConnectionStatus{State: events.StateDisconnected, Error: ct.fatalErr}
+	case ct.loggedOut:
+		return events.ConnectionStatus{State: events.StateLoggedOut, Error: ct.fatalErr}
+	case ct.inactiveAlert != gmproto.AlertType_ALERT_TYPE_UNKNOWN:
+		return events.ConnectionStatus{State: events.StateBrowserInactive, InactiveAlert: ct.inactiveAlert}
+	case !ct.pollOpen:
+		return events.ConnectionStatus{State: events.StateConnecting, Error: ct.pollErr}
+	case ct.phoneUnreachable:
+		return events.ConnectionStatus{State: events.StatePhoneUnreachable}
+	case !ct.sessionActive:
+		return events.ConnectionStatus{State: events.StateConnecting}
+	default:
+		return events.ConnectionStatus{State: events.StateLongPollOpen}
+	}
+}
+
+// ConnectionState returns the current state of the connection.
+func (c *Client) ConnectionState() events.ConnectionState {
+	c.connStateLock.Lock()
+	defer c.connStateLock.Unlock()
+	return c.connState.state
+}
+
+// ConnectionStatus returns the current state of the connection along with the error or alert that caused it.
+func (c *Client) ConnectionStatus() events.ConnectionStatus {
+	c.connStateLock.Lock()
+	defer c.connStateLock.Unlock()
+	return c.connState.compute()
+}
+
+// updateConnState applies a change to the connection conditions and emits a [events.ConnectionStateChanged]
+// event if the derived state changed. Changes are ignored while the client isn't running, so that goroutines
+// of a stopped connection can't make it look alive again.
+func (c *Client) updateConnState(fn func(ct *connectionTracker)) {
+	c.connStateLock.Lock()
+	if !c.connState.running {
+		c.connStateLock.Unlock()
+		return
+	}
+	c.applyConnStateLocked(fn)
+}
+
+// resetConnState clears all connection conditions. It's used when (re)connecting and disconnecting.
+func (c *Client) resetConnState(running bool) {
+	c.connStateLock.Lock()
+	c.applyConnStateLocked(func(ct *connectionTracker) {
+		*ct = connectionTracker{running: running, state: ct.state}
+	})
+}
+
+func (c *Client) applyConnStateLocked(fn func(ct *connectionTracker)) {
+	prev := c.connState.state
+	fn(&c.connState)
+	status := c.connState.compute()
+	c.connState.state = status.State
+	c.connStateLock.Unlock()
+	if status.State != prev {
+		c.Logger.Debug().
+			Stringer("prev_state", prev).
+			Stringer("new_state", status.State).
+			AnErr("state_error", status.Error).
+			Msg("Connection state changed")
+		c.triggerEvent(&events.ConnectionStateChanged{ConnectionStatus: status, Previous: prev})
+	}
+}
+
+func (c *Client) setLoggedOut(err error) {
+	c.updateConnState(func(ct *connectionTracker) {
+		ct.loggedOut = true
+		ct.fatalErr = err
+	})
+}
+
+func (c *Client) setPhoneReachable(reachable bool) {
+	c.updateConnState(func(ct *connectionTracker) {
+		ct.phoneUnreachable = !reachable
+	})
+}
+
+func (c *Client) handleConnStateAlert(alert gmproto.AlertType) {
+	switch alert {
+	case gmproto.AlertType_BROWSER_ACTIVE:
+		c.updateConnState(func(ct *connectionTracker) {
+			ct.sessionActive = true
+			ct.inactiveAlert = gmproto.AlertType_ALERT_TYPE_UNKNOWN
+		})
+	case gmproto.AlertType_BROWSER_INACTIVE,
+		gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT,
+		gmproto.AlertType_BROWSER_INACTIVE_FROM_INACTIVITY:
+		c.updateConnState(func(ct *connectionTracker) {
+			ct.inactiveAlert = alert
+		})
+	}
+}
@@ -58,7 +58,8 @@ func (c *AESGCMHelper) decryptChunk(data []byte, aad []byte) ([]byte, error) {
 	return decrypted, nil
 }
 
-const outgoingRawChunkSize = 1 << 15
+const outgoingRawChunkSizeLog2 = 15
+const outgoingRawChunkSize = 1 << outgoingRawChunkSizeLog2
 
 func (c *AESGCMHelper) EncryptData(data []byte) ([]byte, error) {
 	chunkOverhead := c.gcm.NonceSize() + c.gcm.Overhead()
@@ -0,0 +1,48 @@
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/crypto"
+)
+
+func TestAESGCMStream(t *testing.T) {
+	helper, err := crypto.NewAESGCMHelper(crypto.GenerateKey(32))
+	require.NoError(t, err)
+	const chunkSize = 1<<15 - 28
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize, 200_000} {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+
+		encrypted, err := io.ReadAll(helper.EncryptStream(bytes.NewReader(data)))
+		require.NoError(t, err)
+		assert.Equal(t, helper.EncryptedSize(int64(size)), int64(len(encrypted)), "size %d", size)
+		decrypted, err := helper.DecryptData(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, data, decrypted, "size %d", size)
+
+		encrypted, err = helper.EncryptData(data)
+		require.NoError(t, err)
+		decrypted, err = io.ReadAll(helper.DecryptStream(bytes.NewReader(encrypted)))
+		require.NoError(t, err)
+		assert.Equal(t, len(data), len(decrypted), "size %d", size)
+		assert.True(t, bytes.Equal(data, decrypted), "size %d", size)
+	}
+}
+
+func TestAESGCMStreamTruncated(t *testing.T) {
+	helper, err := crypto.NewAESGCMHelper(crypto.GenerateKey(32))
+	require.NoError(t, err)
+	data := make([]byte, 100_000)
+	encrypted, err := helper.EncryptData(data)
+	require.NoError(t, err)
+	// Cut the data at a chunk boundary, so that only the last chunk flag can reveal the truncation
+	_, err = io.ReadAll(helper.DecryptStream(bytes.NewReader(encrypted[:2+2<<15])))
+	assert.Error(t, err)
+}
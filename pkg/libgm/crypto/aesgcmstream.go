@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// EncryptedSize returns the size of the output of EncryptData or EncryptStream for the given input size.
+func (c *AESGCMHelper) EncryptedSize(size int64) int64 {
+	chunkOverhead := int64(c.gcm.NonceSize() + c.gcm.Overhead())
+	chunkSize := int64(outgoingRawChunkSize) - chunkOverhead
+	chunkCount := (size + chunkSize - 1) / chunkSize
+	return 2 + size + chunkOverhead*chunkCount
+}
+
+// EncryptStream returns a reader that encrypts data from the given reader in the same format as EncryptData,
+// while only keeping one chunk in memory at a time.
+func (c *AESGCMHelper) EncryptStream(r io.Reader) io.Reader {
+	chunkSize := outgoingRawChunkSize - c.gcm.NonceSize() - c.gcm.Overhead()
+	return &chunkedReader{
+		src:       bufio.NewReader(r),
+		chunkSize: chunkSize,
+		header:    []byte{0, outgoingRawChunkSizeLog2},
+		process: func(chunk []byte, index uint32, isLast bool) ([]byte, error) {
+			return c.encryptChunk(chunk, c.calculateAAD(index, isLast)), nil
+		},
+	}
+}
+
+// DecryptStream returns a reader that decrypts data in the format produced by EncryptData from the given reader,
+// while only keeping one chunk in memory at a time.
+func (c *AESGCMHelper) DecryptStream(r io.Reader) io.Reader {
+	return &chunkedReader{
+		src: bufio.NewReader(r),
+		readHeader: func(header []byte) (int, error) {
+			if header[0] != 0 {
+				return 0, fmt.Errorf("invalid first-byte header signature (got=%o , expected=%o)", header[0], 0)
+			} else if header[1] > 24 {
+				return 0, fmt.Errorf("invalid chunk size exponent %d", header[1])
+			}
+			return 1 << header[1], nil
+		},
+		process: func(chunk []byte, index uint32, isLast bool) ([]byte, error) {
+			decrypted, err := c.decryptChunk(chunk, c.calculateAAD(index, isLast))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt chunk #%d: %w", index+1, err)
+			}
+			return decrypted, nil
+		},
+	}
+}
+
+// chunkedReader splits the source into chunks and runs each one through a function.
+// The source is buffered so that it can peek whether the current chunk is the last one.
+type chunkedReader struct {
+	src       *bufio.Reader
+	chunkSize int
+	// header is written before the first chunk when encrypting
+	header []byte
+	// readHeader parses the header and returns the chunk size when decrypting
+	readHeader func(header []byte) (int, error)
+	process    func(chunk []byte, index uint32, isLast bool) ([]byte, error)
+
+	started  bool
+	index    uint32
+	buf      []byte
+	out      []byte
+	finished bool
+	err      error
+}
+
+func (cr *chunkedReader) start() error {
+	cr.started = true
+	if cr.readHeader == nil {
+		cr.out = cr.header
+	} else {
+		header := make([]byte, 2)
+		_, err := io.ReadFull(cr.src, header)
+		if errors.Is(err, io.EOF) {
+			cr.finished = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+		cr.chunkSize, err = cr.readHeader(header)
+		if err != nil {
+			return err
+		}
+	}
+	cr.buf = make([]byte, cr.chunkSize)
+	return nil
+}
+
+func (cr *chunkedReader) nextChunk() error {
+	n, err := io.ReadFull(cr.src, cr.buf)
+	if errors.Is(err, io.EOF) {
+		cr.finished = true
+		return nil
+	} else if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+		cr.finished = true
+	} else if err != nil {
+		return err
+	} else if _, err = cr.src.Peek(1); errors.Is(err, io.EOF) {
+		err = nil
+		cr.finished = true
+	} else if err != nil {
+		return err
+	}
+	cr.out, err = cr.process(cr.buf[:n], cr.index, cr.finished)
+	cr.index++
+	return err
+}
+
+func (cr *chunkedReader) Read(p []byte) (n int, err error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if !cr.started {
+		cr.err = cr.start()
+	}
+	for len(cr.out) == 0 && !cr.finished && cr.err == nil {
+		cr.err = cr.nextChunk()
+	}
+	if len(cr.out) > 0 {
+		n = copy(p, cr.out)
+		cr.out = cr.out[n:]
+		return n, nil
+	} else if cr.err != nil {
+		return 0, cr.err
+	}
+	cr.err = io.EOF
+	return 0, io.EOF
+}
@@ -17,6 +17,9 @@ type IncomingRPCMessage struct {
 	*gmproto.IncomingRPCMessage
 
 	IsOld bool
+	// IsRedelivered is true if an ack for the message was still pending when it was received,
+	// i.e. the server sent it again because a previous ack didn't go through.
+	IsRedelivered bool
 
 	Pair *gmproto.RPCPairData
 	Gaia *gmproto.RPCGaiaData
@@ -27,23 +30,26 @@ type IncomingRPCMessage struct {
 }
 
 var responseType = map[gmproto.ActionType]proto.Message{
-	gmproto.ActionType_IS_BUGLE_DEFAULT:           &gmproto.IsBugleDefaultResponse{},
-	gmproto.ActionType_GET_UPDATES:                &gmproto.UpdateEvents{},
-	gmproto.ActionType_LIST_CONVERSATIONS:         &gmproto.ListConversationsResponse{},
-	gmproto.ActionType_NOTIFY_DITTO_ACTIVITY:      &gmproto.NotifyDittoActivityResponse{},
-	gmproto.ActionType_GET_CONVERSATION_TYPE:      &gmproto.GetConversationTypeResponse{},
-	gmproto.ActionType_GET_CONVERSATION:           &gmproto.GetConversationResponse{},
-	gmproto.ActionType_LIST_MESSAGES:              &gmproto.ListMessagesResponse{},
-	gmproto.ActionType_SEND_MESSAGE:               &gmproto.SendMessageResponse{},
-	gmproto.ActionType_SEND_REACTION:              &gmproto.SendReactionResponse{},
-	gmproto.ActionType_DELETE_MESSAGE:             &gmproto.DeleteMessageResponse{},
-	gmproto.ActionType_GET_PARTICIPANTS_THUMBNAIL: &gmproto.GetThumbnailResponse{},
-	gmproto.ActionType_GET_CONTACTS_THUMBNAIL:     &gmproto.GetThumbnailResponse{},
-	gmproto.ActionType_LIST_CONTACTS:              &gmproto.ListContactsResponse{},
-	gmproto.ActionType_LIST_TOP_CONTACTS:          &gmproto.ListTopContactsResponse{},
-	gmproto.ActionType_GET_OR_CREATE_CONVERSATION: &gmproto.GetOrCreateConversationResponse{},
-	gmproto.ActionType_UPDATE_CONVERSATION:        &gmproto.UpdateConversationResponse{},
-	gmproto.ActionType_GET_FULL_SIZE_IMAGE:        &gmproto.GetFullSizeImageResponse{},
+	gmproto.ActionType_IS_BUGLE_DEFAULT:             &gmproto.IsBugleDefaultResponse{},
+	gmproto.ActionType_GET_UPDATES:                  &gmproto.UpdateEvents{},
+	gmproto.ActionType_LIST_CONVERSATIONS:           &gmproto.ListConversationsResponse{},
+	gmproto.ActionType_NOTIFY_DITTO_ACTIVITY:        &gmproto.NotifyDittoActivityResponse{},
+	gmproto.ActionType_GET_CONVERSATION_TYPE:        &gmproto.GetConversationTypeResponse{},
+	gmproto.ActionType_GET_CONVERSATION:             &gmproto.GetConversationResponse{},
+	gmproto.ActionType_LIST_MESSAGES:                &gmproto.ListMessagesResponse{},
+	gmproto.ActionType_SEND_MESSAGE:                 &gmproto.SendMessageResponse{},
+	gmproto.ActionType_SEND_REACTION:                &gmproto.SendReactionResponse{},
+	gmproto.ActionType_DELETE_MESSAGE:               &gmproto.DeleteMessageResponse{},
+	gmproto.ActionType_GET_PARTICIPANTS_THUMBNAIL:   &gmproto.GetThumbnailResponse{},
+	gmproto.ActionType_GET_CONTACTS_THUMBNAIL:       &gmproto.GetThumbnailResponse{},
+	gmproto.ActionType_LIST_CONTACTS:                &gmproto.ListContactsResponse{},
+	gmproto.ActionType_LIST_TOP_CONTACTS:            &gmproto.ListTopContactsResponse{},
+	gmproto.ActionType_GET_OR_CREATE_CONVERSATION:   &gmproto.GetOrCreateConversationResponse{},
+	gmproto.ActionType_UPDATE_CONVERSATION:          &gmproto.UpdateConversationResponse{},
+	gmproto.ActionType_GET_FULL_SIZE_IMAGE:          &gmproto.GetFullSizeImageResponse{},
+	gmproto.ActionType_LEAVE_RCS_GROUP:              &gmproto.LeaveRCSGroupResponse{},
+	gmproto.ActionType_ADD_PARTICIPANT_TO_RCS_GROUP: &gmproto.AddParticipantToRCSGroupResponse{},
+	gmproto.ActionType_GET_CONTACT_RCS_GROUP_STATUS: &gmproto.GetContactRCSGroupStatusResponse{},
 }
 
 func (c *Client) decryptInternalMessage(data *gmproto.IncomingRPCMessage) (*IncomingRPCMessage, error) {
@@ -162,10 +168,12 @@ func (c *Client) deduplicateUpdate(id string, msg *IncomingRPCMessage) bool {
 	if msg.DecryptedData != nil {
 		contentHash := sha256.Sum256(msg.DecryptedData)
 		if c.deduplicateHash(id, contentHash) {
+			c.metrics.DedupHit()
 			c.Logger.Trace().
 				Str("thing_id", id).
 				Hex("data_hash", contentHash[:]).
 				Bool("is_old", msg.IsOld).
+				Bool("is_redelivered", msg.IsRedelivered).
 				Msg("Ignoring duplicate update")
 			return true
 		}
@@ -176,19 +184,23 @@ func (c *Client) deduplicateUpdate(id string, msg *IncomingRPCMessage) bool {
 
 func (c *Client) HandleRPCMsg(rawMsg *gmproto.IncomingRPCMessage) {
 	msg, err := c.decryptInternalMessage(rawMsg)
+	if c.recorder != nil {
+		c.recordIncoming(rawMsg, msg, err)
+	}
 	if err != nil {
 		c.Logger.Err(err).Str("message_id", rawMsg.ResponseID).Msg("Failed to decode incoming RPC message")
 		c.sessionHandler.queueMessageAck(rawMsg.ResponseID)
 		return
 	}
 
-	c.sessionHandler.queueMessageAck(msg.ResponseID)
+	msg.IsRedelivered = c.sessionHandler.queueMessageAck(msg.ResponseID)
 	if c.sessionHandler.receiveResponse(msg) {
 		return
 	}
 	logEvt := c.Logger.Debug().
 		Str("message_id", msg.ResponseID).
-		Stringer("bugle_route", msg.BugleRoute)
+		Stringer("bugle_route", msg.BugleRoute).
+		Bool("is_redelivered", msg.IsRedelivered)
 	if msg.Message != nil {
 		logEvt.Stringer("message_action", msg.Message.Action)
 	}
@@ -199,18 +211,41 @@ func (c *Client) HandleRPCMsg(rawMsg *gmproto.IncomingRPCMessage) {
 	case gmproto.BugleRoute_GaiaEvent:
 		c.handleGaiaPairingEvent(msg)
 	case gmproto.BugleRoute_DataEvent:
-		if c.skipCount > 0 {
-			c.skipCount--
+		if c.skipCount.Load() > 0 {
+			c.skipCount.Add(-1)
 			msg.IsOld = true
 		}
 		c.handleUpdatesEvent(msg)
 	}
 }
 
+func (c *Client) recordIncoming(rawMsg *gmproto.IncomingRPCMessage, msg *IncomingRPCMessage, decryptErr error) {
+	entry := &CaptureEntry{
+		Direction:  CaptureIncoming,
+		MessageID:  rawMsg.GetResponseID(),
+		BugleRoute: rawMsg.GetBugleRoute(),
+	}
+	var err error
+	entry.Raw, err = proto.Marshal(rawMsg)
+	if err != nil {
+		c.Logger.Err(err).Str("message_id", rawMsg.GetResponseID()).Msg("Failed to marshal message for capture")
+		return
+	}
+	if decryptErr != nil {
+		entry.Error = decryptErr.Error()
+	} else {
+		entry.RequestID = msg.Message.GetSessionID()
+		entry.Action = msg.Message.GetAction()
+		entry.Decrypted = msg.DecryptedData
+	}
+	c.recordCapture(entry)
+}
+
 type WrappedMessage struct {
 	*gmproto.Message
-	IsOld bool
-	Data  []byte
+	IsOld         bool
+	IsRedelivered bool
+	Data          []byte
 }
 
 var hackyLoggedOutBytes = []byte{0x72, 0x00}
@@ -219,6 +254,7 @@ func (c *Client) handleUpdatesEvent(msg *IncomingRPCMessage) {
 	switch msg.Message.Action {
 	case gmproto.ActionType_GET_UPDATES:
 		if msg.DecryptedData == nil && bytes.Equal(msg.Message.UnencryptedData, hackyLoggedOutBytes) {
+			c.setLoggedOut(events.ErrGaiaLoggedOut)
 			c.triggerEvent(&events.GaiaLoggedOut{})
 			return
 		}
@@ -237,10 +273,12 @@ func (c *Client) handleUpdatesEvent(msg *IncomingRPCMessage) {
 		switch evt := data.Event.(type) {
 		case *gmproto.UpdateEvents_UserAlertEvent:
 			c.logContent(msg, "", nil)
-			if msg.IsOld {
+			if msg.IsOld || msg.IsRedelivered {
 				return
 			}
 			c.triggerEvent(evt.UserAlertEvent)
+			// The state is updated after dispatching the alert, so that handlers can still see what the alert changed
+			c.handleConnStateAlert(evt.UserAlertEvent.GetAlertType())
 
 		case *gmproto.UpdateEvents_SettingsEvent:
 			c.Logger.Debug().
@@ -266,21 +304,28 @@ func (c *Client) handleUpdatesEvent(msg *IncomingRPCMessage) {
 					return
 				}
 				c.triggerEvent(&WrappedMessage{
-					Message: part,
-					IsOld:   msg.IsOld,
-					Data:    msg.DecryptedData,
+					Message:       part,
+					IsOld:         msg.IsOld,
+					IsRedelivered: msg.IsRedelivered,
+					Data:          msg.DecryptedData,
 				})
 			}
 
 		case *gmproto.UpdateEvents_TypingEvent:
 			c.logContent(msg, "", nil)
-			if msg.IsOld {
+			if msg.IsOld || msg.IsRedelivered {
 				return
 			}
 			c.triggerEvent(evt.TypingEvent.GetData())
 
 		case *gmproto.UpdateEvents_AccountChange:
 			c.logContent(msg, "", nil)
+			if !evt.AccountChange.GetEnabled() {
+				// Switching back to QR pairing doesn't send BROWSER_ACTIVE, so assume the session is active now
+				c.updateConnState(func(ct *connectionTracker) {
+					ct.sessionActive = true
+				})
+			}
 			c.triggerEvent(&events.AccountChange{
 				AccountChangeOrSomethingEvent: evt.AccountChange,
 			})
@@ -0,0 +1,124 @@
+package libgm
+
+import (
+	"slices"
+	"sync"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/events"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+type eventSubscriber struct {
+	id      uint64
+	handler EventHandler
+}
+
+type eventBus struct {
+	subscribers []eventSubscriber
+	nextID      uint64
+	// ID of the handler set with SetEventHandler, 0 if there isn't one
+	legacyID uint64
+	lock     sync.RWMutex
+}
+
+func (eb *eventBus) add(handler EventHandler) uint64 {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	return eb.addLocked(handler)
+}
+
+func (eb *eventBus) addLocked(handler EventHandler) uint64 {
+	eb.nextID++
+	// Copy on write so that dispatch can iterate over a snapshot without holding the lock
+	eb.subscribers = append(slices.Clip(eb.subscribers), eventSubscriber{id: eb.nextID, handler: handler})
+	return eb.nextID
+}
+
+func (eb *eventBus) remove(id uint64) {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	eb.removeLocked(id)
+}
+
+func (eb *eventBus) removeLocked(id uint64) {
+	idx := slices.IndexFunc(eb.subscribers, func(sub eventSubscriber) bool {
+		return sub.id == id
+	})
+	if idx >= 0 {
+		eb.subscribers = slices.Concat(eb.subscribers[:idx], eb.subscribers[idx+1:])
+	}
+}
+
+func (eb *eventBus) dispatch(evt any) {
+	eb.lock.RLock()
+	subscribers := eb.subscribers
+	eb.lock.RUnlock()
+	for _, sub := range subscribers {
+		sub.handler(evt)
+	}
+}
+
+// SetEventHandler sets a handler that receives all events. Calling it again replaces the previous handler,
+// and calling it with nil removes it. Handlers added with [Client.AddEventHandler] or the typed On* methods
+// are not affected.
+func (c *Client) SetEventHandler(eventHandler EventHandler) {
+	c.eventBus.lock.Lock()
+	defer c.eventBus.lock.Unlock()
+	if c.eventBus.legacyID != 0 {
+		c.eventBus.removeLocked(c.eventBus.legacyID)
+		c.eventBus.legacyID = 0
+	}
+	if eventHandler != nil {
+		c.eventBus.legacyID = c.eventBus.addLocked(eventHandler)
+	}
+}
+
+// AddEventHandler adds a handler that receives all events. Handlers are called synchronously
+// in the order they were added. The returned function removes the handler.
+func (c *Client) AddEventHandler(handler EventHandler) (unsubscribe func()) {
+	id := c.eventBus.add(handler)
+	return sync.OnceFunc(func() {
+		c.eventBus.remove(id)
+	})
+}
+
+// Subscribe adds a handler that only receives events of type T. T may also be an interface,
+// in which case the handler receives all events implementing it.
+// The returned function removes the handler.
+func Subscribe[T any](c *Client, handler func(T)) (unsubscribe func()) {
+	return c.AddEventHandler(func(evt any) {
+		if typedEvt, ok := evt.(T); ok {
+			handler(typedEvt)
+		}
+	})
+}
+
+// OnMessage subscribes to new and updated messages.
+func (c *Client) OnMessage(handler func(*WrappedMessage)) (unsubscribe func()) {
+	return Subscribe(c, handler)
+}
+
+// OnConversation subscribes to new and updated conversations.
+func (c *Client) OnConversation(handler func(*gmproto.Conversation)) (unsubscribe func()) {
+	return Subscribe(c, handler)
+}
+
+// OnTyping subscribes to typing notifications.
+func (c *Client) OnTyping(handler func(*gmproto.TypingData)) (unsubscribe func()) {
+	return Subscribe(c, handler)
+}
+
+// OnUserAlert subscribes to user alerts, like the browser becoming active or the phone's battery being low.
+func (c *Client) OnUserAlert(handler func(*gmproto.UserAlertEvent)) (unsubscribe func()) {
+	return Subscribe(c, handler)
+}
+
+// OnSettings subscribes to updates to the phone's settings, such as the list of SIMs.
+func (c *Client) OnSettings(handler func(*gmproto.Settings)) (unsubscribe func()) {
+	return Subscribe(c, handler)
+}
+
+// OnConnectionState subscribes to events about the state of the connection to the server and the phone.
+func (c *Client) OnConnectionState(handler func(events.ConnectionEvent)) (unsubscribe func()) {
+	return Subscribe(c, handler)
+}
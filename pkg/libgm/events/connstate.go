@@ -0,0 +1,70 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+// ConnectionState is the overall state of the connection to the Google Messages servers and the phone.
+type ConnectionState int
+
+const (
+	// StateDisconnected means the client isn't connected, either because Connect hasn't been called,
+	// Disconnect was called, or long polling stopped due to a fatal error.
+	StateDisconnected ConnectionState = iota
+	// StateConnecting means the long-polling connection isn't open yet (or failed and is being retried),
+	// or the phone hasn't confirmed that this session is active.
+	StateConnecting
+	// StateLongPollOpen means the long-polling connection is open and the phone is responding.
+	StateLongPollOpen
+	// StatePhoneUnreachable means the long-polling connection is open, but the phone isn't responding to pings.
+	StatePhoneUnreachable
+	// StateBrowserInactive means the phone has switched to another browser session.
+	StateBrowserInactive
+	// StateLoggedOut means the session was revoked or the credentials are no longer valid.
+	StateLoggedOut
+)
+
+func (cs ConnectionState) String() string {
+	switch cs {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateLongPollOpen:
+		return "long-poll-open"
+	case StatePhoneUnreachable:
+		return "phone-unreachable"
+	case StateBrowserInactive:
+		return "browser-inactive"
+	case StateLoggedOut:
+		return "logged-out"
+	default:
+		return fmt.Sprintf("ConnectionState(%d)", int(cs))
+	}
+}
+
+var (
+	// ErrPairingRevoked is the error of the StateLoggedOut status when the phone unpaired the browser.
+	ErrPairingRevoked = errors.New("pairing revoked by phone")
+	// ErrGaiaLoggedOut is the error of the StateLoggedOut status when the Google account session was logged out.
+	ErrGaiaLoggedOut = errors.New("google account logged out")
+)
+
+// ConnectionStatus is a snapshot of the client's connection state.
+type ConnectionStatus struct {
+	State ConnectionState
+	// Error is the most recent error that affects the state, e.g. the long-polling error while reconnecting
+	// or the error that caused the client to stop.
+	Error error
+	// InactiveAlert is the user alert that made the browser inactive, if the state is StateBrowserInactive.
+	InactiveAlert gmproto.AlertType
+}
+
+// ConnectionStateChanged is emitted whenever the client's connection state changes.
+type ConnectionStateChanged struct {
+	ConnectionStatus
+	Previous ConnectionState
+}
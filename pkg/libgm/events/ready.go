@@ -90,6 +90,25 @@ func (he HTTPError) Error() string {
 	return fmt.Sprintf("http %d while %s", he.Resp.StatusCode, he.Action)
 }
 
+// ErrRequestTimeout is matched by all [RequestTimeoutError]s when using errors.Is.
+var ErrRequestTimeout = errors.New("request to phone timed out")
+
+// RequestTimeoutError is returned when the context of a request to the phone is cancelled
+// or reaches its deadline before the phone responds.
+type RequestTimeoutError struct {
+	Action    gmproto.ActionType
+	RequestID string
+	Err       error
+}
+
+func (rte RequestTimeoutError) Unwrap() []error {
+	return []error{ErrRequestTimeout, rte.Err}
+}
+
+func (rte RequestTimeoutError) Error() string {
+	return fmt.Sprintf("%s request %s timed out: %v", rte.Action, rte.RequestID, rte.Err)
+}
+
 type ListenFatalError struct {
 	Error error
 }
@@ -110,3 +129,19 @@ type PingFailed struct {
 }
 
 type HackySetActiveMayFail struct{}
+
+// ConnectionEvent is implemented by all events that describe the state of the connection
+// to the Google Messages servers or the phone.
+type ConnectionEvent interface {
+	isConnectionEvent()
+}
+
+func (ListenFatalError) isConnectionEvent()       {}
+func (ListenTemporaryError) isConnectionEvent()   {}
+func (ListenRecovered) isConnectionEvent()        {}
+func (PhoneNotResponding) isConnectionEvent()     {}
+func (PhoneRespondingAgain) isConnectionEvent()   {}
+func (PingFailed) isConnectionEvent()             {}
+func (NoDataReceived) isConnectionEvent()         {}
+func (HackySetActiveMayFail) isConnectionEvent()  {}
+func (ConnectionStateChanged) isConnectionEvent() {}
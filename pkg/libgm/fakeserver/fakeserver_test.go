@@ -0,0 +1,827 @@
+package fakeserver_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/events"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/fakeserver"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+// testLogWriter writes logs to the test output, but drops them once the test has finished,
+// as background goroutines of disconnected clients may still log something while exiting.
+type testLogWriter struct {
+	zerolog.TestWriter
+	done atomic.Bool
+}
+
+func (tlw *testLogWriter) Write(p []byte) (int, error) {
+	if tlw.done.Load() {
+		return len(p), nil
+	}
+	return tlw.TestWriter.Write(p)
+}
+
+func newLogger(t *testing.T, component string) zerolog.Logger {
+	writer := &testLogWriter{TestWriter: zerolog.NewTestWriter(t)}
+	t.Cleanup(func() {
+		writer.done.Store(true)
+	})
+	return zerolog.New(writer).With().Str("component", component).Logger()
+}
+
+func newServer(t *testing.T) *fakeserver.Server {
+	srv := fakeserver.New(newLogger(t, "fakeserver"))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newPairedClient(t *testing.T, srv *fakeserver.Server) (*libgm.Client, <-chan any) {
+	authData := libgm.NewAuthData()
+	tokenData := fakeserver.NewTokenData()
+	authData.TachyonAuthToken = tokenData.TachyonAuthToken
+	authData.TachyonTTL = tokenData.TTL
+	authData.TachyonExpiry = time.Now().Add(fakeserver.DefaultTokenTTL)
+	authData.Mobile = srv.Phone.Device
+	authData.Browser = srv.Browser()
+	srv.Phone.SetKeys(authData.RequestCrypto)
+	return newClient(t, srv, authData)
+}
+
+func newClient(t *testing.T, srv *fakeserver.Server, authData *libgm.AuthData) (*libgm.Client, <-chan any) {
+	cli := libgm.NewClient(authData, nil, newLogger(t, "libgm"))
+	cli.SetEndpoints(srv.Endpoints())
+	evts := make(chan any, 64)
+	cli.SetEventHandler(func(evt any) {
+		evts <- evt
+	})
+	t.Cleanup(cli.Disconnect)
+	return cli, evts
+}
+
+// newConnectedClient adds the given conversations to the phone, then connects a paired client
+// and waits until it has requested updates from the phone.
+func newConnectedClient(t *testing.T, srv *fakeserver.Server, convs ...*gmproto.Conversation) (*libgm.Client, <-chan any) {
+	for _, conv := range convs {
+		srv.Phone.AddConversation(conv)
+	}
+	cli, evts := newPairedClient(t, srv)
+	connectClient(t, srv, cli)
+	return cli, evts
+}
+
+// connectClient connects the client and waits for the long poll to be open.
+func connectClient(t *testing.T, srv *fakeserver.Server, cli *libgm.Client) {
+	require.NoError(t, cli.Connect())
+	require.Eventually(t, func() bool {
+		return len(srv.Phone.RequestsOfType(gmproto.ActionType_GET_UPDATES)) > 0
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func waitForEvent[T any](t *testing.T, evts <-chan any) T {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case evt := <-evts:
+			if typed, ok := evt.(T); ok {
+				return typed
+			}
+		case <-timeout:
+			var zero T
+			t.Fatalf("Timed out waiting for %T event", zero)
+			return zero
+		}
+	}
+}
+
+func TestQRPairing(t *testing.T) {
+	srv := newServer(t)
+	cli := libgm.NewClient(libgm.NewAuthData(), nil, newLogger(t, "libgm"))
+	cli.SetEndpoints(srv.Endpoints())
+	t.Cleanup(cli.Disconnect)
+	paired := make(chan *gmproto.PairedData, 1)
+	callback := func(data *gmproto.PairedData) {
+		paired <- data
+	}
+	cli.PairCallback.Store(&callback)
+
+	require.NoError(t, cli.FetchConfig(context.Background()))
+	qr, err := cli.StartLogin(context.Background())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return srv.LongPollCount() > 0 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, srv.PairWithQR(qr))
+
+	select {
+	case data := <-paired:
+		assert.Equal(t, srv.Phone.Device.GetSourceID(), data.GetMobile().GetSourceID())
+		assert.Equal(t, srv.Phone.Device.GetSourceID(), cli.AuthData.Mobile.GetSourceID())
+		assert.NotEmpty(t, cli.AuthData.TachyonAuthToken)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for pairing")
+	}
+}
+
+func TestSendAndReceiveMessages(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{
+		ConversationID:       "1",
+		Name:                 "Alice",
+		Status:               gmproto.ConversationStatus_ACTIVE,
+		LastMessageTimestamp: time.Now().UnixMicro(),
+	})
+
+	convs, err := cli.ListConversations(ctx, 25, gmproto.ListConversationsRequest_INBOX)
+	require.NoError(t, err)
+	require.Len(t, convs.GetConversations(), 1)
+	assert.Equal(t, "Alice", convs.GetConversations()[0].GetName())
+
+	resp, err := cli.SendMessage(ctx, &gmproto.SendMessageRequest{
+		ConversationID: "1",
+		MessagePayload: &gmproto.MessagePayload{
+			MessageInfo: []*gmproto.MessageInfo{{
+				Data: &gmproto.MessageInfo_MessageContent{MessageContent: &gmproto.MessageContent{Content: "hello"}},
+			}},
+		},
+		TmpID: "tmp_123",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, gmproto.SendMessageResponse_SUCCESS, resp.GetStatus())
+	echo := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, "tmp_123", echo.GetTmpID())
+
+	srv.Phone.ReceiveMessage(&gmproto.Message{
+		ConversationID: "1",
+		ParticipantID:  "2",
+		MessageStatus:  &gmproto.MessageStatus{Status: gmproto.MessageStatusType_INCOMING_COMPLETE},
+		MessageInfo: []*gmproto.MessageInfo{{
+			Data: &gmproto.MessageInfo_MessageContent{MessageContent: &gmproto.MessageContent{Content: "hi there"}},
+		}},
+	})
+	incoming := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, "hi there", incoming.GetMessageInfo()[0].GetMessageContent().GetContent())
+
+	require.Eventually(t, func() bool { return len(srv.Acks()) >= 3 }, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestPaginateConversations(t *testing.T) {
+	srv := newServer(t)
+	for i := 1; i <= 5; i++ {
+		srv.Phone.AddConversation(&gmproto.Conversation{
+			ConversationID:       strconv.Itoa(i),
+			Status:               gmproto.ConversationStatus_ACTIVE,
+			LastMessageTimestamp: int64(i),
+		})
+	}
+	srv.Phone.AddConversation(&gmproto.Conversation{ConversationID: "6", Status: gmproto.ConversationStatus_ARCHIVED})
+	ctx := context.Background()
+	cli, _ := newConnectedClient(t, srv)
+
+	var ids []string
+	var cursors []*gmproto.Cursor
+	for page, err := range cli.PaginateConversations(ctx, gmproto.ListConversationsRequest_INBOX, 2, nil) {
+		require.NoError(t, err)
+		for _, conv := range page.GetConversations() {
+			ids = append(ids, conv.GetConversationID())
+		}
+		cursors = append(cursors, page.GetCursor())
+	}
+	assert.Equal(t, []string{"5", "4", "3", "2", "1"}, ids)
+	require.Len(t, cursors, 3)
+	assert.Nil(t, cursors[2])
+
+	ids = nil
+	for page, err := range cli.PaginateConversations(ctx, gmproto.ListConversationsRequest_INBOX, 2, cursors[0]) {
+		require.NoError(t, err)
+		for _, conv := range page.GetConversations() {
+			ids = append(ids, conv.GetConversationID())
+		}
+	}
+	assert.Equal(t, []string{"3", "2", "1"}, ids)
+
+	ids = nil
+	for page, err := range cli.PaginateConversations(ctx, gmproto.ListConversationsRequest_ARCHIVE, 2, nil) {
+		require.NoError(t, err)
+		for _, conv := range page.GetConversations() {
+			ids = append(ids, conv.GetConversationID())
+		}
+	}
+	assert.Equal(t, []string{"6"}, ids)
+}
+
+func TestListAllContacts(t *testing.T) {
+	srv := newServer(t)
+	for i := 1; i <= libgm.DefaultContactPageSize+10; i++ {
+		srv.Phone.AddContact(&gmproto.Contact{ParticipantID: strconv.Itoa(i), Name: "Contact " + strconv.Itoa(i)})
+	}
+	ctx := context.Background()
+	cli, _ := newConnectedClient(t, srv)
+
+	firstPage, err := cli.ListContacts(ctx)
+	require.NoError(t, err)
+	assert.Len(t, firstPage.GetContacts(), libgm.DefaultContactPageSize)
+	assert.NotNil(t, firstPage.GetCursor())
+
+	contacts, err := cli.ListAllContacts(ctx)
+	require.NoError(t, err)
+	require.Len(t, contacts, libgm.DefaultContactPageSize+10)
+	assert.Equal(t, "1", contacts[0].GetParticipantID())
+	assert.Equal(t, strconv.Itoa(libgm.DefaultContactPageSize+10), contacts[len(contacts)-1].GetParticipantID())
+	assert.Len(t, srv.Phone.RequestsOfType(gmproto.ActionType_LIST_CONTACTS), 3)
+}
+
+func TestListAllContactsIgnoredCursor(t *testing.T) {
+	srv := newServer(t)
+	contacts := make([]*gmproto.Contact, libgm.DefaultContactPageSize)
+	for i := range contacts {
+		contacts[i] = &gmproto.Contact{ParticipantID: strconv.Itoa(i + 1)}
+	}
+	// A phone that doesn't understand the cursor keeps returning the first page
+	srv.Phone.SetHandler(gmproto.ActionType_LIST_CONTACTS, func(_ *fakeserver.Request) (proto.Message, error) {
+		return &gmproto.ListContactsResponse{
+			Contacts: contacts,
+			Cursor:   &gmproto.Cursor{LastItemID: contacts[len(contacts)-1].GetParticipantID()},
+		}, nil
+	})
+	cli, _ := newConnectedClient(t, srv)
+
+	all, err := cli.ListAllContacts(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 2*libgm.DefaultContactPageSize)
+	assert.Len(t, srv.Phone.RequestsOfType(gmproto.ActionType_LIST_CONTACTS), 2)
+}
+
+func TestResendMessage(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{ConversationID: "1", Status: gmproto.ConversationStatus_ACTIVE})
+
+	_, err := cli.SendMessage(ctx, &gmproto.SendMessageRequest{
+		ConversationID: "1",
+		MessagePayload: &gmproto.MessagePayload{
+			MessageInfo: []*gmproto.MessageInfo{{
+				Data: &gmproto.MessageInfo_MessageContent{MessageContent: &gmproto.MessageContent{Content: "hello"}},
+			}},
+		},
+		TmpID: "tmp_456",
+	})
+	require.NoError(t, err)
+	echo := waitForEvent[*libgm.WrappedMessage](t, evts)
+
+	srv.Phone.SetMessageStatus(echo.GetMessageID(), gmproto.MessageStatusType_OUTGOING_FAILED_GENERIC)
+	failed := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, gmproto.MessageStatusType_OUTGOING_FAILED_GENERIC, failed.GetMessageStatus().GetStatus())
+
+	require.NoError(t, cli.ResendMessage(ctx, echo.GetMessageID()))
+	resent := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, echo.GetMessageID(), resent.GetMessageID())
+	assert.Equal(t, gmproto.MessageStatusType_OUTGOING_COMPLETE, resent.GetMessageStatus().GetStatus())
+}
+
+func TestDownloadMessage(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{ConversationID: "1", Status: gmproto.ConversationStatus_ACTIVE})
+
+	srv.Phone.ReceiveMessage(&gmproto.Message{
+		ConversationID: "1",
+		ParticipantID:  "2",
+		MessageStatus:  &gmproto.MessageStatus{Status: gmproto.MessageStatusType_INCOMING_YET_TO_MANUAL_DOWNLOAD},
+	})
+	pending := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, gmproto.MessageStatusType_INCOMING_YET_TO_MANUAL_DOWNLOAD, pending.GetMessageStatus().GetStatus())
+
+	require.NoError(t, cli.DownloadMessage(ctx, pending.GetMessageID()))
+	downloading := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, gmproto.MessageStatusType_INCOMING_MANUAL_DOWNLOADING, downloading.GetMessageStatus().GetStatus())
+	downloaded := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Equal(t, pending.GetMessageID(), downloaded.GetMessageID())
+	assert.Equal(t, gmproto.MessageStatusType_INCOMING_COMPLETE, downloaded.GetMessageStatus().GetStatus())
+}
+
+func TestRCSGroupMembership(t *testing.T) {
+	srv := newServer(t)
+	srv.Phone.SetRCSCapable("+15550002", true)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{
+		ConversationID: "1",
+		Name:           "Team",
+		Status:         gmproto.ConversationStatus_ACTIVE,
+		Type:           gmproto.ConversationType_RCS,
+		IsGroupChat:    true,
+		Participants: []*gmproto.Participant{
+			{ID: &gmproto.SmallInfo{ParticipantID: "1"}, IsMe: true},
+			{ID: &gmproto.SmallInfo{ParticipantID: "2", Number: "+15550001"}, IsVisible: true},
+		},
+		OtherParticipants: []string{"2"},
+	})
+
+	status, err := cli.GetContactRCSGroupStatus(ctx, &gmproto.ContactNumber{Number: "+15550003"})
+	require.NoError(t, err)
+	assert.False(t, status.GetRCSGroupCapable())
+	status, err = cli.GetContactRCSGroupStatus(ctx, &gmproto.ContactNumber{Number: "+15550002"})
+	require.NoError(t, err)
+	assert.True(t, status.GetRCSGroupCapable())
+
+	added, err := cli.AddParticipantsToRCSGroup(ctx, "1", &gmproto.ContactNumber{Number: "+15550002"})
+	require.NoError(t, err)
+	require.True(t, added.GetSuccess())
+	assert.Len(t, added.GetConversation().GetParticipants(), 3)
+	updated := waitForEvent[*gmproto.Conversation](t, evts)
+	assert.Equal(t, []string{"2", "3"}, updated.GetOtherParticipants())
+
+	left, err := cli.LeaveRCSGroup(ctx, "1")
+	require.NoError(t, err)
+	require.True(t, left.GetSuccess())
+	updated = waitForEvent[*gmproto.Conversation](t, evts)
+	assert.True(t, updated.GetReadOnly())
+	for _, part := range updated.GetParticipants() {
+		assert.False(t, part.GetIsMe())
+	}
+
+	left, err = cli.LeaveRCSGroup(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, left.GetSuccess())
+}
+
+func TestConversationMute(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{
+		ConversationID: "1",
+		Name:           "Alice",
+		Status:         gmproto.ConversationStatus_ACTIVE,
+	})
+
+	require.NoError(t, cli.SetConversationMuted(ctx, "1", true))
+	updated := waitForEvent[*gmproto.Conversation](t, evts)
+	assert.EqualValues(t, 1, updated.GetMuted().GetIsMuted())
+	assert.Equal(t, gmproto.ConversationStatus_ACTIVE, updated.GetStatus())
+
+	require.NoError(t, cli.SetConversationMuted(ctx, "1", false))
+	updated = waitForEvent[*gmproto.Conversation](t, evts)
+	assert.Nil(t, updated.GetMuted())
+
+	require.ErrorIs(t, cli.SetConversationMuted(ctx, "2", true), libgm.ErrUpdateConversationFailed)
+}
+
+func TestArchiveConversation(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{
+		ConversationID: "1",
+		Name:           "Alice",
+		Status:         gmproto.ConversationStatus_ACTIVE,
+	})
+
+	require.NoError(t, cli.SetConversationStatus(ctx, "1", gmproto.ConversationStatus_ARCHIVED))
+	assert.Equal(t, gmproto.ConversationStatus_ARCHIVED, waitForEvent[*gmproto.Conversation](t, evts).GetStatus())
+	archived, err := cli.ListConversationsPage(ctx, 25, gmproto.ListConversationsRequest_ARCHIVE, nil)
+	require.NoError(t, err)
+	require.Len(t, archived.GetConversations(), 1)
+
+	require.NoError(t, cli.SetConversationStatus(ctx, "1", gmproto.ConversationStatus_ACTIVE))
+	assert.Equal(t, gmproto.ConversationStatus_ACTIVE, waitForEvent[*gmproto.Conversation](t, evts).GetStatus())
+}
+
+func TestBlockConversation(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, evts := newConnectedClient(t, srv, &gmproto.Conversation{
+		ConversationID: "1",
+		Name:           "Spammer",
+		Status:         gmproto.ConversationStatus_ACTIVE,
+	})
+
+	require.NoError(t, cli.ReportSpam(ctx, "1"))
+	assert.Equal(t, gmproto.ConversationStatus_BLOCKED_FOLDER, waitForEvent[*gmproto.Conversation](t, evts).GetStatus())
+	blocked, err := cli.ListConversationsPage(ctx, 25, gmproto.ListConversationsRequest_SPAM_BLOCKED, nil)
+	require.NoError(t, err)
+	require.Len(t, blocked.GetConversations(), 1)
+
+	require.NoError(t, cli.UnblockConversation(ctx, "1"))
+	assert.Equal(t, gmproto.ConversationStatus_ACTIVE, waitForEvent[*gmproto.Conversation](t, evts).GetStatus())
+
+	var actions []gmproto.ConversationActionStatus
+	for _, req := range srv.Phone.RequestsOfType(gmproto.ActionType_UPDATE_CONVERSATION) {
+		var payload gmproto.UpdateConversationRequest
+		require.NoError(t, req.Unmarshal(&payload))
+		actions = append(actions, payload.GetAction())
+	}
+	assert.Equal(t, []gmproto.ConversationActionStatus{gmproto.ConversationActionStatus_BLOCK_AND_REPORT, gmproto.ConversationActionStatus_UNBLOCK}, actions)
+
+	require.ErrorIs(t, cli.BlockConversation(ctx, "2"), libgm.ErrUpdateConversationFailed)
+}
+
+func TestSetTyping(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, _ := newConnectedClient(t, srv)
+
+	require.NoError(t, cli.SetTyping(ctx, "1", true, nil))
+	require.NoError(t, cli.SetTyping(ctx, "1", false, nil))
+	require.Eventually(t, func() bool {
+		return len(srv.Phone.RequestsOfType(gmproto.ActionType_TYPING_UPDATES)) == 2
+	}, 10*time.Second, 10*time.Millisecond)
+	var typing []bool
+	for _, req := range srv.Phone.RequestsOfType(gmproto.ActionType_TYPING_UPDATES) {
+		var payload gmproto.TypingUpdateRequest
+		require.NoError(t, req.Unmarshal(&payload))
+		assert.Equal(t, "1", payload.GetData().GetConversationID())
+		typing = append(typing, payload.GetData().GetTyping())
+	}
+	assert.Equal(t, []bool{true, false}, typing)
+}
+
+func TestMediaRoundtrip(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, _ := newPairedClient(t, srv)
+	data := bytes.Repeat([]byte("meow"), 100_000)
+	media, err := cli.UploadMedia(ctx, data, "cat.txt", "text/plain")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), media.GetSize())
+	downloaded, err := cli.DownloadMedia(ctx, media.GetMediaID(), media.GetDecryptionKey())
+	require.NoError(t, err)
+	assert.Equal(t, data, downloaded)
+}
+
+func TestMediaStreaming(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, _ := newPairedClient(t, srv)
+	// Large enough to be split into multiple upload requests
+	const size = 10*1024*1024 + 12345
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+	media, err := cli.UploadMediaStream(ctx, bytes.NewReader(data), size, "noise.bin", "application/octet-stream")
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), media.GetSize())
+
+	stream, err := cli.DownloadMediaStream(ctx, media.GetMediaID(), media.GetDecryptionKey())
+	require.NoError(t, err)
+	hash := sha256.New()
+	n, err := io.Copy(hash, stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	assert.Equal(t, int64(size), n)
+	assert.Equal(t, sha256.Sum256(data), [32]byte(hash.Sum(nil)))
+
+	_, err = cli.UploadMediaStream(ctx, bytes.NewReader(data[:100]), 200, "short.bin", "application/octet-stream")
+	assert.Error(t, err)
+}
+
+func TestResumableUpload(t *testing.T) {
+	srv := newServer(t)
+	ctx := context.Background()
+	cli, _ := newPairedClient(t, srv)
+	const size = 10 * 1024 * 1024
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+	// The first chunk is only partially committed, so the client has to resume from the middle of it
+	srv.FailUploadChunks(1)
+	media, err := cli.UploadMediaStream(ctx, bytes.NewReader(data), size, "noise.bin", "application/octet-stream")
+	require.NoError(t, err)
+	downloaded, err := cli.DownloadMedia(ctx, media.GetMediaID(), media.GetDecryptionKey())
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, downloaded))
+
+	// The response to the finalizing request is lost, so the client has to get the result by querying
+	srv.FailUploadChunks(1)
+	media, err = cli.UploadMedia(ctx, []byte("meow"), "cat.txt", "text/plain")
+	require.NoError(t, err)
+	downloaded, err = cli.DownloadMedia(ctx, media.GetMediaID(), media.GetDecryptionKey())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("meow"), downloaded)
+}
+
+func TestRejectedUploadNotRetried(t *testing.T) {
+	srv := newServer(t)
+	cli, _ := newPairedClient(t, srv)
+	srv.RejectUploadChunks(1)
+	start := time.Now()
+	_, err := cli.UploadMedia(context.Background(), []byte("meow"), "cat.txt", "text/plain")
+	var statusErr libgm.UploadStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := newServer(t)
+	srv.Phone.SetHandler(gmproto.ActionType_GET_CONVERSATION, nil)
+	cli, _ := newConnectedClient(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := cli.GetConversation(ctx, "1")
+	require.ErrorIs(t, err, events.ErrRequestTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var timeoutErr events.RequestTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, gmproto.ActionType_GET_CONVERSATION, timeoutErr.Action)
+}
+
+func waitForState(t *testing.T, evts <-chan any, state events.ConnectionState) *events.ConnectionStateChanged {
+	for {
+		evt := waitForEvent[*events.ConnectionStateChanged](t, evts)
+		if evt.State == state {
+			return evt
+		}
+	}
+}
+
+func TestConnectionState(t *testing.T) {
+	srv := newServer(t)
+	cli, evts := newConnectedClient(t, srv)
+	evt := waitForEvent[*events.ConnectionStateChanged](t, evts)
+	assert.Equal(t, events.StateConnecting, evt.State)
+	assert.Equal(t, events.StateDisconnected, evt.Previous)
+	// The long poll is open, but the session isn't active until the phone says so
+	assert.True(t, cli.IsConnected())
+	assert.Equal(t, events.StateConnecting, cli.ConnectionState())
+
+	srv.Phone.SendUserAlert(gmproto.AlertType_BROWSER_ACTIVE)
+	waitForState(t, evts, events.StateLongPollOpen)
+
+	srv.Phone.SendUserAlert(gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT)
+	evt = waitForState(t, evts, events.StateBrowserInactive)
+	assert.Equal(t, events.StateLongPollOpen, evt.Previous)
+	assert.Equal(t, gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT, evt.InactiveAlert)
+	assert.Equal(t, gmproto.AlertType_BROWSER_INACTIVE_FROM_TIMEOUT, cli.ConnectionStatus().InactiveAlert)
+
+	srv.Phone.SendUserAlert(gmproto.AlertType_BROWSER_ACTIVE)
+	waitForState(t, evts, events.StateLongPollOpen)
+
+	srv.Phone.RevokePairing()
+	evt = waitForState(t, evts, events.StateLoggedOut)
+	assert.ErrorIs(t, evt.Error, events.ErrPairingRevoked)
+	// Logging out is sticky until the client is stopped
+	srv.Phone.SendUserAlert(gmproto.AlertType_BROWSER_ACTIVE)
+	cli.Disconnect()
+	evt = waitForState(t, evts, events.StateDisconnected)
+	assert.Equal(t, events.StateLoggedOut, evt.Previous)
+	assert.Equal(t, events.StateDisconnected, cli.ConnectionState())
+	assert.False(t, cli.IsConnected())
+}
+
+type memoryAckStore struct {
+	pending map[string]struct{}
+	lock    sync.Mutex
+}
+
+func (mas *memoryAckStore) AddPendingAcks(_ context.Context, messageIDs []string) error {
+	mas.lock.Lock()
+	for _, id := range messageIDs {
+		mas.pending[id] = struct{}{}
+	}
+	mas.lock.Unlock()
+	return nil
+}
+
+func (mas *memoryAckStore) RemovePendingAcks(_ context.Context, messageIDs []string) error {
+	mas.lock.Lock()
+	for _, id := range messageIDs {
+		delete(mas.pending, id)
+	}
+	mas.lock.Unlock()
+	return nil
+}
+
+func (mas *memoryAckStore) GetPendingAcks(_ context.Context) ([]string, error) {
+	mas.lock.Lock()
+	defer mas.lock.Unlock()
+	return slices.Collect(maps.Keys(mas.pending)), nil
+}
+
+func TestAckRedelivery(t *testing.T) {
+	srv := newServer(t)
+	store := &memoryAckStore{pending: make(map[string]struct{})}
+	srv.FailAcks(1000)
+	cli, evts := newPairedClient(t, srv)
+	cli.SetAckStore(store)
+	connectClient(t, srv, cli)
+
+	srv.Phone.ReceiveMessage(&gmproto.Message{
+		ConversationID: "1",
+		ParticipantID:  "2",
+		MessageStatus:  &gmproto.MessageStatus{Status: gmproto.MessageStatusType_INCOMING_COMPLETE},
+	})
+	msg := waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.False(t, msg.IsRedelivered)
+	// Acks are persisted as soon as they're queued, before the first ack request is even attempted
+	pending, _ := store.GetPendingAcks(context.Background())
+	assert.ElementsMatch(t, srv.Unacked(), pending)
+	// Acks keep failing, so disconnecting leaves everything pending on both sides
+	cli.Disconnect()
+	pending, _ = store.GetPendingAcks(context.Background())
+	assert.ElementsMatch(t, srv.Unacked(), pending)
+
+	srv.FailAcks(0)
+	cli2, evts2 := newClient(t, srv, cli.AuthData)
+	cli2.SetAckStore(store)
+	require.NoError(t, cli2.Connect())
+	redelivered := waitForEvent[*libgm.WrappedMessage](t, evts2)
+	assert.True(t, redelivered.IsRedelivered)
+	assert.Equal(t, msg.GetMessageID(), redelivered.GetMessageID())
+	require.Eventually(t, func() bool {
+		pending, _ := store.GetPendingAcks(context.Background())
+		return len(srv.Unacked()) == 0 && len(pending) == 0
+	}, 15*time.Second, 50*time.Millisecond)
+}
+
+func TestEventSubscriptions(t *testing.T) {
+	srv := newServer(t)
+	cli, evts := newConnectedClient(t, srv)
+	messages := make(chan *libgm.WrappedMessage, 4)
+	unsubscribe := cli.OnMessage(func(msg *libgm.WrappedMessage) {
+		messages <- msg
+	})
+	var alerts atomic.Int32
+	libgm.Subscribe(cli, func(*gmproto.UserAlertEvent) {
+		alerts.Add(1)
+	})
+
+	srv.Phone.ReceiveMessage(&gmproto.Message{ConversationID: "1", ParticipantID: "2"})
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "1", msg.GetConversationID())
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+	// The catch-all handler still receives everything
+	waitForEvent[*libgm.WrappedMessage](t, evts)
+
+	unsubscribe()
+	srv.Phone.ReceiveMessage(&gmproto.Message{ConversationID: "1", ParticipantID: "2"})
+	waitForEvent[*libgm.WrappedMessage](t, evts)
+	assert.Empty(t, messages)
+
+	srv.Phone.PushUpdate(&gmproto.UpdateEvents{Event: &gmproto.UpdateEvents_UserAlertEvent{
+		UserAlertEvent: &gmproto.UserAlertEvent{AlertType: gmproto.AlertType_MOBILE_BATTERY_LOW},
+	}})
+	require.Eventually(t, func() bool { return alerts.Load() == 1 }, 10*time.Second, 10*time.Millisecond)
+}
+
+type recordingMetrics struct {
+	libgm.NoopMetrics
+	lock      sync.Mutex
+	responses []gmproto.ActionType
+	timeouts  []gmproto.ActionType
+	ackSizes  []int
+}
+
+func (rm *recordingMetrics) RPCResponse(action gmproto.ActionType, _ time.Duration) {
+	rm.lock.Lock()
+	rm.responses = append(rm.responses, action)
+	rm.lock.Unlock()
+}
+
+func (rm *recordingMetrics) RPCTimeout(action gmproto.ActionType) {
+	rm.lock.Lock()
+	rm.timeouts = append(rm.timeouts, action)
+	rm.lock.Unlock()
+}
+
+func (rm *recordingMetrics) AckBatch(size int, err error) {
+	if err == nil {
+		rm.lock.Lock()
+		rm.ackSizes = append(rm.ackSizes, size)
+		rm.lock.Unlock()
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv := newServer(t)
+	srv.Phone.SetHandler(gmproto.ActionType_GET_CONVERSATION, nil)
+	metrics := &recordingMetrics{}
+	cli, _ := newPairedClient(t, srv)
+	cli.SetMetrics(metrics)
+	require.NoError(t, cli.Connect())
+
+	ctx := context.Background()
+	_, err := cli.ListConversations(ctx, 25, gmproto.ListConversationsRequest_INBOX)
+	require.NoError(t, err)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	_, err = cli.GetConversation(timeoutCtx, "1")
+	require.ErrorIs(t, err, events.ErrRequestTimeout)
+
+	metrics.lock.Lock()
+	assert.Contains(t, metrics.responses, gmproto.ActionType_LIST_CONVERSATIONS)
+	assert.NotContains(t, metrics.responses, gmproto.ActionType_GET_CONVERSATION)
+	assert.Equal(t, []gmproto.ActionType{gmproto.ActionType_GET_CONVERSATION}, metrics.timeouts)
+	metrics.lock.Unlock()
+
+	// Responses are acked, so disconnecting flushes at least one batch
+	cli.Disconnect()
+	metrics.lock.Lock()
+	assert.NotEmpty(t, metrics.ackSizes)
+	metrics.lock.Unlock()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := newServer(t)
+	srv.Phone.AddConversation(&gmproto.Conversation{
+		ConversationID: "1",
+		Name:           "Alice",
+		Status:         gmproto.ConversationStatus_ACTIVE,
+	})
+	var capture bytes.Buffer
+	var captureLock sync.Mutex
+	cli, evts := newPairedClient(t, srv)
+	cli.SetRecorder(libgm.NewRecorder(writerFunc(func(p []byte) (int, error) {
+		captureLock.Lock()
+		defer captureLock.Unlock()
+		return capture.Write(p)
+	})))
+	require.NoError(t, cli.Connect())
+	_, err := cli.ListConversations(context.Background(), 25, gmproto.ListConversationsRequest_INBOX)
+	require.NoError(t, err)
+	srv.Phone.ReceiveMessage(&gmproto.Message{
+		ConversationID: "1",
+		ParticipantID:  "2",
+		MessageStatus:  &gmproto.MessageStatus{Status: gmproto.MessageStatusType_INCOMING_COMPLETE},
+	})
+	msg := waitForEvent[*libgm.WrappedMessage](t, evts)
+	cli.Disconnect()
+
+	captureLock.Lock()
+	data := bytes.Clone(capture.Bytes())
+	captureLock.Unlock()
+	var entries []*libgm.CaptureEntry
+	for entry, err := range libgm.ReadCapture(bytes.NewReader(data)) {
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	listReq := slices.IndexFunc(entries, func(entry *libgm.CaptureEntry) bool {
+		return entry.Direction == libgm.CaptureOutgoing && entry.Action == gmproto.ActionType_LIST_CONVERSATIONS
+	})
+	require.GreaterOrEqual(t, listReq, 0)
+	payload, err := entries[listReq].DecodePayload()
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), payload.(*gmproto.ListConversationsRequest).GetCount())
+	listResp := slices.IndexFunc(entries, func(entry *libgm.CaptureEntry) bool {
+		return entry.Direction == libgm.CaptureIncoming && entry.RequestID == entries[listReq].RequestID
+	})
+	require.GreaterOrEqual(t, listResp, 0)
+	payload, err = entries[listResp].DecodePayload()
+	require.NoError(t, err)
+	assert.Len(t, payload.(*gmproto.ListConversationsResponse).GetConversations(), 1)
+
+	replayCli := libgm.NewClient(libgm.NewAuthData(), nil, newLogger(t, "replay"))
+	var replayed []*libgm.WrappedMessage
+	replayCli.OnMessage(func(evt *libgm.WrappedMessage) {
+		replayed = append(replayed, evt)
+	})
+	for _, entry := range entries {
+		require.NoError(t, replayCli.Replay(entry))
+	}
+	require.Len(t, replayed, 1)
+	assert.Equal(t, msg.GetMessageID(), replayed[0].GetMessageID())
+}
+
+type closeRecordingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (crb *closeRecordingBuffer) Close() error {
+	crb.closed = true
+	return nil
+}
+
+func TestRecorderClose(t *testing.T) {
+	var buf closeRecordingBuffer
+	rec := libgm.NewRecorder(&buf)
+	require.NoError(t, rec.Record(&libgm.CaptureEntry{Action: gmproto.ActionType_LIST_CONVERSATIONS}))
+	written := buf.Len()
+	require.NoError(t, rec.Close())
+	assert.True(t, buf.closed)
+	require.NoError(t, rec.Record(&libgm.CaptureEntry{Action: gmproto.ActionType_LIST_CONVERSATIONS}))
+	assert.Equal(t, written, buf.Len())
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (wf writerFunc) Write(p []byte) (int, error) {
+	return wf(p)
+}
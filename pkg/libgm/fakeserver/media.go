@@ -0,0 +1,204 @@
+package fakeserver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+type pendingUpload struct {
+	expectedSize int64
+	data         []byte
+	// response is the body of the finalize response, set once the upload is complete
+	response []byte
+}
+
+// FailUploadChunks makes the next count media upload requests fail with HTTP 503 after partially processing them,
+// like a connection dropping mid-request. Chunks that don't finalize the upload are committed up to
+// half their size, rounded down to the chunk granularity. Finalizing chunks complete the upload, but the
+// client doesn't get the response.
+func (s *Server) FailUploadChunks(count int) {
+	s.mediaLock.Lock()
+	s.failUploads = count
+	s.mediaLock.Unlock()
+}
+
+// RejectUploadChunks makes the next count media upload requests fail with HTTP 403 without processing them.
+func (s *Server) RejectUploadChunks(count int) {
+	s.mediaLock.Lock()
+	s.rejectUploads = count
+	s.mediaLock.Unlock()
+}
+
+// Media returns the encrypted bytes of an uploaded media file.
+func (s *Server) Media(mediaID string) ([]byte, bool) {
+	s.mediaLock.Lock()
+	defer s.mediaLock.Unlock()
+	data, ok := s.media[mediaID]
+	return data, ok
+}
+
+// AddMedia stores encrypted media that clients can download with the returned media ID.
+func (s *Server) AddMedia(encrypted []byte) string {
+	mediaID := uuid.NewString()
+	s.mediaLock.Lock()
+	s.media[mediaID] = encrypted
+	s.mediaLock.Unlock()
+	return mediaID
+}
+
+func (s *Server) handleStartUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-goog-upload-command") != "start" {
+		s.writeError(w, http.StatusBadRequest, "Unsupported upload command")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read body: %v", err))
+		return
+	}
+	rawReq, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode body: %v", err))
+		return
+	}
+	var req gmproto.StartMediaUploadRequest
+	if err = proto.Unmarshal(rawReq, &req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse body: %v", err))
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("x-goog-upload-header-content-length"), 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid content length header")
+		return
+	}
+	uploadID := uuid.NewString()
+	s.mediaLock.Lock()
+	s.uploads[uploadID] = &pendingUpload{expectedSize: size}
+	s.mediaLock.Unlock()
+	uploadURL := fmt.Sprintf("%s/upload/%s", s.srv.URL, uploadID)
+	w.Header().Set("x-guploader-uploadid", uploadID)
+	w.Header().Set("x-goog-upload-url", uploadURL)
+	w.Header().Set("x-goog-upload-control-url", uploadURL)
+	w.Header().Set("x-goog-upload-status", "active")
+	w.Header().Set("x-goog-upload-chunk-granularity", strconv.FormatInt(s.ChunkGranularity, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	uploadID := r.PathValue("uploadID")
+	s.mediaLock.Lock()
+	defer s.mediaLock.Unlock()
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "Unknown upload ID")
+		return
+	}
+	commands := strings.Split(r.Header.Get("x-goog-upload-command"), ",")
+	var doUpload, doFinalize bool
+	for _, cmd := range commands {
+		switch strings.TrimSpace(cmd) {
+		case "upload":
+			doUpload = true
+		case "finalize":
+			doFinalize = true
+		case "query":
+			if upload.response != nil {
+				w.Header().Set("x-goog-upload-status", "final")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(upload.response)
+				return
+			}
+			w.Header().Set("x-goog-upload-status", "active")
+			w.Header().Set("x-goog-upload-size-received", strconv.Itoa(len(upload.data)))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	if upload.response != nil {
+		s.writeError(w, http.StatusBadRequest, "Upload is already finalized")
+		return
+	}
+	if s.rejectUploads > 0 {
+		s.rejectUploads--
+		s.writeError(w, http.StatusForbidden, "Simulated upload rejection")
+		return
+	}
+	fail := s.failUploads > 0
+	if fail {
+		s.failUploads--
+	}
+	if doUpload {
+		offset, err := strconv.ParseInt(r.Header.Get("x-goog-upload-offset"), 10, 64)
+		if err != nil || offset != int64(len(upload.data)) {
+			s.writeError(w, http.StatusBadRequest, "Invalid upload offset")
+			return
+		}
+		chunk, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read body: %v", err))
+			return
+		}
+		if !doFinalize && int64(len(chunk))%s.ChunkGranularity != 0 {
+			s.writeError(w, http.StatusBadRequest, "Chunk size is not a multiple of the granularity")
+			return
+		}
+		if fail && !doFinalize {
+			committed := int64(len(chunk)) / 2 / s.ChunkGranularity * s.ChunkGranularity
+			upload.data = append(upload.data, chunk[:committed]...)
+			s.writeError(w, http.StatusServiceUnavailable, "Simulated upload failure")
+			return
+		}
+		upload.data = append(upload.data, chunk...)
+	}
+	if !doFinalize {
+		w.Header().Set("x-goog-upload-status", "active")
+		w.WriteHeader(http.StatusOK)
+		return
+	} else if int64(len(upload.data)) != upload.expectedSize {
+		s.writeError(w, http.StatusBadRequest, "Upload size doesn't match declared content length")
+		return
+	}
+	mediaID := uuid.NewString()
+	s.media[mediaID] = upload.data
+	resp, _ := proto.Marshal(&gmproto.UploadMediaResponse{
+		Media: &gmproto.UploadedMedia{MediaID: mediaID, MediaNumber: int64(len(s.media))},
+	})
+	upload.response = []byte(base64.StdEncoding.EncodeToString(resp))
+	if fail {
+		s.writeError(w, http.StatusServiceUnavailable, "Simulated upload failure")
+		return
+	}
+	w.Header().Set("x-goog-upload-status", "final")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(upload.response)
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	rawReq, err := base64.StdEncoding.DecodeString(r.Header.Get("x-goog-download-metadata"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid download metadata")
+		return
+	}
+	var req gmproto.DownloadAttachmentRequest
+	if err = proto.Unmarshal(rawReq, &req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid download metadata")
+		return
+	}
+	data, ok := s.Media(req.GetInfo().GetAttachmentID())
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
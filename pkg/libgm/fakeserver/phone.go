@@ -0,0 +1,812 @@
+package fakeserver
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/crypto"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+// ErrNoResponse can be returned from a Handler to make the phone silently drop a request.
+var ErrNoResponse = errors.New("no response")
+
+// Request is a decrypted request that the client sent to the phone.
+type Request struct {
+	ID      string
+	Action  gmproto.ActionType
+	Message *gmproto.OutgoingRPCMessage
+	RPC     *gmproto.OutgoingRPCData
+	// Data is the decrypted protobuf payload of the request.
+	Data []byte
+}
+
+// Unmarshal parses the decrypted payload of the request into the given message.
+func (req *Request) Unmarshal(into proto.Message) error {
+	return proto.Unmarshal(req.Data, into)
+}
+
+// Handler answers a single request. Returning a nil message and a nil error sends no response,
+// which is what the real phone does for fire-and-forget actions like TYPING_UPDATES.
+type Handler func(req *Request) (proto.Message, error)
+
+// Phone is a scripted Android phone running Google Messages. It holds the pairing keys,
+// decrypts requests from the client and answers them using a small in-memory message store.
+type Phone struct {
+	Device *gmproto.Device
+
+	server *Server
+
+	lock          sync.Mutex
+	crypto        *crypto.AESCTRHelper
+	sessionID     string
+	offline       bool
+	handlers      map[gmproto.ActionType]Handler
+	requests      []*Request
+	conversations map[string]*gmproto.Conversation
+	messages      map[string][]*gmproto.Message
+	contacts      []*gmproto.Contact
+	settings      *gmproto.Settings
+	rcsNumbers    map[string]bool
+}
+
+func newPhone(server *Server) *Phone {
+	p := &Phone{
+		Device: &gmproto.Device{
+			UserID:   1,
+			SourceID: randomHex(8),
+			Network:  "Bugle",
+		},
+		server:        server,
+		handlers:      make(map[gmproto.ActionType]Handler),
+		conversations: make(map[string]*gmproto.Conversation),
+		messages:      make(map[string][]*gmproto.Message),
+		rcsNumbers:    make(map[string]bool),
+	}
+	p.handlers[gmproto.ActionType_NOTIFY_DITTO_ACTIVITY] = p.handleNotifyDittoActivity
+	p.handlers[gmproto.ActionType_IS_BUGLE_DEFAULT] = p.handleIsBugleDefault
+	p.handlers[gmproto.ActionType_GET_UPDATES] = p.handleGetUpdates
+	p.handlers[gmproto.ActionType_LIST_CONVERSATIONS] = p.handleListConversations
+	p.handlers[gmproto.ActionType_GET_CONVERSATION] = p.handleGetConversation
+	p.handlers[gmproto.ActionType_GET_CONVERSATION_TYPE] = p.handleGetConversationType
+	p.handlers[gmproto.ActionType_LIST_MESSAGES] = p.handleListMessages
+	p.handlers[gmproto.ActionType_SEND_MESSAGE] = p.handleSendMessage
+	p.handlers[gmproto.ActionType_SEND_REACTION] = p.handleSendReaction
+	p.handlers[gmproto.ActionType_DELETE_MESSAGE] = p.handleDeleteMessage
+	p.handlers[gmproto.ActionType_RESEND_MESSAGE] = p.handleResendMessage
+	p.handlers[gmproto.ActionType_DOWNLOAD_MESSAGE] = p.handleDownloadMessage
+	p.handlers[gmproto.ActionType_MESSAGE_READ] = p.handleMessageRead
+	p.handlers[gmproto.ActionType_LIST_CONTACTS] = p.handleListContacts
+	p.handlers[gmproto.ActionType_LIST_TOP_CONTACTS] = p.handleListTopContacts
+	p.handlers[gmproto.ActionType_GET_OR_CREATE_CONVERSATION] = p.handleGetOrCreateConversation
+	p.handlers[gmproto.ActionType_UPDATE_CONVERSATION] = p.handleUpdateConversation
+	p.handlers[gmproto.ActionType_LEAVE_RCS_GROUP] = p.handleLeaveRCSGroup
+	p.handlers[gmproto.ActionType_ADD_PARTICIPANT_TO_RCS_GROUP] = p.handleAddParticipantToRCSGroup
+	p.handlers[gmproto.ActionType_GET_CONTACT_RCS_GROUP_STATUS] = p.handleGetContactRCSGroupStatus
+	p.handlers[gmproto.ActionType_GET_PARTICIPANTS_THUMBNAIL] = p.handleGetThumbnail
+	p.handlers[gmproto.ActionType_GET_CONTACTS_THUMBNAIL] = p.handleGetThumbnail
+	p.handlers[gmproto.ActionType_TYPING_UPDATES] = noResponse
+	p.handlers[gmproto.ActionType_SETTINGS_UPDATE] = noResponse
+	return p
+}
+
+func noResponse(_ *Request) (proto.Message, error) {
+	return nil, nil
+}
+
+// SetKeys sets the keys used to encrypt communication with the client, which is normally done by pairing.
+func (p *Phone) SetKeys(keys *crypto.AESCTRHelper) {
+	p.lock.Lock()
+	p.crypto = keys
+	p.lock.Unlock()
+}
+
+func (p *Phone) setKeys(aesKey, hmacKey []byte) {
+	p.SetKeys(&crypto.AESCTRHelper{AESKey: aesKey, HMACKey: hmacKey})
+}
+
+// SetHandler overrides the handler for the given action. Passing a nil handler makes the phone ignore the action.
+func (p *Phone) SetHandler(action gmproto.ActionType, handler Handler) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if handler == nil {
+		handler = func(_ *Request) (proto.Message, error) {
+			return nil, ErrNoResponse
+		}
+	}
+	p.handlers[action] = handler
+}
+
+// SetOffline makes the phone stop (or resume) answering all requests, like a phone without network access.
+func (p *Phone) SetOffline(offline bool) {
+	p.lock.Lock()
+	p.offline = offline
+	p.lock.Unlock()
+}
+
+// Requests returns all requests the phone has received so far.
+func (p *Phone) Requests() []*Request {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return slices.Clone(p.requests)
+}
+
+// RequestsOfType returns all received requests with the given action.
+func (p *Phone) RequestsOfType(action gmproto.ActionType) []*Request {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var out []*Request
+	for _, req := range p.requests {
+		if req.Action == action {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// AddConversation stores a conversation on the phone without notifying the client.
+func (p *Phone) AddConversation(conv *gmproto.Conversation) {
+	p.lock.Lock()
+	p.conversations[conv.GetConversationID()] = conv
+	p.lock.Unlock()
+}
+
+// AddContact stores a contact in the phone's address book.
+func (p *Phone) AddContact(contact *gmproto.Contact) {
+	p.lock.Lock()
+	p.contacts = append(p.contacts, contact)
+	p.lock.Unlock()
+}
+
+// SetRCSCapable changes whether the given phone number can be added to RCS groups. Numbers are not RCS capable by default.
+func (p *Phone) SetRCSCapable(number string, capable bool) {
+	p.lock.Lock()
+	p.rcsNumbers[number] = capable
+	p.lock.Unlock()
+}
+
+// SetSettings changes the settings that the phone sends to the client when it becomes active.
+func (p *Phone) SetSettings(settings *gmproto.Settings) {
+	p.lock.Lock()
+	p.settings = settings
+	p.lock.Unlock()
+}
+
+// ReceiveMessage stores an incoming message and sends it to the client as a live update.
+func (p *Phone) ReceiveMessage(msg *gmproto.Message) {
+	p.lock.Lock()
+	if msg.MessageID == "" {
+		msg.MessageID = strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().UnixMicro()
+	}
+	p.addMessage(msg)
+	p.lock.Unlock()
+	p.PushUpdate(&gmproto.UpdateEvents{
+		Event: &gmproto.UpdateEvents_MessageEvent{MessageEvent: &gmproto.MessageEvent{
+			Data: []*gmproto.Message{msg},
+		}},
+	})
+}
+
+// PushUpdate encrypts the given event and sends it to the client as a GET_UPDATES response.
+func (p *Phone) PushUpdate(evt *gmproto.UpdateEvents) {
+	p.lock.Lock()
+	sessionID := p.sessionID
+	p.lock.Unlock()
+	p.sendData(sessionID, gmproto.ActionType_GET_UPDATES, evt)
+}
+
+// SetMessageStatus changes the status of a stored message and sends the updated message to the client,
+// e.g. to make an outgoing message fail.
+func (p *Phone) SetMessageStatus(messageID string, status gmproto.MessageStatusType) {
+	p.lock.Lock()
+	msg, _ := p.findMessage(messageID)
+	if msg == nil {
+		p.lock.Unlock()
+		return
+	}
+	msg.MessageStatus = &gmproto.MessageStatus{Status: status}
+	updated := proto.Clone(msg).(*gmproto.Message)
+	p.lock.Unlock()
+	p.PushUpdate(&gmproto.UpdateEvents{
+		Event: &gmproto.UpdateEvents_MessageEvent{MessageEvent: &gmproto.MessageEvent{
+			Data: []*gmproto.Message{updated},
+		}},
+	})
+}
+
+// SendUserAlert sends a user alert to the client, e.g. BROWSER_ACTIVE after the client has become the active session.
+func (p *Phone) SendUserAlert(alertType gmproto.AlertType) {
+	p.PushUpdate(&gmproto.UpdateEvents{
+		Event: &gmproto.UpdateEvents_UserAlertEvent{UserAlertEvent: &gmproto.UserAlertEvent{AlertType: alertType}},
+	})
+}
+
+func (p *Phone) addMessage(msg *gmproto.Message) {
+	p.messages[msg.GetConversationID()] = append(p.messages[msg.GetConversationID()], msg)
+	if conv, ok := p.conversations[msg.GetConversationID()]; ok {
+		conv.LatestMessageID = msg.GetMessageID()
+		conv.LastMessageTimestamp = msg.GetTimestamp()
+	}
+}
+
+func (p *Phone) sendData(sessionID string, action gmproto.ActionType, data proto.Message) {
+	p.lock.Lock()
+	keys := p.crypto
+	p.lock.Unlock()
+	if keys == nil {
+		p.server.Log.Warn().Stringer("action", action).Msg("Phone isn't paired, dropping outgoing data")
+		return
+	}
+	plaintext, err := proto.Marshal(data)
+	if err != nil {
+		p.server.Log.Err(err).Msg("Failed to marshal phone response")
+		return
+	}
+	encrypted, err := keys.Encrypt(plaintext)
+	if err != nil {
+		p.server.Log.Err(err).Msg("Failed to encrypt phone response")
+		return
+	}
+	p.server.pushRPC(gmproto.BugleRoute_DataEvent, &gmproto.RPCMessageData{
+		SessionID:     sessionID,
+		Timestamp:     time.Now().UnixMicro(),
+		Action:        action,
+		EncryptedData: encrypted,
+	})
+}
+
+func (p *Phone) handleRequest(msg *gmproto.OutgoingRPCMessage, data *gmproto.OutgoingRPCData) {
+	req := &Request{
+		ID:      data.GetRequestID(),
+		Action:  data.GetAction(),
+		Message: msg,
+		RPC:     data,
+		Data:    data.GetUnencryptedProtoData(),
+	}
+	p.lock.Lock()
+	keys := p.crypto
+	offline := p.offline
+	handler := p.handlers[req.Action]
+	p.lock.Unlock()
+	log := p.server.Log.With().Str("request_id", req.ID).Stringer("action", req.Action).Logger()
+	if offline {
+		log.Debug().Msg("Phone is offline, dropping request")
+		return
+	}
+	if len(data.GetEncryptedProtoData()) > 0 {
+		if keys == nil {
+			log.Warn().Msg("Phone isn't paired, dropping encrypted request")
+			return
+		}
+		var err error
+		req.Data, err = keys.Decrypt(data.GetEncryptedProtoData())
+		if err != nil {
+			log.Err(err).Msg("Failed to decrypt request")
+			return
+		}
+	}
+	p.lock.Lock()
+	p.requests = append(p.requests, req)
+	p.lock.Unlock()
+	if handler == nil {
+		log.Warn().Msg("No handler for action, not responding")
+		return
+	}
+	resp, err := handler(req)
+	if err != nil {
+		if !errors.Is(err, ErrNoResponse) {
+			log.Err(err).Msg("Handler failed")
+		}
+		return
+	} else if resp == nil {
+		return
+	}
+	p.sendData(req.ID, req.Action, resp)
+}
+
+func (p *Phone) handleNotifyDittoActivity(_ *Request) (proto.Message, error) {
+	return &gmproto.NotifyDittoActivityResponse{}, nil
+}
+
+func (p *Phone) handleIsBugleDefault(_ *Request) (proto.Message, error) {
+	return &gmproto.IsBugleDefaultResponse{Success: true}, nil
+}
+
+func (p *Phone) handleGetUpdates(req *Request) (proto.Message, error) {
+	p.lock.Lock()
+	p.sessionID = req.ID
+	settings := p.settings
+	p.lock.Unlock()
+	if settings != nil {
+		p.PushUpdate(&gmproto.UpdateEvents{
+			Event: &gmproto.UpdateEvents_SettingsEvent{SettingsEvent: settings},
+		})
+	}
+	return nil, nil
+}
+
+func folderMatches(folder gmproto.ListConversationsRequest_Folder, status gmproto.ConversationStatus) bool {
+	switch folder {
+	case gmproto.ListConversationsRequest_ARCHIVE:
+		return status == gmproto.ConversationStatus_ARCHIVED || status == gmproto.ConversationStatus_KEEP_ARCHIVED
+	case gmproto.ListConversationsRequest_SPAM_BLOCKED:
+		return status == gmproto.ConversationStatus_SPAM_FOLDER || status == gmproto.ConversationStatus_BLOCKED_FOLDER
+	default:
+		return status == gmproto.ConversationStatus_ACTIVE || status == gmproto.ConversationStatus_UNKNOWN_CONVERSATION_STATUS
+	}
+}
+
+// paginate sorts items from newest to oldest and returns the page after the given cursor.
+func paginate[T any](items []T, count int64, cursor *gmproto.Cursor, getID func(T) string, getTS func(T) int64) ([]T, *gmproto.Cursor) {
+	slices.SortFunc(items, func(a, b T) int {
+		return cmp.Or(cmp.Compare(getTS(b), getTS(a)), cmp.Compare(getID(b), getID(a)))
+	})
+	if cursor != nil {
+		idx := slices.IndexFunc(items, func(item T) bool {
+			return getTS(item) < cursor.GetLastItemTimestamp() ||
+				(getTS(item) == cursor.GetLastItemTimestamp() && getID(item) < cursor.GetLastItemID())
+		})
+		if idx < 0 {
+			idx = len(items)
+		}
+		items = items[idx:]
+	}
+	if count <= 0 || int64(len(items)) <= count {
+		return items, nil
+	}
+	items = items[:count]
+	last := items[len(items)-1]
+	return items, &gmproto.Cursor{LastItemID: getID(last), LastItemTimestamp: getTS(last)}
+}
+
+func (p *Phone) handleListConversations(req *Request) (proto.Message, error) {
+	var payload gmproto.ListConversationsRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var convs []*gmproto.Conversation
+	for _, conv := range p.conversations {
+		if folderMatches(payload.GetFolder(), conv.GetStatus()) {
+			convs = append(convs, proto.Clone(conv).(*gmproto.Conversation))
+		}
+	}
+	page, cursor := paginate(convs, payload.GetCount(), payload.Cursor,
+		(*gmproto.Conversation).GetConversationID, (*gmproto.Conversation).GetLastMessageTimestamp)
+	return &gmproto.ListConversationsResponse{Conversations: page, Cursor: cursor}, nil
+}
+
+func (p *Phone) handleGetConversation(req *Request) (proto.Message, error) {
+	var payload gmproto.GetConversationRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	conv, ok := p.conversations[payload.GetConversationID()]
+	if !ok {
+		return &gmproto.GetConversationResponse{}, nil
+	}
+	return &gmproto.GetConversationResponse{Conversation: proto.Clone(conv).(*gmproto.Conversation)}, nil
+}
+
+func (p *Phone) handleGetConversationType(req *Request) (proto.Message, error) {
+	var payload gmproto.GetConversationTypeRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	conv := p.conversations[payload.GetConversationID()]
+	return &gmproto.GetConversationTypeResponse{
+		ConversationID: payload.GetConversationID(),
+		Type:           int32(conv.GetType()),
+	}, nil
+}
+
+func (p *Phone) handleListMessages(req *Request) (proto.Message, error) {
+	var payload gmproto.ListMessagesRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	msgs := make([]*gmproto.Message, len(p.messages[payload.GetConversationID()]))
+	for i, msg := range p.messages[payload.GetConversationID()] {
+		msgs[i] = proto.Clone(msg).(*gmproto.Message)
+	}
+	total := int64(len(msgs))
+	page, cursor := paginate(msgs, payload.GetCount(), payload.GetCursor(),
+		(*gmproto.Message).GetMessageID, (*gmproto.Message).GetTimestamp)
+	return &gmproto.ListMessagesResponse{Messages: page, TotalMessages: total, Cursor: cursor}, nil
+}
+
+func (p *Phone) handleSendMessage(req *Request) (proto.Message, error) {
+	var payload gmproto.SendMessageRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	if _, ok := p.conversations[payload.GetConversationID()]; !ok {
+		p.lock.Unlock()
+		return &gmproto.SendMessageResponse{Status: gmproto.SendMessageResponse_FAILURE_2}, nil
+	}
+	msg := &gmproto.Message{
+		MessageID:      strconv.FormatInt(time.Now().UnixNano(), 10),
+		MsgType:        &gmproto.MsgType{Type: 1},
+		MessageStatus:  &gmproto.MessageStatus{Status: gmproto.MessageStatusType_OUTGOING_COMPLETE},
+		Timestamp:      time.Now().UnixMicro(),
+		ConversationID: payload.GetConversationID(),
+		ParticipantID:  "1",
+		MessageInfo:    payload.GetMessagePayload().GetMessageInfo(),
+		TmpID:          payload.GetTmpID(),
+	}
+	if payload.GetReply().GetMessageID() != "" {
+		msg.ReplyMessage = &gmproto.ReplyMessage{
+			MessageID:      payload.GetReply().GetMessageID(),
+			ConversationID: payload.GetConversationID(),
+		}
+	}
+	p.addMessage(msg)
+	p.lock.Unlock()
+	go p.PushUpdate(&gmproto.UpdateEvents{
+		Event: &gmproto.UpdateEvents_MessageEvent{MessageEvent: &gmproto.MessageEvent{
+			Data: []*gmproto.Message{msg},
+		}},
+	})
+	return &gmproto.SendMessageResponse{Status: gmproto.SendMessageResponse_SUCCESS}, nil
+}
+
+func (p *Phone) findMessage(messageID string) (*gmproto.Message, int) {
+	for _, msgs := range p.messages {
+		for i, msg := range msgs {
+			if msg.GetMessageID() == messageID {
+				return msg, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+func (p *Phone) handleSendReaction(req *Request) (proto.Message, error) {
+	var payload gmproto.SendReactionRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	msg, _ := p.findMessage(payload.GetMessageID())
+	if msg == nil {
+		return &gmproto.SendReactionResponse{Success: false}, nil
+	}
+	switch payload.GetAction() {
+	case gmproto.SendReactionRequest_ADD, gmproto.SendReactionRequest_SWITCH:
+		msg.Reactions = []*gmproto.ReactionEntry{{Data: payload.GetReactionData(), ParticipantIDs: []string{"1"}}}
+	case gmproto.SendReactionRequest_REMOVE:
+		msg.Reactions = nil
+	}
+	return &gmproto.SendReactionResponse{Success: true}, nil
+}
+
+func (p *Phone) handleDeleteMessage(req *Request) (proto.Message, error) {
+	var payload gmproto.DeleteMessageRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	msg, idx := p.findMessage(payload.GetMessageID())
+	if msg == nil {
+		return &gmproto.DeleteMessageResponse{Success: false}, nil
+	}
+	p.messages[msg.GetConversationID()] = slices.Delete(p.messages[msg.GetConversationID()], idx, idx+1)
+	return &gmproto.DeleteMessageResponse{Success: true}, nil
+}
+
+func (p *Phone) handleResendMessage(req *Request) (proto.Message, error) {
+	var payload gmproto.ResendMessageRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	msg, _ := p.findMessage(payload.GetMessageID())
+	switch msg.GetMessageStatus().GetStatus() {
+	case gmproto.MessageStatusType_OUTGOING_FAILED_GENERIC, gmproto.MessageStatusType_OUTGOING_AWAITING_RETRY:
+	default:
+		p.lock.Unlock()
+		return &gmproto.EmptyArr{}, nil
+	}
+	msg.MessageStatus = &gmproto.MessageStatus{Status: gmproto.MessageStatusType_OUTGOING_COMPLETE}
+	updated := proto.Clone(msg).(*gmproto.Message)
+	p.lock.Unlock()
+	go p.PushUpdate(&gmproto.UpdateEvents{
+		Event: &gmproto.UpdateEvents_MessageEvent{MessageEvent: &gmproto.MessageEvent{
+			Data: []*gmproto.Message{updated},
+		}},
+	})
+	return &gmproto.EmptyArr{}, nil
+}
+
+func (p *Phone) handleDownloadMessage(req *Request) (proto.Message, error) {
+	var payload gmproto.DownloadMessageRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	msg, _ := p.findMessage(payload.GetMessageID())
+	if msg.GetMessageStatus().GetStatus() != gmproto.MessageStatusType_INCOMING_YET_TO_MANUAL_DOWNLOAD {
+		p.lock.Unlock()
+		return &gmproto.EmptyArr{}, nil
+	}
+	msg.MessageStatus = &gmproto.MessageStatus{Status: gmproto.MessageStatusType_INCOMING_MANUAL_DOWNLOADING}
+	downloading := proto.Clone(msg).(*gmproto.Message)
+	msg.MessageStatus = &gmproto.MessageStatus{Status: gmproto.MessageStatusType_INCOMING_COMPLETE}
+	downloaded := proto.Clone(msg).(*gmproto.Message)
+	p.lock.Unlock()
+	go func() {
+		for _, update := range []*gmproto.Message{downloading, downloaded} {
+			p.PushUpdate(&gmproto.UpdateEvents{
+				Event: &gmproto.UpdateEvents_MessageEvent{MessageEvent: &gmproto.MessageEvent{
+					Data: []*gmproto.Message{update},
+				}},
+			})
+		}
+	}()
+	return &gmproto.EmptyArr{}, nil
+}
+
+func (p *Phone) handleMessageRead(req *Request) (proto.Message, error) {
+	var payload gmproto.MessageReadRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	if conv, ok := p.conversations[payload.GetConversationID()]; ok {
+		conv.Unread = false
+	}
+	p.lock.Unlock()
+	// The real phone answers with an empty payload
+	return &gmproto.EmptyArr{}, nil
+}
+
+func (p *Phone) handleListContacts(req *Request) (proto.Message, error) {
+	var payload gmproto.ListContactsRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// Contacts are returned in address book order, so the cursor only needs the ID of the last contact
+	contacts := p.contacts
+	if cursor := payload.GetCursor(); cursor != nil {
+		idx := slices.IndexFunc(contacts, func(contact *gmproto.Contact) bool {
+			return contact.GetParticipantID() == cursor.GetLastItemID()
+		})
+		contacts = contacts[idx+1:]
+	}
+	resp := &gmproto.ListContactsResponse{Contacts: slices.Clone(contacts)}
+	if count := int(payload.GetCount()); count > 0 && len(contacts) > count {
+		resp.Contacts = resp.Contacts[:count]
+		resp.Cursor = &gmproto.Cursor{LastItemID: contacts[count-1].GetParticipantID()}
+	}
+	return resp, nil
+}
+
+func (p *Phone) handleListTopContacts(req *Request) (proto.Message, error) {
+	var payload gmproto.ListTopContactsRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	contacts := p.contacts
+	if len(contacts) > int(payload.GetCount()) {
+		contacts = contacts[:payload.GetCount()]
+	}
+	return &gmproto.ListTopContactsResponse{Contacts: slices.Clone(contacts)}, nil
+}
+
+func (p *Phone) handleGetOrCreateConversation(req *Request) (proto.Message, error) {
+	var payload gmproto.GetOrCreateConversationRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(payload.GetNumbers()) == 1 {
+		number := payload.GetNumbers()[0].GetNumber()
+		for _, conv := range p.conversations {
+			if conv.GetIsGroupChat() {
+				continue
+			}
+			for _, part := range conv.GetParticipants() {
+				if !part.GetIsMe() && part.GetID().GetNumber() == number {
+					return &gmproto.GetOrCreateConversationResponse{
+						Conversation: proto.Clone(conv).(*gmproto.Conversation),
+						Status:       gmproto.GetOrCreateConversationResponse_SUCCESS,
+					}, nil
+				}
+			}
+		}
+	} else if len(payload.GetNumbers()) > 1 && payload.GetRCSGroupName() == "" && !payload.GetCreateRCSGroup() {
+		return &gmproto.GetOrCreateConversationResponse{Status: gmproto.GetOrCreateConversationResponse_CREATE_RCS}, nil
+	}
+	conv := &gmproto.Conversation{
+		ConversationID:       strconv.Itoa(len(p.conversations) + 1),
+		Name:                 payload.GetRCSGroupName(),
+		LastMessageTimestamp: time.Now().UnixMicro(),
+		IsGroupChat:          len(payload.GetNumbers()) > 1,
+		DefaultOutgoingID:    "1",
+		Status:               gmproto.ConversationStatus_ACTIVE,
+		Type:                 gmproto.ConversationType_SMS,
+		Participants: []*gmproto.Participant{{
+			ID:   &gmproto.SmallInfo{Type: gmproto.IdentifierType_PHONE, ParticipantID: "1"},
+			IsMe: true,
+		}},
+	}
+	if payload.GetCreateRCSGroup() {
+		conv.Type = gmproto.ConversationType_RCS
+	}
+	for i, number := range payload.GetNumbers() {
+		participantID := strconv.Itoa(i + 2)
+		conv.Participants = append(conv.Participants, &gmproto.Participant{
+			ID:              &gmproto.SmallInfo{Type: gmproto.IdentifierType_PHONE, Number: number.GetNumber(), ParticipantID: participantID},
+			FullName:        number.GetNumber(),
+			FormattedNumber: number.GetNumber(),
+			IsVisible:       true,
+		})
+		conv.OtherParticipants = append(conv.OtherParticipants, participantID)
+	}
+	p.conversations[conv.ConversationID] = conv
+	return &gmproto.GetOrCreateConversationResponse{
+		Conversation: proto.Clone(conv).(*gmproto.Conversation),
+		Status:       gmproto.GetOrCreateConversationResponse_SUCCESS,
+	}, nil
+}
+
+func (p *Phone) handleUpdateConversation(req *Request) (proto.Message, error) {
+	var payload gmproto.UpdateConversationRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	convID := cmp.Or(payload.GetConversationID(), payload.GetUpdateData().GetConversationID(), payload.GetDeleteData().GetConversationID())
+	p.lock.Lock()
+	conv, ok := p.conversations[convID]
+	if !ok {
+		p.lock.Unlock()
+		return &gmproto.UpdateConversationResponse{Success: false}, nil
+	}
+	switch payload.GetAction() {
+	case gmproto.ConversationActionStatus_DELETE:
+		conv.Status = gmproto.ConversationStatus_DELETED
+		delete(p.conversations, convID)
+		delete(p.messages, convID)
+	case gmproto.ConversationActionStatus_BLOCK, gmproto.ConversationActionStatus_BLOCK_AND_REPORT:
+		conv.Status = gmproto.ConversationStatus_BLOCKED_FOLDER
+	case gmproto.ConversationActionStatus_UNBLOCK:
+		conv.Status = gmproto.ConversationStatus_ACTIVE
+	default:
+		switch data := payload.GetUpdateData().GetData().(type) {
+		case *gmproto.UpdateConversationData_Status:
+			if data.Status != gmproto.ConversationStatus_UNKNOWN_CONVERSATION_STATUS {
+				conv.Status = data.Status
+			}
+		case *gmproto.UpdateConversationData_Mute:
+			if data.Mute == gmproto.ConversationMuteStatus_MUTE {
+				conv.Muted = &gmproto.Muted{IsMuted: 1}
+			} else {
+				conv.Muted = nil
+			}
+		}
+	}
+	conv = proto.Clone(conv).(*gmproto.Conversation)
+	p.lock.Unlock()
+	go p.pushConversation(conv)
+	return &gmproto.UpdateConversationResponse{Success: true}, nil
+}
+
+func (p *Phone) pushConversation(conv *gmproto.Conversation) {
+	p.PushUpdate(&gmproto.UpdateEvents{
+		Event: &gmproto.UpdateEvents_ConversationEvent{ConversationEvent: &gmproto.ConversationEvent{
+			Data: []*gmproto.Conversation{conv},
+		}},
+	})
+}
+
+func (p *Phone) getRCSGroup(conversationID string) *gmproto.Conversation {
+	conv, ok := p.conversations[conversationID]
+	if !ok || !conv.GetIsGroupChat() || conv.GetType() != gmproto.ConversationType_RCS || conv.GetReadOnly() {
+		return nil
+	}
+	return conv
+}
+
+func (p *Phone) handleLeaveRCSGroup(req *Request) (proto.Message, error) {
+	var payload gmproto.LeaveRCSGroupRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	conv := p.getRCSGroup(payload.GetConversationID())
+	if conv == nil {
+		p.lock.Unlock()
+		return &gmproto.LeaveRCSGroupResponse{Success: false}, nil
+	}
+	// The phone keeps the conversation after leaving, but without the self participant
+	conv.ReadOnly = true
+	conv.Participants = slices.DeleteFunc(conv.Participants, (*gmproto.Participant).GetIsMe)
+	conv = proto.Clone(conv).(*gmproto.Conversation)
+	p.lock.Unlock()
+	go p.pushConversation(conv)
+	return &gmproto.LeaveRCSGroupResponse{Success: true}, nil
+}
+
+func (p *Phone) handleAddParticipantToRCSGroup(req *Request) (proto.Message, error) {
+	var payload gmproto.AddParticipantToRCSGroupRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	conv := p.getRCSGroup(payload.GetConversationID())
+	if conv == nil {
+		p.lock.Unlock()
+		return &gmproto.AddParticipantToRCSGroupResponse{Success: false}, nil
+	}
+	for _, number := range payload.GetNumbers() {
+		if !p.rcsNumbers[number.GetNumber()] {
+			p.lock.Unlock()
+			return &gmproto.AddParticipantToRCSGroupResponse{Success: false}, nil
+		}
+	}
+	for _, number := range payload.GetNumbers() {
+		alreadyMember := slices.ContainsFunc(conv.Participants, func(part *gmproto.Participant) bool {
+			return part.GetID().GetNumber() == number.GetNumber()
+		})
+		if alreadyMember {
+			continue
+		}
+		participantID := strconv.Itoa(len(conv.OtherParticipants) + 2)
+		conv.Participants = append(conv.Participants, &gmproto.Participant{
+			ID:              &gmproto.SmallInfo{Type: gmproto.IdentifierType_PHONE, Number: number.GetNumber(), ParticipantID: participantID},
+			FullName:        number.GetNumber(),
+			FormattedNumber: number.GetNumber(),
+			IsVisible:       true,
+		})
+		conv.OtherParticipants = append(conv.OtherParticipants, participantID)
+	}
+	conv = proto.Clone(conv).(*gmproto.Conversation)
+	p.lock.Unlock()
+	go p.pushConversation(conv)
+	return &gmproto.AddParticipantToRCSGroupResponse{Success: true, Conversation: conv}, nil
+}
+
+func (p *Phone) handleGetContactRCSGroupStatus(req *Request) (proto.Message, error) {
+	var payload gmproto.GetContactRCSGroupStatusRequest
+	if err := req.Unmarshal(&payload); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return &gmproto.GetContactRCSGroupStatusResponse{RCSGroupCapable: p.rcsNumbers[payload.GetNumber().GetNumber()]}, nil
+}
+
+func (p *Phone) handleGetThumbnail(_ *Request) (proto.Message, error) {
+	return &gmproto.GetThumbnailResponse{}, nil
+}
+
+// RevokePairing emulates the user unpairing the client from the phone.
+func (p *Phone) RevokePairing() {
+	p.server.pushRPC(gmproto.BugleRoute_PairEvent, &gmproto.RPCPairData{
+		Event: &gmproto.RPCPairData_Revoked{Revoked: &gmproto.RevokePairData{RevokedDevice: p.server.Browser()}},
+	})
+}
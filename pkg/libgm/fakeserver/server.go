@@ -0,0 +1,515 @@
+// Package fakeserver implements an in-process fake of the Google Messages backend and a scripted phone,
+// so that libgm can be tested end-to-end without a real device.
+package fakeserver
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/pblite"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/util"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypePBLite   = "application/json+protobuf"
+)
+
+// DefaultTokenTTL is the lifetime of tachyon tokens issued by the fake server.
+const DefaultTokenTTL = 24 * time.Hour
+
+// Server is a fake Google Messages backend. It serves the pblite/protobuf endpoints that libgm uses
+// and forwards requests addressed to the phone to the attached Phone.
+type Server struct {
+	Phone *Phone
+	Log   zerolog.Logger
+
+	// ChunkGranularity is the value returned in x-goog-upload-chunk-granularity for media uploads.
+	ChunkGranularity int64
+
+	srv *httptest.Server
+
+	streams     map[*longPollStream]struct{}
+	streamsLock sync.Mutex
+
+	acks     []string
+	unacked  []*gmproto.IncomingRPCMessage
+	failAcks int
+	acksLock sync.Mutex
+
+	tokenRefreshes int
+	browser        *gmproto.Device
+	pairingKey     []byte
+	stateLock      sync.Mutex
+
+	media     map[string][]byte
+	uploads   map[string]*pendingUpload
+	mediaLock sync.Mutex
+	// number of upcoming upload requests to fail, see FailUploadChunks
+	failUploads int
+	// number of upcoming upload requests to reject, see RejectUploadChunks
+	rejectUploads int
+}
+
+// New starts a new fake server listening on a random local port.
+func New(log zerolog.Logger) *Server {
+	s := &Server{
+		Log:              log,
+		ChunkGranularity: 256 * 1024,
+		streams:          make(map[*longPollStream]struct{}),
+		media:            make(map[string][]byte),
+		uploads:          make(map[string]*pendingUpload),
+		browser: &gmproto.Device{
+			UserID:   1,
+			SourceID: randomHex(8),
+			Network:  util.QRNetwork,
+		},
+	}
+	s.Phone = newPhone(s)
+
+	endpoints := util.NewEndpoints("", "", "")
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+endpoints.ReceiveMessagesURL(false), s.handleReceiveMessages)
+	mux.HandleFunc("POST "+endpoints.SendMessageURL(false), s.handleSendMessage)
+	mux.HandleFunc("POST "+endpoints.AckMessagesURL(false), s.handleAckMessages)
+	mux.HandleFunc("POST "+endpoints.RegisterRefreshURL(), s.handleRegisterRefresh)
+	mux.HandleFunc("POST "+endpoints.RegisterPhoneRelayURL(), s.handleRegisterPhoneRelay)
+	mux.HandleFunc("POST "+endpoints.RefreshPhoneRelayURL(), s.handleRefreshPhoneRelay)
+	mux.HandleFunc("POST "+endpoints.RevokeRelayPairingURL(), s.handleRevokeRelayPairing)
+	mux.HandleFunc("GET "+endpoints.ConfigURL(), s.handleConfig)
+	mux.HandleFunc("POST "+endpoints.UploadMediaURL(), s.handleStartUpload)
+	mux.HandleFunc("GET "+endpoints.UploadMediaURL(), s.handleDownload)
+	mux.HandleFunc("POST /upload/{uploadID}", s.handleUploadChunk)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Endpoints returns an endpoint set that points every service at this server.
+// Pass it to [libgm.Client.SetEndpoints] to use the fake server.
+func (s *Server) Endpoints() *util.Endpoints {
+	return util.NewEndpoints(s.srv.URL, s.srv.URL, s.srv.URL)
+}
+
+// Close drops all long-polling connections and shuts down the server.
+func (s *Server) Close() {
+	s.DropLongPolls()
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// Browser returns the device that the server assigned to the paired browser.
+func (s *Server) Browser() *gmproto.Device {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.browser
+}
+
+// TokenRefreshes returns the number of RegisterRefresh calls the server has received.
+func (s *Server) TokenRefreshes() int {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.tokenRefreshes
+}
+
+// FailAcks makes the next count ack requests fail with HTTP 503.
+func (s *Server) FailAcks(count int) {
+	s.acksLock.Lock()
+	s.failAcks = count
+	s.acksLock.Unlock()
+}
+
+// Unacked returns the IDs of pushed messages that the client hasn't acknowledged yet.
+// These are redelivered at the start of every new long-polling request.
+func (s *Server) Unacked() []string {
+	s.acksLock.Lock()
+	defer s.acksLock.Unlock()
+	ids := make([]string, len(s.unacked))
+	for i, msg := range s.unacked {
+		ids[i] = msg.GetResponseID()
+	}
+	return ids
+}
+
+// Acks returns the IDs of all messages that the client has acknowledged.
+func (s *Server) Acks() []string {
+	s.acksLock.Lock()
+	defer s.acksLock.Unlock()
+	return append([]string(nil), s.acks...)
+}
+
+// NewTokenData creates a new random tachyon token.
+func NewTokenData() *gmproto.TokenData {
+	return &gmproto.TokenData{
+		TachyonAuthToken: randomBytes(32),
+		TTL:              DefaultTokenTTL.Microseconds(),
+	}
+}
+
+type longPollStream struct {
+	frames chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (lps *longPollStream) close() {
+	lps.once.Do(func() {
+		close(lps.closed)
+	})
+}
+
+// DropLongPolls ends all currently open long-polling requests cleanly, which makes the client reconnect.
+func (s *Server) DropLongPolls() {
+	s.streamsLock.Lock()
+	defer s.streamsLock.Unlock()
+	for stream := range s.streams {
+		stream.close()
+		delete(s.streams, stream)
+	}
+}
+
+// LongPollCount returns the number of currently open long-polling requests.
+func (s *Server) LongPollCount() int {
+	s.streamsLock.Lock()
+	defer s.streamsLock.Unlock()
+	return len(s.streams)
+}
+
+func (s *Server) pushPayload(payload *gmproto.LongPollingPayload) {
+	data, err := pblite.Marshal(payload)
+	if err != nil {
+		s.Log.Err(err).Msg("Failed to marshal long polling payload")
+		return
+	}
+	s.streamsLock.Lock()
+	defer s.streamsLock.Unlock()
+	if len(s.streams) == 0 {
+		s.Log.Warn().Msg("No long polling connections to push payload to")
+	}
+	for stream := range s.streams {
+		select {
+		case stream.frames <- data:
+		case <-stream.closed:
+		}
+	}
+}
+
+// Push sends an RPC message to all connected clients. The message will be redelivered on new
+// long-polling requests until the client acknowledges it.
+func (s *Server) Push(msg *gmproto.IncomingRPCMessage) {
+	s.acksLock.Lock()
+	defer s.acksLock.Unlock()
+	s.unacked = append(s.unacked, msg)
+	s.pushPayload(&gmproto.LongPollingPayload{Data: msg})
+}
+
+func (s *Server) pushRPC(route gmproto.BugleRoute, messageData proto.Message) string {
+	data, err := proto.Marshal(messageData)
+	if err != nil {
+		s.Log.Err(err).Msg("Failed to marshal RPC message data")
+		return ""
+	}
+	now := time.Now()
+	responseID := uuid.NewString()
+	s.Push(&gmproto.IncomingRPCMessage{
+		ResponseID:    responseID,
+		BugleRoute:    route,
+		StartExecute:  uint64(now.UnixMicro()),
+		FinishExecute: uint64(now.UnixMicro()),
+		MessageType:   gmproto.MessageType_BUGLE_MESSAGE,
+		Mobile:        s.Phone.Device,
+		Browser:       s.Browser(),
+		MessageData:   data,
+		Timestamp:     strconv.FormatInt(now.UnixMicro(), 10),
+	})
+	return responseID
+}
+
+func (s *Server) handleReceiveMessages(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.ReceiveMessagesRequest
+	if !s.readRequest(w, r, &req) {
+		return
+	} else if len(req.GetAuth().GetTachyonAuthToken()) == 0 {
+		s.writeError(w, http.StatusUnauthorized, "Missing tachyon auth token")
+		return
+	}
+	stream := &longPollStream{
+		frames: make(chan []byte, 16),
+		closed: make(chan struct{}),
+	}
+	// Register the stream while holding the ack lock, so that messages pushed concurrently
+	// are either in the redelivery list or sent to the stream, but not both.
+	s.acksLock.Lock()
+	redeliver := slices.Clone(s.unacked)
+	s.streamsLock.Lock()
+	s.streams[stream] = struct{}{}
+	s.streamsLock.Unlock()
+	s.acksLock.Unlock()
+	defer func() {
+		stream.close()
+		s.streamsLock.Lock()
+		delete(s.streams, stream)
+		s.streamsLock.Unlock()
+	}()
+
+	flusher := w.(http.Flusher)
+	w.Header().Set("Content-Type", contentTypePBLite)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, "[[")
+	first := true
+	writeFrame := func(data []byte) bool {
+		if !first {
+			data = append([]byte{','}, data...)
+		}
+		first = false
+		_, err := w.Write(data)
+		flusher.Flush()
+		return err == nil
+	}
+	startAck, _ := pblite.Marshal(&gmproto.LongPollingPayload{Ack: &gmproto.StartAckMessage{Count: proto.Int32(int32(len(redeliver)))}})
+	startRead, _ := pblite.Marshal(&gmproto.LongPollingPayload{StartRead: &gmproto.EmptyArr{}})
+	if !writeFrame(startAck) || !writeFrame(startRead) {
+		return
+	}
+	for _, msg := range redeliver {
+		data, _ := pblite.Marshal(&gmproto.LongPollingPayload{Data: msg})
+		if !writeFrame(data) {
+			return
+		}
+	}
+	for {
+		select {
+		case data := <-stream.frames:
+			if !writeFrame(data) {
+				return
+			}
+		case <-stream.closed:
+			_, _ = io.WriteString(w, "]]")
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.OutgoingRPCMessage
+	if !s.readRequest(w, r, &req) {
+		return
+	}
+	var data gmproto.OutgoingRPCData
+	err := proto.Unmarshal(req.GetData().GetMessageData(), &data)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse message data: %v", err))
+		return
+	}
+	s.writeResponse(w, r, &gmproto.OutgoingRPCResponse{
+		SomeIdentifier: &gmproto.OutgoingRPCResponse_SomeIdentifier{SomeNumber: "1"},
+		Timestamp:      proto.String(strconv.FormatInt(time.Now().UnixMicro(), 10)),
+	})
+	go s.Phone.handleRequest(&req, &data)
+}
+
+func (s *Server) handleAckMessages(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.AckMessageRequest
+	if !s.readRequest(w, r, &req) {
+		return
+	}
+	s.acksLock.Lock()
+	if s.failAcks > 0 {
+		s.failAcks--
+		s.acksLock.Unlock()
+		s.writeError(w, http.StatusServiceUnavailable, "Ack failure requested by test")
+		return
+	}
+	for _, ack := range req.GetAcks() {
+		s.acks = append(s.acks, ack.GetRequestID())
+		s.unacked = slices.DeleteFunc(s.unacked, func(msg *gmproto.IncomingRPCMessage) bool {
+			return msg.GetResponseID() == ack.GetRequestID()
+		})
+	}
+	s.acksLock.Unlock()
+	s.writeResponse(w, r, &gmproto.OutgoingRPCResponse{})
+}
+
+func (s *Server) handleRegisterRefresh(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.RegisterRefreshRequest
+	if !s.readRequest(w, r, &req) {
+		return
+	}
+	s.stateLock.Lock()
+	s.tokenRefreshes++
+	s.stateLock.Unlock()
+	s.writeResponse(w, r, &gmproto.RegisterRefreshResponse{TokenData: NewTokenData()})
+}
+
+func (s *Server) handleRegisterPhoneRelay(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.AuthenticationContainer
+	if !s.readRequest(w, r, &req) {
+		return
+	}
+	s.stateLock.Lock()
+	s.pairingKey = randomBytes(32)
+	pairingKey := s.pairingKey
+	browser := s.browser
+	s.stateLock.Unlock()
+	s.writeResponse(w, r, &gmproto.RegisterPhoneRelayResponse{
+		Coordinates: &gmproto.CoordinateMessage{Coord1: 1},
+		Browser:     browser,
+		PairingKey:  pairingKey,
+		ValidFor:    (5 * time.Minute).Microseconds(),
+		AuthKeyData: NewTokenData(),
+		ResponseID:  uuid.NewString(),
+	})
+}
+
+func (s *Server) handleRefreshPhoneRelay(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.AuthenticationContainer
+	if !s.readRequest(w, r, &req) {
+		return
+	}
+	s.stateLock.Lock()
+	s.pairingKey = randomBytes(32)
+	pairingKey := s.pairingKey
+	s.stateLock.Unlock()
+	s.writeResponse(w, r, &gmproto.RefreshPhoneRelayResponse{
+		Coordinates: &gmproto.CoordinateMessage{Coord1: 1},
+		PairKey:     pairingKey,
+		ValidFor:    (5 * time.Minute).Microseconds(),
+	})
+}
+
+func (s *Server) handleRevokeRelayPairing(w http.ResponseWriter, r *http.Request) {
+	var req gmproto.RevokeRelayPairingRequest
+	if !s.readRequest(w, r, &req) {
+		return
+	}
+	s.writeResponse(w, r, &gmproto.RevokeRelayPairingResponse{})
+	go s.pushRPC(gmproto.BugleRoute_PairEvent, &gmproto.RPCPairData{
+		Event: &gmproto.RPCPairData_Revoked{Revoked: &gmproto.RevokePairData{RevokedDevice: req.GetBrowser()}},
+	})
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	version := util.ConfigMessage
+	data, _ := pblite.Marshal(&gmproto.Config{
+		ClientVersion: fmt.Sprintf("%04d%02d%02d00_00_RC00", version.Year, version.Month, version.Day),
+		ServerVersion: "fakeserver",
+		DeviceInfo: &gmproto.Config_DeviceInfo{
+			DeviceID: uuid.NewString(),
+		},
+		CountryCode:   "US",
+		GeneratedAtMS: time.Now().UnixMilli(),
+	})
+	w.Header().Set("Content-Type", contentTypePBLite)
+	_, _ = w.Write(data)
+}
+
+// PairWithQR emulates the phone scanning the given QR code URL and completing pairing.
+func (s *Server) PairWithQR(qrURL string) error {
+	_, encoded, ok := strings.Cut(qrURL, "#?c=")
+	if !ok {
+		return fmt.Errorf("QR code URL doesn't contain data")
+	}
+	rawData, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("failed to decode QR data: %w", err)
+	}
+	var urlData gmproto.URLData
+	err = proto.Unmarshal(rawData, &urlData)
+	if err != nil {
+		return fmt.Errorf("failed to parse QR data: %w", err)
+	}
+	s.stateLock.Lock()
+	expectedKey := s.pairingKey
+	browser := s.browser
+	s.stateLock.Unlock()
+	if expectedKey == nil || string(expectedKey) != string(urlData.GetPairingKey()) {
+		return fmt.Errorf("unknown pairing key")
+	}
+	s.Phone.setKeys(urlData.GetAESKey(), urlData.GetHMACKey())
+	s.pushRPC(gmproto.BugleRoute_PairEvent, &gmproto.RPCPairData{
+		Event: &gmproto.RPCPairData_Paired{Paired: &gmproto.PairedData{
+			Mobile:    s.Phone.Device,
+			TokenData: NewTokenData(),
+			Browser:   browser,
+		}},
+	})
+	return nil
+}
+
+func (s *Server) readRequest(w http.ResponseWriter, r *http.Request, into proto.Message) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read body: %v", err))
+		return false
+	}
+	switch r.Header.Get("Content-Type") {
+	case contentTypeProtobuf:
+		err = proto.Unmarshal(body, into)
+	case contentTypePBLite:
+		err = pblite.Unmarshal(body, into)
+	default:
+		err = fmt.Errorf("unsupported content type %q", r.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse body: %v", err))
+		return false
+	}
+	return true
+}
+
+func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, data proto.Message) {
+	var body []byte
+	var err error
+	contentType := r.Header.Get("Content-Type")
+	if contentType == contentTypeProtobuf {
+		body, err = proto.Marshal(data)
+	} else {
+		contentType = contentTypePBLite
+		body, err = pblite.Marshal(data)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to marshal response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(body)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	s.Log.Warn().Int("status_code", status).Str("error", message).Msg("Returning error response")
+	body, _ := pblite.Marshal(&gmproto.ErrorResponse{Type: int64(status), Message: message})
+	w.Header().Set("Content-Type", contentTypePBLite)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	_, _ = rand.Read(data)
+	return data
+}
+
+func randomHex(n int) string {
+	return fmt.Sprintf("%x", randomBytes(n))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+var actionFilter = flag.String("action", "", "Only show entries with the given action types (comma-separated, e.g. SEND_MESSAGE,GET_UPDATES)")
+var conversationFilter = flag.String("conversation", "", "Only show entries that mention the given conversation ID")
+var directionFilter = flag.String("direction", "", "Only show incoming or outgoing entries")
+var replay = flag.Bool("replay", false, "Replay incoming entries into a client and print the resulting events instead of the raw entries")
+var verbose = flag.Bool("verbose", false, "Enable libgm debug logs when replaying")
+
+var marshalOpts = protojson.MarshalOptions{Multiline: true, Indent: "  "}
+
+func main() {
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(os.Stderr, "Usage: gmcapture [flags] <capture.jsonl>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var actions []gmproto.ActionType
+	if *actionFilter != "" {
+		for _, name := range strings.Split(*actionFilter, ",") {
+			val, ok := gmproto.ActionType_value[strings.ToUpper(strings.TrimSpace(name))]
+			if !ok {
+				_, _ = fmt.Fprintln(os.Stderr, "Unknown action type", name)
+				os.Exit(2)
+			}
+			actions = append(actions, gmproto.ActionType(val))
+		}
+	}
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Failed to open capture:", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	var cli *libgm.Client
+	if *replay {
+		log := zerolog.Nop()
+		if *verbose {
+			log = zerolog.New(zerolog.NewConsoleWriter(func(w *zerolog.ConsoleWriter) {
+				w.Out = os.Stderr
+			})).Level(zerolog.DebugLevel)
+		}
+		cli = libgm.NewClient(libgm.NewAuthData(), nil, log)
+		cli.SetEventHandler(printEvent)
+	}
+
+	for entry, err := range libgm.ReadCapture(file) {
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		if *directionFilter != "" && string(entry.Direction) != *directionFilter {
+			continue
+		} else if len(actions) > 0 && !slices.Contains(actions, entry.Action) {
+			continue
+		}
+		payload, err := entry.DecodePayload()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Failed to decode payload of %s: %v\n", entry.RequestID, err)
+		}
+		if *conversationFilter != "" && !slices.Contains(findConversationIDs(payload), *conversationFilter) {
+			continue
+		}
+		if cli != nil {
+			err = cli.Replay(entry)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Failed to replay %s: %v\n", entry.MessageID, err)
+			}
+		} else {
+			printEntry(entry, payload)
+		}
+	}
+}
+
+func printEntry(entry *libgm.CaptureEntry, payload proto.Message) {
+	arrow := "->"
+	if entry.Direction == libgm.CaptureIncoming {
+		arrow = "<-"
+	}
+	fmt.Printf("%s %s %s", entry.Timestamp.Format("2006-01-02 15:04:05.000"), arrow, entry.Action)
+	if entry.RequestID != "" {
+		fmt.Printf(" request_id=%s", entry.RequestID)
+	}
+	if entry.MessageID != "" {
+		fmt.Printf(" message_id=%s", entry.MessageID)
+	}
+	if entry.BugleRoute != gmproto.BugleRoute_DataEvent {
+		fmt.Printf(" route=%s", entry.BugleRoute)
+	}
+	fmt.Println()
+	if entry.Error != "" {
+		fmt.Println("  error:", entry.Error)
+	}
+	if payload != nil {
+		fmt.Printf("%s %s\n", payload.ProtoReflect().Descriptor().FullName(), marshalOpts.Format(payload))
+	} else if entry.Decrypted != nil {
+		fmt.Printf("unknown payload (%d bytes)\n", len(entry.Decrypted))
+	}
+	fmt.Println()
+}
+
+func printEvent(evt any) {
+	fmt.Printf("%T\n", evt)
+	switch typedEvt := evt.(type) {
+	case *libgm.WrappedMessage:
+		fmt.Println(marshalOpts.Format(typedEvt.Message))
+	case proto.Message:
+		fmt.Println(marshalOpts.Format(typedEvt))
+	default:
+		data, _ := json.MarshalIndent(evt, "", "  ")
+		fmt.Println(string(data))
+	}
+	fmt.Println()
+}
+
+// findConversationIDs returns the values of all fields named conversationID anywhere in the message.
+func findConversationIDs(msg proto.Message) (ids []string) {
+	if msg == nil {
+		return nil
+	}
+	var walk func(m protoreflect.Message)
+	walk = func(m protoreflect.Message) {
+		m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			switch {
+			case fd.Kind() == protoreflect.StringKind && fd.Name() == "conversationID" && !fd.IsList():
+				ids = append(ids, v.String())
+			case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+				list := v.List()
+				for i := 0; i < list.Len(); i++ {
+					walk(list.Get(i).Message())
+				}
+			case fd.Kind() == protoreflect.MessageKind && !fd.IsMap():
+				walk(v.Message())
+			}
+			return true
+		})
+	}
+	walk(msg.ProtoReflect())
+	return
+}
@@ -1183,9 +1183,10 @@ func (x *ListMessagesResponse) GetCursor() *Cursor {
 
 type ListContactsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	I1            int32                  `protobuf:"varint,5,opt,name=i1,proto3" json:"i1,omitempty"` // = 1
-	I2            int32                  `protobuf:"varint,6,opt,name=i2,proto3" json:"i2,omitempty"` // = 350
-	I3            int32                  `protobuf:"varint,7,opt,name=i3,proto3" json:"i3,omitempty"` // = 50
+	I1            int32                  `protobuf:"varint,5,opt,name=i1,proto3" json:"i1,omitempty"`              // = 1
+	Count         int32                  `protobuf:"varint,6,opt,name=count,proto3" json:"count,omitempty"`        // maximum number of contacts in the page, the web app uses 350
+	I3            int32                  `protobuf:"varint,7,opt,name=i3,proto3" json:"i3,omitempty"`              // = 50
+	Cursor        *Cursor                `protobuf:"bytes,8,opt,name=cursor,proto3,oneof" json:"cursor,omitempty"` // unverified guess
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1227,9 +1228,9 @@ func (x *ListContactsRequest) GetI1() int32 {
 	return 0
 }
 
-func (x *ListContactsRequest) GetI2() int32 {
+func (x *ListContactsRequest) GetCount() int32 {
 	if x != nil {
-		return x.I2
+		return x.Count
 	}
 	return 0
 }
@@ -1241,6 +1242,13 @@ func (x *ListContactsRequest) GetI3() int32 {
 	return 0
 }
 
+func (x *ListContactsRequest) GetCursor() *Cursor {
+	if x != nil {
+		return x.Cursor
+	}
+	return nil
+}
+
 type ListTopContactsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Count         int32                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
@@ -1286,8 +1294,11 @@ func (x *ListTopContactsRequest) GetCount() int32 {
 }
 
 type ListContactsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Contacts      []*Contact             `protobuf:"bytes,2,rep,name=contacts,proto3" json:"contacts,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Contacts []*Contact             `protobuf:"bytes,2,rep,name=contacts,proto3" json:"contacts,omitempty"`
+	// Only set if there are more contacts. The item ID is the participant ID of the last contact.
+	// The field number is an unverified guess.
+	Cursor        *Cursor `protobuf:"bytes,5,opt,name=cursor,proto3,oneof" json:"cursor,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -1329,6 +1340,13 @@ func (x *ListContactsResponse) GetContacts() []*Contact {
 	return nil
 }
 
+func (x *ListContactsResponse) GetCursor() *Cursor {
+	if x != nil {
+		return x.Cursor
+	}
+	return nil
+}
+
 type ListTopContactsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Contacts      []*Contact             `protobuf:"bytes,1,rep,name=contacts,proto3" json:"contacts,omitempty"`
@@ -2825,6 +2843,334 @@ func (x *ResendMessageRequest) GetMessageID() string {
 	return ""
 }
 
+// The field number is an unverified guess based on ResendMessageRequest.
+type DownloadMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageID     string                 `protobuf:"bytes,2,opt,name=messageID,proto3" json:"messageID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadMessageRequest) Reset() {
+	*x = DownloadMessageRequest{}
+	mi := &file_client_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadMessageRequest) ProtoMessage() {}
+
+func (x *DownloadMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadMessageRequest.ProtoReflect.Descriptor instead.
+func (*DownloadMessageRequest) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *DownloadMessageRequest) GetMessageID() string {
+	if x != nil {
+		return x.MessageID
+	}
+	return ""
+}
+
+// The field numbers of the RCS group messages below are guesses that haven't been verified against captured traffic yet.
+type LeaveRCSGroupRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationID string                 `protobuf:"bytes,2,opt,name=conversationID,proto3" json:"conversationID,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *LeaveRCSGroupRequest) Reset() {
+	*x = LeaveRCSGroupRequest{}
+	mi := &file_client_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaveRCSGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRCSGroupRequest) ProtoMessage() {}
+
+func (x *LeaveRCSGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRCSGroupRequest.ProtoReflect.Descriptor instead.
+func (*LeaveRCSGroupRequest) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *LeaveRCSGroupRequest) GetConversationID() string {
+	if x != nil {
+		return x.ConversationID
+	}
+	return ""
+}
+
+type LeaveRCSGroupResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LeaveRCSGroupResponse) Reset() {
+	*x = LeaveRCSGroupResponse{}
+	mi := &file_client_proto_msgTypes[48]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaveRCSGroupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRCSGroupResponse) ProtoMessage() {}
+
+func (x *LeaveRCSGroupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[48]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRCSGroupResponse.ProtoReflect.Descriptor instead.
+func (*LeaveRCSGroupResponse) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *LeaveRCSGroupResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+type AddParticipantToRCSGroupRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationID string                 `protobuf:"bytes,2,opt,name=conversationID,proto3" json:"conversationID,omitempty"`
+	Numbers        []*ContactNumber       `protobuf:"bytes,3,rep,name=numbers,proto3" json:"numbers,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AddParticipantToRCSGroupRequest) Reset() {
+	*x = AddParticipantToRCSGroupRequest{}
+	mi := &file_client_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddParticipantToRCSGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddParticipantToRCSGroupRequest) ProtoMessage() {}
+
+func (x *AddParticipantToRCSGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[49]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddParticipantToRCSGroupRequest.ProtoReflect.Descriptor instead.
+func (*AddParticipantToRCSGroupRequest) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *AddParticipantToRCSGroupRequest) GetConversationID() string {
+	if x != nil {
+		return x.ConversationID
+	}
+	return ""
+}
+
+func (x *AddParticipantToRCSGroupRequest) GetNumbers() []*ContactNumber {
+	if x != nil {
+		return x.Numbers
+	}
+	return nil
+}
+
+type AddParticipantToRCSGroupResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	// The updated conversation, also sent as a normal conversation event
+	Conversation  *Conversation `protobuf:"bytes,2,opt,name=conversation,proto3" json:"conversation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddParticipantToRCSGroupResponse) Reset() {
+	*x = AddParticipantToRCSGroupResponse{}
+	mi := &file_client_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddParticipantToRCSGroupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddParticipantToRCSGroupResponse) ProtoMessage() {}
+
+func (x *AddParticipantToRCSGroupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddParticipantToRCSGroupResponse.ProtoReflect.Descriptor instead.
+func (*AddParticipantToRCSGroupResponse) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *AddParticipantToRCSGroupResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *AddParticipantToRCSGroupResponse) GetConversation() *Conversation {
+	if x != nil {
+		return x.Conversation
+	}
+	return nil
+}
+
+type GetContactRCSGroupStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Number        *ContactNumber         `protobuf:"bytes,2,opt,name=number,proto3" json:"number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetContactRCSGroupStatusRequest) Reset() {
+	*x = GetContactRCSGroupStatusRequest{}
+	mi := &file_client_proto_msgTypes[51]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetContactRCSGroupStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetContactRCSGroupStatusRequest) ProtoMessage() {}
+
+func (x *GetContactRCSGroupStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[51]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetContactRCSGroupStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetContactRCSGroupStatusRequest) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *GetContactRCSGroupStatusRequest) GetNumber() *ContactNumber {
+	if x != nil {
+		return x.Number
+	}
+	return nil
+}
+
+type GetContactRCSGroupStatusResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Whether the number can be added to RCS groups. Numbers without RCS can only be in MMS groups.
+	RCSGroupCapable bool `protobuf:"varint,2,opt,name=RCSGroupCapable,proto3" json:"RCSGroupCapable,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *GetContactRCSGroupStatusResponse) Reset() {
+	*x = GetContactRCSGroupStatusResponse{}
+	mi := &file_client_proto_msgTypes[52]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetContactRCSGroupStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetContactRCSGroupStatusResponse) ProtoMessage() {}
+
+func (x *GetContactRCSGroupStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[52]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetContactRCSGroupStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetContactRCSGroupStatusResponse) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *GetContactRCSGroupStatusResponse) GetRCSGroupCapable() bool {
+	if x != nil {
+		return x.RCSGroupCapable
+	}
+	return false
+}
+
 type TypingUpdateRequest struct {
 	state         protoimpl.MessageState    `protogen:"open.v1"`
 	Data          *TypingUpdateRequest_Data `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -2835,7 +3181,7 @@ type TypingUpdateRequest struct {
 
 func (x *TypingUpdateRequest) Reset() {
 	*x = TypingUpdateRequest{}
-	mi := &file_client_proto_msgTypes[46]
+	mi := &file_client_proto_msgTypes[53]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2847,7 +3193,7 @@ func (x *TypingUpdateRequest) String() string {
 func (*TypingUpdateRequest) ProtoMessage() {}
 
 func (x *TypingUpdateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[46]
+	mi := &file_client_proto_msgTypes[53]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2860,7 +3206,7 @@ func (x *TypingUpdateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TypingUpdateRequest.ProtoReflect.Descriptor instead.
 func (*TypingUpdateRequest) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{46}
+	return file_client_proto_rawDescGZIP(), []int{53}
 }
 
 func (x *TypingUpdateRequest) GetData() *TypingUpdateRequest_Data {
@@ -2886,7 +3232,7 @@ type SettingsUpdateRequest struct {
 
 func (x *SettingsUpdateRequest) Reset() {
 	*x = SettingsUpdateRequest{}
-	mi := &file_client_proto_msgTypes[47]
+	mi := &file_client_proto_msgTypes[54]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2898,7 +3244,7 @@ func (x *SettingsUpdateRequest) String() string {
 func (*SettingsUpdateRequest) ProtoMessage() {}
 
 func (x *SettingsUpdateRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[47]
+	mi := &file_client_proto_msgTypes[54]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2911,7 +3257,7 @@ func (x *SettingsUpdateRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SettingsUpdateRequest.ProtoReflect.Descriptor instead.
 func (*SettingsUpdateRequest) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{47}
+	return file_client_proto_rawDescGZIP(), []int{54}
 }
 
 func (x *SettingsUpdateRequest) GetPushSettings() *SettingsUpdateRequest_PushSettings {
@@ -2931,7 +3277,7 @@ type GetFullSizeImageRequest struct {
 
 func (x *GetFullSizeImageRequest) Reset() {
 	*x = GetFullSizeImageRequest{}
-	mi := &file_client_proto_msgTypes[48]
+	mi := &file_client_proto_msgTypes[55]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2943,7 +3289,7 @@ func (x *GetFullSizeImageRequest) String() string {
 func (*GetFullSizeImageRequest) ProtoMessage() {}
 
 func (x *GetFullSizeImageRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[48]
+	mi := &file_client_proto_msgTypes[55]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2956,7 +3302,7 @@ func (x *GetFullSizeImageRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetFullSizeImageRequest.ProtoReflect.Descriptor instead.
 func (*GetFullSizeImageRequest) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{48}
+	return file_client_proto_rawDescGZIP(), []int{55}
 }
 
 func (x *GetFullSizeImageRequest) GetMessageID() string {
@@ -2981,7 +3327,7 @@ type GetFullSizeImageResponse struct {
 
 func (x *GetFullSizeImageResponse) Reset() {
 	*x = GetFullSizeImageResponse{}
-	mi := &file_client_proto_msgTypes[49]
+	mi := &file_client_proto_msgTypes[56]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2993,7 +3339,7 @@ func (x *GetFullSizeImageResponse) String() string {
 func (*GetFullSizeImageResponse) ProtoMessage() {}
 
 func (x *GetFullSizeImageResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[49]
+	mi := &file_client_proto_msgTypes[56]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3006,7 +3352,7 @@ func (x *GetFullSizeImageResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetFullSizeImageResponse.ProtoReflect.Descriptor instead.
 func (*GetFullSizeImageResponse) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{49}
+	return file_client_proto_rawDescGZIP(), []int{56}
 }
 
 type ReceiveMessagesRequest_UnknownEmptyObject1 struct {
@@ -3017,7 +3363,7 @@ type ReceiveMessagesRequest_UnknownEmptyObject1 struct {
 
 func (x *ReceiveMessagesRequest_UnknownEmptyObject1) Reset() {
 	*x = ReceiveMessagesRequest_UnknownEmptyObject1{}
-	mi := &file_client_proto_msgTypes[50]
+	mi := &file_client_proto_msgTypes[57]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3029,7 +3375,7 @@ func (x *ReceiveMessagesRequest_UnknownEmptyObject1) String() string {
 func (*ReceiveMessagesRequest_UnknownEmptyObject1) ProtoMessage() {}
 
 func (x *ReceiveMessagesRequest_UnknownEmptyObject1) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[50]
+	mi := &file_client_proto_msgTypes[57]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3054,7 +3400,7 @@ type ReceiveMessagesRequest_UnknownEmptyObject2 struct {
 
 func (x *ReceiveMessagesRequest_UnknownEmptyObject2) Reset() {
 	*x = ReceiveMessagesRequest_UnknownEmptyObject2{}
-	mi := &file_client_proto_msgTypes[51]
+	mi := &file_client_proto_msgTypes[58]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3066,7 +3412,7 @@ func (x *ReceiveMessagesRequest_UnknownEmptyObject2) String() string {
 func (*ReceiveMessagesRequest_UnknownEmptyObject2) ProtoMessage() {}
 
 func (x *ReceiveMessagesRequest_UnknownEmptyObject2) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[51]
+	mi := &file_client_proto_msgTypes[58]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3099,7 +3445,7 @@ type AckMessageRequest_Message struct {
 
 func (x *AckMessageRequest_Message) Reset() {
 	*x = AckMessageRequest_Message{}
-	mi := &file_client_proto_msgTypes[52]
+	mi := &file_client_proto_msgTypes[59]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3111,7 +3457,7 @@ func (x *AckMessageRequest_Message) String() string {
 func (*AckMessageRequest_Message) ProtoMessage() {}
 
 func (x *AckMessageRequest_Message) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[52]
+	mi := &file_client_proto_msgTypes[59]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3152,7 +3498,7 @@ type GetThumbnailResponse_Thumbnail struct {
 
 func (x *GetThumbnailResponse_Thumbnail) Reset() {
 	*x = GetThumbnailResponse_Thumbnail{}
-	mi := &file_client_proto_msgTypes[53]
+	mi := &file_client_proto_msgTypes[60]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3164,7 +3510,7 @@ func (x *GetThumbnailResponse_Thumbnail) String() string {
 func (*GetThumbnailResponse_Thumbnail) ProtoMessage() {}
 
 func (x *GetThumbnailResponse_Thumbnail) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[53]
+	mi := &file_client_proto_msgTypes[60]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3203,7 +3549,7 @@ type ThumbnailData_MysteriousData struct {
 
 func (x *ThumbnailData_MysteriousData) Reset() {
 	*x = ThumbnailData_MysteriousData{}
-	mi := &file_client_proto_msgTypes[54]
+	mi := &file_client_proto_msgTypes[61]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3215,7 +3561,7 @@ func (x *ThumbnailData_MysteriousData) String() string {
 func (*ThumbnailData_MysteriousData) ProtoMessage() {}
 
 func (x *ThumbnailData_MysteriousData) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[54]
+	mi := &file_client_proto_msgTypes[61]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3248,7 +3594,7 @@ type TypingUpdateRequest_Data struct {
 
 func (x *TypingUpdateRequest_Data) Reset() {
 	*x = TypingUpdateRequest_Data{}
-	mi := &file_client_proto_msgTypes[55]
+	mi := &file_client_proto_msgTypes[62]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3260,7 +3606,7 @@ func (x *TypingUpdateRequest_Data) String() string {
 func (*TypingUpdateRequest_Data) ProtoMessage() {}
 
 func (x *TypingUpdateRequest_Data) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[55]
+	mi := &file_client_proto_msgTypes[62]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3273,7 +3619,7 @@ func (x *TypingUpdateRequest_Data) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TypingUpdateRequest_Data.ProtoReflect.Descriptor instead.
 func (*TypingUpdateRequest_Data) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{46, 0}
+	return file_client_proto_rawDescGZIP(), []int{53, 0}
 }
 
 func (x *TypingUpdateRequest_Data) GetConversationID() string {
@@ -3299,7 +3645,7 @@ type SettingsUpdateRequest_PushSettings struct {
 
 func (x *SettingsUpdateRequest_PushSettings) Reset() {
 	*x = SettingsUpdateRequest_PushSettings{}
-	mi := &file_client_proto_msgTypes[56]
+	mi := &file_client_proto_msgTypes[63]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -3311,7 +3657,7 @@ func (x *SettingsUpdateRequest_PushSettings) String() string {
 func (*SettingsUpdateRequest_PushSettings) ProtoMessage() {}
 
 func (x *SettingsUpdateRequest_PushSettings) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[56]
+	mi := &file_client_proto_msgTypes[63]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -3324,7 +3670,7 @@ func (x *SettingsUpdateRequest_PushSettings) ProtoReflect() protoreflect.Message
 
 // Deprecated: Use SettingsUpdateRequest_PushSettings.ProtoReflect.Descriptor instead.
 func (*SettingsUpdateRequest_PushSettings) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{47, 0}
+	return file_client_proto_rawDescGZIP(), []int{54, 0}
 }
 
 func (x *SettingsUpdateRequest_PushSettings) GetEnabled() bool {
@@ -3410,15 +3756,19 @@ const file_client_proto_rawDesc = "" +
 	"\bmessages\x18\x02 \x03(\v2\x16.conversations.MessageR\bmessages\x12\x1c\n" +
 	"\tsomeBytes\x18\x03 \x01(\fR\tsomeBytes\x12$\n" +
 	"\rtotalMessages\x18\x04 \x01(\x03R\rtotalMessages\x12&\n" +
-	"\x06cursor\x18\x05 \x01(\v2\x0e.client.CursorR\x06cursor\"E\n" +
+	"\x06cursor\x18\x05 \x01(\v2\x0e.client.CursorR\x06cursor\"\x83\x01\n" +
 	"\x13ListContactsRequest\x12\x0e\n" +
-	"\x02i1\x18\x05 \x01(\x05R\x02i1\x12\x0e\n" +
-	"\x02i2\x18\x06 \x01(\x05R\x02i2\x12\x0e\n" +
-	"\x02i3\x18\a \x01(\x05R\x02i3\".\n" +
+	"\x02i1\x18\x05 \x01(\x05R\x02i1\x12\x14\n" +
+	"\x05count\x18\x06 \x01(\x05R\x05count\x12\x0e\n" +
+	"\x02i3\x18\a \x01(\x05R\x02i3\x12+\n" +
+	"\x06cursor\x18\b \x01(\v2\x0e.client.CursorH\x00R\x06cursor\x88\x01\x01B\t\n" +
+	"\a_cursor\".\n" +
 	"\x16ListTopContactsRequest\x12\x14\n" +
-	"\x05count\x18\x01 \x01(\x05R\x05count\"J\n" +
+	"\x05count\x18\x01 \x01(\x05R\x05count\"\x82\x01\n" +
 	"\x14ListContactsResponse\x122\n" +
-	"\bcontacts\x18\x02 \x03(\v2\x16.conversations.ContactR\bcontacts\"M\n" +
+	"\bcontacts\x18\x02 \x03(\v2\x16.conversations.ContactR\bcontacts\x12+\n" +
+	"\x06cursor\x18\x05 \x01(\v2\x0e.client.CursorH\x00R\x06cursor\x88\x01\x01B\t\n" +
+	"\a_cursor\"M\n" +
 	"\x17ListTopContactsResponse\x122\n" +
 	"\bcontacts\x18\x01 \x03(\v2\x16.conversations.ContactR\bcontacts\"\xea\x01\n" +
 	"\x18ListConversationsRequest\x12\x14\n" +
@@ -3544,7 +3894,23 @@ const file_client_proto_rawDesc = "" +
 	"\x14SendReactionResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\"4\n" +
 	"\x14ResendMessageRequest\x12\x1c\n" +
-	"\tmessageID\x18\x02 \x01(\tR\tmessageID\"\xc9\x01\n" +
+	"\tmessageID\x18\x02 \x01(\tR\tmessageID\"6\n" +
+	"\x16DownloadMessageRequest\x12\x1c\n" +
+	"\tmessageID\x18\x02 \x01(\tR\tmessageID\">\n" +
+	"\x14LeaveRCSGroupRequest\x12&\n" +
+	"\x0econversationID\x18\x02 \x01(\tR\x0econversationID\"1\n" +
+	"\x15LeaveRCSGroupResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x81\x01\n" +
+	"\x1fAddParticipantToRCSGroupRequest\x12&\n" +
+	"\x0econversationID\x18\x02 \x01(\tR\x0econversationID\x126\n" +
+	"\anumbers\x18\x03 \x03(\v2\x1c.conversations.ContactNumberR\anumbers\"}\n" +
+	" AddParticipantToRCSGroupResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12?\n" +
+	"\fconversation\x18\x02 \x01(\v2\x1b.conversations.ConversationR\fconversation\"W\n" +
+	"\x1fGetContactRCSGroupStatusRequest\x124\n" +
+	"\x06number\x18\x02 \x01(\v2\x1c.conversations.ContactNumberR\x06number\"L\n" +
+	" GetContactRCSGroupStatusResponse\x12(\n" +
+	"\x0fRCSGroupCapable\x18\x02 \x01(\bR\x0fRCSGroupCapable\"\xc9\x01\n" +
 	"\x13TypingUpdateRequest\x124\n" +
 	"\x04data\x18\x02 \x01(\v2 .client.TypingUpdateRequest.DataR\x04data\x124\n" +
 	"\n" +
@@ -3587,7 +3953,7 @@ func file_client_proto_rawDescGZIP() []byte {
 }
 
 var file_client_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
-var file_client_proto_msgTypes = make([]protoimpl.MessageInfo, 57)
+var file_client_proto_msgTypes = make([]protoimpl.MessageInfo, 64)
 var file_client_proto_goTypes = []any{
 	(ConversationActionStatus)(0),                      // 0: client.ConversationActionStatus
 	(ConversationMuteStatus)(0),                        // 1: client.ConversationMuteStatus
@@ -3641,87 +4007,99 @@ var file_client_proto_goTypes = []any{
 	(*SendReactionRequest)(nil),                        // 49: client.SendReactionRequest
 	(*SendReactionResponse)(nil),                       // 50: client.SendReactionResponse
 	(*ResendMessageRequest)(nil),                       // 51: client.ResendMessageRequest
-	(*TypingUpdateRequest)(nil),                        // 52: client.TypingUpdateRequest
-	(*SettingsUpdateRequest)(nil),                      // 53: client.SettingsUpdateRequest
-	(*GetFullSizeImageRequest)(nil),                    // 54: client.GetFullSizeImageRequest
-	(*GetFullSizeImageResponse)(nil),                   // 55: client.GetFullSizeImageResponse
-	(*ReceiveMessagesRequest_UnknownEmptyObject1)(nil), // 56: client.ReceiveMessagesRequest.UnknownEmptyObject1
-	(*ReceiveMessagesRequest_UnknownEmptyObject2)(nil), // 57: client.ReceiveMessagesRequest.UnknownEmptyObject2
-	(*AckMessageRequest_Message)(nil),                  // 58: client.AckMessageRequest.Message
-	(*GetThumbnailResponse_Thumbnail)(nil),             // 59: client.GetThumbnailResponse.Thumbnail
-	(*ThumbnailData_MysteriousData)(nil),               // 60: client.ThumbnailData.MysteriousData
-	(*TypingUpdateRequest_Data)(nil),                   // 61: client.TypingUpdateRequest.Data
-	(*SettingsUpdateRequest_PushSettings)(nil),         // 62: client.SettingsUpdateRequest.PushSettings
-	(*AuthMessage)(nil),                                // 63: authentication.AuthMessage
-	(*EmptyArr)(nil),                                   // 64: util.EmptyArr
-	(*Device)(nil),                                     // 65: authentication.Device
-	(*Dimensions)(nil),                                 // 66: conversations.Dimensions
-	(*Message)(nil),                                    // 67: conversations.Message
-	(*Contact)(nil),                                    // 68: conversations.Contact
-	(*Conversation)(nil),                               // 69: conversations.Conversation
-	(*ContactNumber)(nil),                              // 70: conversations.ContactNumber
-	(ConversationStatus)(0),                            // 71: conversations.ConversationStatus
-	(*SIMPayload)(nil),                                 // 72: settings.SIMPayload
-	(*MessageInfo)(nil),                                // 73: conversations.MessageInfo
-	(*MessageContent)(nil),                             // 74: conversations.MessageContent
-	(*AccountChangeOrSomethingEvent)(nil),              // 75: events.AccountChangeOrSomethingEvent
-	(*ReactionData)(nil),                               // 76: conversations.ReactionData
+	(*DownloadMessageRequest)(nil),                     // 52: client.DownloadMessageRequest
+	(*LeaveRCSGroupRequest)(nil),                       // 53: client.LeaveRCSGroupRequest
+	(*LeaveRCSGroupResponse)(nil),                      // 54: client.LeaveRCSGroupResponse
+	(*AddParticipantToRCSGroupRequest)(nil),            // 55: client.AddParticipantToRCSGroupRequest
+	(*AddParticipantToRCSGroupResponse)(nil),           // 56: client.AddParticipantToRCSGroupResponse
+	(*GetContactRCSGroupStatusRequest)(nil),            // 57: client.GetContactRCSGroupStatusRequest
+	(*GetContactRCSGroupStatusResponse)(nil),           // 58: client.GetContactRCSGroupStatusResponse
+	(*TypingUpdateRequest)(nil),                        // 59: client.TypingUpdateRequest
+	(*SettingsUpdateRequest)(nil),                      // 60: client.SettingsUpdateRequest
+	(*GetFullSizeImageRequest)(nil),                    // 61: client.GetFullSizeImageRequest
+	(*GetFullSizeImageResponse)(nil),                   // 62: client.GetFullSizeImageResponse
+	(*ReceiveMessagesRequest_UnknownEmptyObject1)(nil), // 63: client.ReceiveMessagesRequest.UnknownEmptyObject1
+	(*ReceiveMessagesRequest_UnknownEmptyObject2)(nil), // 64: client.ReceiveMessagesRequest.UnknownEmptyObject2
+	(*AckMessageRequest_Message)(nil),                  // 65: client.AckMessageRequest.Message
+	(*GetThumbnailResponse_Thumbnail)(nil),             // 66: client.GetThumbnailResponse.Thumbnail
+	(*ThumbnailData_MysteriousData)(nil),               // 67: client.ThumbnailData.MysteriousData
+	(*TypingUpdateRequest_Data)(nil),                   // 68: client.TypingUpdateRequest.Data
+	(*SettingsUpdateRequest_PushSettings)(nil),         // 69: client.SettingsUpdateRequest.PushSettings
+	(*AuthMessage)(nil),                                // 70: authentication.AuthMessage
+	(*EmptyArr)(nil),                                   // 71: util.EmptyArr
+	(*Device)(nil),                                     // 72: authentication.Device
+	(*Dimensions)(nil),                                 // 73: conversations.Dimensions
+	(*Message)(nil),                                    // 74: conversations.Message
+	(*Contact)(nil),                                    // 75: conversations.Contact
+	(*Conversation)(nil),                               // 76: conversations.Conversation
+	(*ContactNumber)(nil),                              // 77: conversations.ContactNumber
+	(ConversationStatus)(0),                            // 78: conversations.ConversationStatus
+	(*SIMPayload)(nil),                                 // 79: settings.SIMPayload
+	(*MessageInfo)(nil),                                // 80: conversations.MessageInfo
+	(*MessageContent)(nil),                             // 81: conversations.MessageContent
+	(*AccountChangeOrSomethingEvent)(nil),              // 82: events.AccountChangeOrSomethingEvent
+	(*ReactionData)(nil),                               // 83: conversations.ReactionData
 }
 var file_client_proto_depIdxs = []int32{
-	63, // 0: client.ReceiveMessagesRequest.auth:type_name -> authentication.AuthMessage
-	57, // 1: client.ReceiveMessagesRequest.unknown:type_name -> client.ReceiveMessagesRequest.UnknownEmptyObject2
-	63, // 2: client.AckMessageRequest.authData:type_name -> authentication.AuthMessage
-	64, // 3: client.AckMessageRequest.emptyArr:type_name -> util.EmptyArr
-	58, // 4: client.AckMessageRequest.acks:type_name -> client.AckMessageRequest.Message
+	70, // 0: client.ReceiveMessagesRequest.auth:type_name -> authentication.AuthMessage
+	64, // 1: client.ReceiveMessagesRequest.unknown:type_name -> client.ReceiveMessagesRequest.UnknownEmptyObject2
+	70, // 2: client.AckMessageRequest.authData:type_name -> authentication.AuthMessage
+	71, // 3: client.AckMessageRequest.emptyArr:type_name -> util.EmptyArr
+	65, // 4: client.AckMessageRequest.acks:type_name -> client.AckMessageRequest.Message
 	12, // 5: client.DownloadAttachmentRequest.info:type_name -> client.AttachmentInfo
-	63, // 6: client.DownloadAttachmentRequest.authData:type_name -> authentication.AuthMessage
-	63, // 7: client.StartMediaUploadRequest.authData:type_name -> authentication.AuthMessage
-	65, // 8: client.StartMediaUploadRequest.mobile:type_name -> authentication.Device
+	70, // 6: client.DownloadAttachmentRequest.authData:type_name -> authentication.AuthMessage
+	70, // 7: client.StartMediaUploadRequest.authData:type_name -> authentication.AuthMessage
+	72, // 8: client.StartMediaUploadRequest.mobile:type_name -> authentication.Device
 	15, // 9: client.UploadMediaResponse.media:type_name -> client.UploadedMedia
-	59, // 10: client.GetThumbnailResponse.thumbnail:type_name -> client.GetThumbnailResponse.Thumbnail
-	66, // 11: client.ThumbnailData.dimensions:type_name -> conversations.Dimensions
-	60, // 12: client.ThumbnailData.mysteriousData:type_name -> client.ThumbnailData.MysteriousData
+	66, // 10: client.GetThumbnailResponse.thumbnail:type_name -> client.GetThumbnailResponse.Thumbnail
+	73, // 11: client.ThumbnailData.dimensions:type_name -> conversations.Dimensions
+	67, // 12: client.ThumbnailData.mysteriousData:type_name -> client.ThumbnailData.MysteriousData
 	19, // 13: client.ListMessagesRequest.cursor:type_name -> client.Cursor
-	67, // 14: client.ListMessagesResponse.messages:type_name -> conversations.Message
+	74, // 14: client.ListMessagesResponse.messages:type_name -> conversations.Message
 	19, // 15: client.ListMessagesResponse.cursor:type_name -> client.Cursor
-	68, // 16: client.ListContactsResponse.contacts:type_name -> conversations.Contact
-	68, // 17: client.ListTopContactsResponse.contacts:type_name -> conversations.Contact
-	2,  // 18: client.ListConversationsRequest.folder:type_name -> client.ListConversationsRequest.Folder
-	19, // 19: client.ListConversationsRequest.cursor:type_name -> client.Cursor
-	69, // 20: client.ListConversationsResponse.conversations:type_name -> conversations.Conversation
-	19, // 21: client.ListConversationsResponse.cursor:type_name -> client.Cursor
-	70, // 22: client.GetOrCreateConversationRequest.numbers:type_name -> conversations.ContactNumber
-	69, // 23: client.GetOrCreateConversationResponse.conversation:type_name -> conversations.Conversation
-	3,  // 24: client.GetOrCreateConversationResponse.status:type_name -> client.GetOrCreateConversationResponse.Status
-	0,  // 25: client.UpdateConversationRequest.action:type_name -> client.ConversationActionStatus
-	33, // 26: client.UpdateConversationRequest.action5:type_name -> client.ConversationAction5
-	34, // 27: client.UpdateConversationRequest.deleteData:type_name -> client.DeleteConversationData
-	35, // 28: client.UpdateConversationRequest.updateData:type_name -> client.UpdateConversationData
-	71, // 29: client.UpdateConversationData.status:type_name -> conversations.ConversationStatus
-	1,  // 30: client.UpdateConversationData.mute:type_name -> client.ConversationMuteStatus
-	69, // 31: client.GetConversationResponse.conversation:type_name -> conversations.Conversation
-	46, // 32: client.SendMessageRequest.messagePayload:type_name -> client.MessagePayload
-	72, // 33: client.SendMessageRequest.SIMPayload:type_name -> settings.SIMPayload
-	45, // 34: client.SendMessageRequest.reply:type_name -> client.ReplyPayload
-	47, // 35: client.MessagePayload.messagePayloadContent:type_name -> client.MessagePayloadContent
-	73, // 36: client.MessagePayload.messageInfo:type_name -> conversations.MessageInfo
-	74, // 37: client.MessagePayloadContent.messageContent:type_name -> conversations.MessageContent
-	75, // 38: client.SendMessageResponse.googleAccountSwitch:type_name -> events.AccountChangeOrSomethingEvent
-	4,  // 39: client.SendMessageResponse.status:type_name -> client.SendMessageResponse.Status
-	76, // 40: client.SendReactionRequest.reactionData:type_name -> conversations.ReactionData
-	5,  // 41: client.SendReactionRequest.action:type_name -> client.SendReactionRequest.Action
-	72, // 42: client.SendReactionRequest.SIMPayload:type_name -> settings.SIMPayload
-	61, // 43: client.TypingUpdateRequest.data:type_name -> client.TypingUpdateRequest.Data
-	72, // 44: client.TypingUpdateRequest.SIMPayload:type_name -> settings.SIMPayload
-	62, // 45: client.SettingsUpdateRequest.pushSettings:type_name -> client.SettingsUpdateRequest.PushSettings
-	56, // 46: client.ReceiveMessagesRequest.UnknownEmptyObject2.unknown:type_name -> client.ReceiveMessagesRequest.UnknownEmptyObject1
-	65, // 47: client.AckMessageRequest.Message.device:type_name -> authentication.Device
-	18, // 48: client.GetThumbnailResponse.Thumbnail.data:type_name -> client.ThumbnailData
-	49, // [49:49] is the sub-list for method output_type
-	49, // [49:49] is the sub-list for method input_type
-	49, // [49:49] is the sub-list for extension type_name
-	49, // [49:49] is the sub-list for extension extendee
-	0,  // [0:49] is the sub-list for field type_name
+	19, // 16: client.ListContactsRequest.cursor:type_name -> client.Cursor
+	75, // 17: client.ListContactsResponse.contacts:type_name -> conversations.Contact
+	19, // 18: client.ListContactsResponse.cursor:type_name -> client.Cursor
+	75, // 19: client.ListTopContactsResponse.contacts:type_name -> conversations.Contact
+	2,  // 20: client.ListConversationsRequest.folder:type_name -> client.ListConversationsRequest.Folder
+	19, // 21: client.ListConversationsRequest.cursor:type_name -> client.Cursor
+	76, // 22: client.ListConversationsResponse.conversations:type_name -> conversations.Conversation
+	19, // 23: client.ListConversationsResponse.cursor:type_name -> client.Cursor
+	77, // 24: client.GetOrCreateConversationRequest.numbers:type_name -> conversations.ContactNumber
+	76, // 25: client.GetOrCreateConversationResponse.conversation:type_name -> conversations.Conversation
+	3,  // 26: client.GetOrCreateConversationResponse.status:type_name -> client.GetOrCreateConversationResponse.Status
+	0,  // 27: client.UpdateConversationRequest.action:type_name -> client.ConversationActionStatus
+	33, // 28: client.UpdateConversationRequest.action5:type_name -> client.ConversationAction5
+	34, // 29: client.UpdateConversationRequest.deleteData:type_name -> client.DeleteConversationData
+	35, // 30: client.UpdateConversationRequest.updateData:type_name -> client.UpdateConversationData
+	78, // 31: client.UpdateConversationData.status:type_name -> conversations.ConversationStatus
+	1,  // 32: client.UpdateConversationData.mute:type_name -> client.ConversationMuteStatus
+	76, // 33: client.GetConversationResponse.conversation:type_name -> conversations.Conversation
+	46, // 34: client.SendMessageRequest.messagePayload:type_name -> client.MessagePayload
+	79, // 35: client.SendMessageRequest.SIMPayload:type_name -> settings.SIMPayload
+	45, // 36: client.SendMessageRequest.reply:type_name -> client.ReplyPayload
+	47, // 37: client.MessagePayload.messagePayloadContent:type_name -> client.MessagePayloadContent
+	80, // 38: client.MessagePayload.messageInfo:type_name -> conversations.MessageInfo
+	81, // 39: client.MessagePayloadContent.messageContent:type_name -> conversations.MessageContent
+	82, // 40: client.SendMessageResponse.googleAccountSwitch:type_name -> events.AccountChangeOrSomethingEvent
+	4,  // 41: client.SendMessageResponse.status:type_name -> client.SendMessageResponse.Status
+	83, // 42: client.SendReactionRequest.reactionData:type_name -> conversations.ReactionData
+	5,  // 43: client.SendReactionRequest.action:type_name -> client.SendReactionRequest.Action
+	79, // 44: client.SendReactionRequest.SIMPayload:type_name -> settings.SIMPayload
+	77, // 45: client.AddParticipantToRCSGroupRequest.numbers:type_name -> conversations.ContactNumber
+	76, // 46: client.AddParticipantToRCSGroupResponse.conversation:type_name -> conversations.Conversation
+	77, // 47: client.GetContactRCSGroupStatusRequest.number:type_name -> conversations.ContactNumber
+	68, // 48: client.TypingUpdateRequest.data:type_name -> client.TypingUpdateRequest.Data
+	79, // 49: client.TypingUpdateRequest.SIMPayload:type_name -> settings.SIMPayload
+	69, // 50: client.SettingsUpdateRequest.pushSettings:type_name -> client.SettingsUpdateRequest.PushSettings
+	63, // 51: client.ReceiveMessagesRequest.UnknownEmptyObject2.unknown:type_name -> client.ReceiveMessagesRequest.UnknownEmptyObject1
+	72, // 52: client.AckMessageRequest.Message.device:type_name -> authentication.Device
+	18, // 53: client.GetThumbnailResponse.Thumbnail.data:type_name -> client.ThumbnailData
+	54, // [54:54] is the sub-list for method output_type
+	54, // [54:54] is the sub-list for method input_type
+	54, // [54:54] is the sub-list for extension type_name
+	54, // [54:54] is the sub-list for extension extendee
+	0,  // [0:54] is the sub-list for field type_name
 }
 
 func init() { file_client_proto_init() }
@@ -3735,6 +4113,8 @@ func file_client_proto_init() {
 	file_util_proto_init()
 	file_events_proto_init()
 	file_client_proto_msgTypes[2].OneofWrappers = []any{}
+	file_client_proto_msgTypes[16].OneofWrappers = []any{}
+	file_client_proto_msgTypes[18].OneofWrappers = []any{}
 	file_client_proto_msgTypes[20].OneofWrappers = []any{}
 	file_client_proto_msgTypes[21].OneofWrappers = []any{}
 	file_client_proto_msgTypes[22].OneofWrappers = []any{}
@@ -3752,7 +4132,7 @@ func file_client_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_client_proto_rawDesc), len(file_client_proto_rawDesc)),
 			NumEnums:      6,
-			NumMessages:   57,
+			NumMessages:   64,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
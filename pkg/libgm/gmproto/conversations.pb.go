@@ -2086,6 +2086,7 @@ type Conversation struct {
 	LatestMessage        *LatestMessage         `protobuf:"bytes,4,opt,name=latestMessage,proto3" json:"latestMessage,omitempty"`
 	LastMessageTimestamp int64                  `protobuf:"varint,5,opt,name=lastMessageTimestamp,proto3" json:"lastMessageTimestamp,omitempty"`
 	Unread               bool                   `protobuf:"varint,6,opt,name=unread,proto3" json:"unread,omitempty"`
+	Muted                *Muted                 `protobuf:"bytes,7,opt,name=muted,proto3" json:"muted,omitempty"`               // only set when the conversation is muted, check this
 	IsGroupChat          bool                   `protobuf:"varint,10,opt,name=isGroupChat,proto3" json:"isGroupChat,omitempty"` // not certain
 	DefaultOutgoingID    string                 `protobuf:"bytes,11,opt,name=defaultOutgoingID,proto3" json:"defaultOutgoingID,omitempty"`
 	Status               ConversationStatus     `protobuf:"varint,12,opt,name=status,proto3,enum=conversations.ConversationStatus" json:"status,omitempty"`
@@ -2174,6 +2175,13 @@ func (x *Conversation) GetUnread() bool {
 	return false
 }
 
+func (x *Conversation) GetMuted() *Muted {
+	if x != nil {
+		return x.Muted
+	}
+	return nil
+}
+
 func (x *Conversation) GetIsGroupChat() bool {
 	if x != nil {
 		return x.IsGroupChat
@@ -3081,13 +3089,14 @@ const file_conversations_proto_rawDesc = "" +
 	"\n" +
 	"statusText\x18\x05 \x01(\tR\n" +
 	"statusText\x12\x1c\n" +
-	"\tthirdCode\x18\x06 \x01(\x03R\tthirdCode\"\xaf\b\n" +
+	"\tthirdCode\x18\x06 \x01(\x03R\tthirdCode\"\xdb\b\n" +
 	"\fConversation\x12&\n" +
 	"\x0econversationID\x18\x01 \x01(\tR\x0econversationID\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12B\n" +
 	"\rlatestMessage\x18\x04 \x01(\v2\x1c.conversations.LatestMessageR\rlatestMessage\x122\n" +
 	"\x14lastMessageTimestamp\x18\x05 \x01(\x03R\x14lastMessageTimestamp\x12\x16\n" +
-	"\x06unread\x18\x06 \x01(\bR\x06unread\x12 \n" +
+	"\x06unread\x18\x06 \x01(\bR\x06unread\x12*\n" +
+	"\x05muted\x18\a \x01(\v2\x14.conversations.MutedR\x05muted\x12 \n" +
 	"\visGroupChat\x18\n" +
 	" \x01(\bR\visGroupChat\x12,\n" +
 	"\x11defaultOutgoingID\x18\v \x01(\tR\x11defaultOutgoingID\x129\n" +
@@ -3425,26 +3434,27 @@ var file_conversations_proto_depIdxs = []int32{
 	21, // 19: conversations.MediaContent.dimensions:type_name -> conversations.Dimensions
 	4,  // 20: conversations.MessageStatus.status:type_name -> conversations.MessageStatusType
 	28, // 21: conversations.Conversation.latestMessage:type_name -> conversations.LatestMessage
-	5,  // 22: conversations.Conversation.status:type_name -> conversations.ConversationStatus
-	2,  // 23: conversations.Conversation.sendMode:type_name -> conversations.ConversationSendMode
-	26, // 24: conversations.Conversation.participants:type_name -> conversations.Participant
-	3,  // 25: conversations.Conversation.type:type_name -> conversations.ConversationType
-	9,  // 26: conversations.Conversation.someKindOfGroupID:type_name -> conversations.SomeKindOfGroupID
-	36, // 27: conversations.Conversation.simCard:type_name -> settings.SIMCard
-	10, // 28: conversations.Conversation.latestMessageAnoherID:type_name -> conversations.AnotherMessageID
-	27, // 29: conversations.Participant.ID:type_name -> conversations.SmallInfo
-	37, // 30: conversations.Participant.simPayload:type_name -> settings.SIMPayload
-	1,  // 31: conversations.SmallInfo.type:type_name -> conversations.IdentifierType
-	29, // 32: conversations.LatestMessage.latestMessageStatus:type_name -> conversations.LatestMessageStatus
-	4,  // 33: conversations.LatestMessageStatus.status:type_name -> conversations.MessageStatusType
-	33, // 34: conversations.CustomEmojiData.Inner.first:type_name -> conversations.CustomEmojiData.Inner.ImageData
-	34, // 35: conversations.CustomEmojiData.Inner.second:type_name -> conversations.CustomEmojiData.Inner.WrappedImageData
-	35, // 36: conversations.CustomEmojiData.Inner.WrappedImageData.data:type_name -> conversations.CustomEmojiData.Inner.WrappedImageData.ImageData
-	37, // [37:37] is the sub-list for method output_type
-	37, // [37:37] is the sub-list for method input_type
-	37, // [37:37] is the sub-list for extension type_name
-	37, // [37:37] is the sub-list for extension extendee
-	0,  // [0:37] is the sub-list for field type_name
+	30, // 22: conversations.Conversation.muted:type_name -> conversations.Muted
+	5,  // 23: conversations.Conversation.status:type_name -> conversations.ConversationStatus
+	2,  // 24: conversations.Conversation.sendMode:type_name -> conversations.ConversationSendMode
+	26, // 25: conversations.Conversation.participants:type_name -> conversations.Participant
+	3,  // 26: conversations.Conversation.type:type_name -> conversations.ConversationType
+	9,  // 27: conversations.Conversation.someKindOfGroupID:type_name -> conversations.SomeKindOfGroupID
+	36, // 28: conversations.Conversation.simCard:type_name -> settings.SIMCard
+	10, // 29: conversations.Conversation.latestMessageAnoherID:type_name -> conversations.AnotherMessageID
+	27, // 30: conversations.Participant.ID:type_name -> conversations.SmallInfo
+	37, // 31: conversations.Participant.simPayload:type_name -> settings.SIMPayload
+	1,  // 32: conversations.SmallInfo.type:type_name -> conversations.IdentifierType
+	29, // 33: conversations.LatestMessage.latestMessageStatus:type_name -> conversations.LatestMessageStatus
+	4,  // 34: conversations.LatestMessageStatus.status:type_name -> conversations.MessageStatusType
+	33, // 35: conversations.CustomEmojiData.Inner.first:type_name -> conversations.CustomEmojiData.Inner.ImageData
+	34, // 36: conversations.CustomEmojiData.Inner.second:type_name -> conversations.CustomEmojiData.Inner.WrappedImageData
+	35, // 37: conversations.CustomEmojiData.Inner.WrappedImageData.data:type_name -> conversations.CustomEmojiData.Inner.WrappedImageData.ImageData
+	38, // [38:38] is the sub-list for method output_type
+	38, // [38:38] is the sub-list for method input_type
+	38, // [38:38] is the sub-list for extension type_name
+	38, // [38:38] is the sub-list for extension extendee
+	0,  // [0:38] is the sub-list for field type_name
 }
 
 func init() { file_conversations_proto_init() }
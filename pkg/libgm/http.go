@@ -23,9 +23,16 @@ import (
 const ContentTypeProtobuf = "application/x-protobuf"
 const ContentTypePBLite = "application/json+protobuf"
 
-func (c *Client) makeProtobufHTTPRequest(url string, data proto.Message, contentType string) (*http.Response, error) {
-	ctx := c.Logger.WithContext(context.TODO())
-	return c.makeProtobufHTTPRequestContext(ctx, url, data, contentType, false)
+// withLogger attaches the client's logger to the context, unless the caller already attached one.
+func (c *Client) withLogger(ctx context.Context) context.Context {
+	if zerolog.Ctx(ctx).GetLevel() != zerolog.Disabled {
+		return ctx
+	}
+	return c.Logger.WithContext(ctx)
+}
+
+func (c *Client) makeProtobufHTTPRequest(ctx context.Context, url string, data proto.Message, contentType string) (*http.Response, error) {
+	return c.makeProtobufHTTPRequestContext(c.withLogger(ctx), url, data, contentType, false)
 }
 
 func (c *Client) makeProtobufHTTPRequestContext(ctx context.Context, url string, data proto.Message, contentType string, longPoll bool) (*http.Response, error) {
@@ -1,11 +1,8 @@
 package libgm
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -79,6 +76,7 @@ func (r *resetter) Done() {
 }
 
 func (dp *dittoPinger) OnRespond(pingID uint64, dur time.Duration, reset *resetter) {
+	dp.client.metrics.PingResponse(dur)
 	dp.pingHandlingLock.Lock()
 	defer dp.pingHandlingLock.Unlock()
 	logEvt := dp.log.Debug().Uint64("ping_id", pingID).Dur("duration", dur)
@@ -92,6 +90,7 @@ func (dp *dittoPinger) OnRespond(pingID uint64, dur time.Duration, reset *resett
 	} else {
 		logEvt.Msg("Ditto ping successful")
 	}
+	dp.client.setPhoneReachable(true)
 	dp.oldestPingTime = time.Time{}
 	dp.notRespondingSent = false
 	dp.pingFails = 0
@@ -103,8 +102,10 @@ func (dp *dittoPinger) OnTimeout(pingID uint64, sendNotResponding bool) {
 	dp.pingHandlingLock.Lock()
 	defer dp.pingHandlingLock.Unlock()
 	dp.log.Warn().Uint64("ping_id", pingID).Msg("Ditto ping is taking long, phone may be offline")
+	dp.client.metrics.PingFailure(true)
 	if (!dp.firstPingDone || sendNotResponding) && !dp.notRespondingSent {
 		dp.client.triggerEvent(&events.PhoneNotResponding{})
+		dp.client.setPhoneReachable(false)
 		dp.notRespondingSent = true
 	}
 }
@@ -155,6 +156,7 @@ func (dp *dittoPinger) WaitForResponse(pingID uint64, start time.Time, timeout t
 					Msg("Ditto ping wait short-circuited during ping backoff, sending PhoneNotResponding immediately")
 				if !dp.notRespondingSent {
 					dp.client.triggerEvent(&events.PhoneNotResponding{})
+					dp.client.setPhoneReachable(false)
 					dp.notRespondingSent = true
 				}
 				dp.pingHandlingLock.Unlock()
@@ -196,9 +198,10 @@ func (dp *dittoPinger) Ping(pingID uint64, timeout time.Duration, timeoutCount i
 	if dp.oldestPingTime.IsZero() {
 		dp.oldestPingTime = now
 	}
-	pingChan, err := dp.client.NotifyDittoActivity()
+	pingChan, err := dp.client.NotifyDittoActivity(dp.log.WithContext(context.TODO()))
 	if err != nil {
 		dp.log.Err(err).Uint64("ping_id", pingID).Msg("Error sending ping")
+		dp.client.metrics.PingFailure(false)
 		dp.pingFails++
 		dp.client.triggerEvent(&events.PingFailed{
 			Error:      fmt.Errorf("failed to notify ditto activity: %w", err),
@@ -254,7 +257,7 @@ func (dp *dittoPinger) Loop() {
 
 func (dp *dittoPinger) HandleNoRecentUpdates() {
 	dp.client.triggerEvent(&events.NoDataReceived{})
-	err := dp.client.sessionHandler.sendMessageNoResponse(SendMessageParams{
+	err := dp.client.sessionHandler.sendMessageNoResponse(dp.log.WithContext(context.TODO()), SendMessageParams{
 		Action:    gmproto.ActionType_GET_UPDATES,
 		OmitTTL:   true,
 		RequestID: dp.client.sessionHandler.sessionID,
@@ -313,13 +316,35 @@ func (c *Client) doLongPoll(loggedIn, background bool, onFirstConnect func()) bo
 		}).Loop()
 	}
 
+	// Pairing and background connections don't affect the connection state
+	trackState := loggedIn && !background
+	setPollState := func(open bool, err error) {
+		if trackState && c.listenID == listenID {
+			c.updateConnState(func(ct *connectionTracker) {
+				ct.pollOpen = open
+				ct.pollErr = err
+			})
+		}
+	}
+	// The poll isn't open anymore once this function returns, unless a new long poll has already replaced it
+	defer setPollState(false, nil)
+
 	errorCount := 1
 	for c.listenID == listenID {
-		err := c.refreshAuthToken(nil)
+		err := c.refreshAuthToken(ctx, nil)
 		if err != nil {
 			log.Err(err).Msg("Error refreshing auth token")
+			err = fmt.Errorf("failed to refresh auth token: %w", err)
 			if loggedIn {
-				c.triggerEvent(&events.ListenFatalError{Error: fmt.Errorf("failed to refresh auth token: %w", err)})
+				c.triggerEvent(&events.ListenFatalError{Error: err})
+			}
+			if trackState && errors.Is(err, events.ErrInvalidCredentials) {
+				c.setLoggedOut(err)
+			} else if trackState {
+				c.updateConnState(func(ct *connectionTracker) {
+					ct.running = false
+					ct.fatalErr = err
+				})
 			}
 			return false
 		}
@@ -335,15 +360,13 @@ func (c *Client) doLongPoll(loggedIn, background bool, onFirstConnect func()) bo
 				Unknown: &gmproto.ReceiveMessagesRequest_UnknownEmptyObject1{},
 			},
 		}
-		url := util.ReceiveMessagesURL
-		if c.AuthData.HasCookies() {
-			url = util.ReceiveMessagesURLGoogle
-		}
+		url := c.endpoints.ReceiveMessagesURL(c.AuthData.HasCookies())
 		resp, err := c.makeProtobufHTTPRequestContext(ctx, url, payload, ContentTypePBLite, true)
 		if err != nil {
 			if loggedIn {
 				c.triggerEvent(&events.ListenTemporaryError{Error: err})
 			}
+			setPollState(false, err)
 			errorCount++
 			sleepSeconds := (errorCount + 1) * 5
 			if background {
@@ -353,6 +376,7 @@ func (c *Client) doLongPoll(loggedIn, background bool, onFirstConnect func()) bo
 				sleepSeconds = errorCount * 2
 			}
 			log.Err(err).Int("sleep_seconds", sleepSeconds).Msg("Error making listen request, retrying in a while")
+			c.metrics.LongPollReconnect(LongPollReconnectRequestError)
 			time.Sleep(time.Duration(sleepSeconds) * time.Second)
 			continue
 		}
@@ -362,16 +386,20 @@ func (c *Client) doLongPoll(loggedIn, background bool, onFirstConnect func()) bo
 				Int("status_code", resp.StatusCode).
 				Bytes("resp_body", body).
 				Msg("Error making listen request")
+			httpErr := events.HTTPError{Action: "polling", Resp: resp, Body: body}
 			if loggedIn {
-				c.triggerEvent(&events.ListenFatalError{Error: events.HTTPError{Action: "polling", Resp: resp, Body: body}})
+				c.triggerEvent(&events.ListenFatalError{Error: httpErr})
+			}
+			if trackState {
+				c.setLoggedOut(httpErr)
 			}
 			return false
 		} else if resp.StatusCode >= 400 {
+			httpErr := events.HTTPError{Action: "polling", Resp: resp, Body: tryReadBody(resp.Body)}
 			if loggedIn {
-				c.triggerEvent(&events.ListenTemporaryError{Error: events.HTTPError{Action: "polling", Resp: resp, Body: tryReadBody(resp.Body)}})
-			} else {
-				_ = resp.Body.Close()
+				c.triggerEvent(&events.ListenTemporaryError{Error: httpErr})
 			}
+			setPollState(false, httpErr)
 			errorCount++
 			sleepSeconds := (errorCount + 1) * 5
 			if background {
@@ -384,6 +412,7 @@ func (c *Client) doLongPoll(loggedIn, background bool, onFirstConnect func()) bo
 				Int("statusCode", resp.StatusCode).
 				Int("sleep_seconds", sleepSeconds).
 				Msg("Error in long polling, retrying in a while")
+			c.metrics.LongPollReconnect(LongPollReconnectHTTPError)
 			time.Sleep(time.Duration(sleepSeconds) * time.Second)
 			continue
 		}
@@ -395,33 +424,39 @@ func (c *Client) doLongPoll(loggedIn, background bool, onFirstConnect func()) bo
 		}
 		log.Debug().Int("statusCode", resp.StatusCode).Msg("Long polling opened")
 		c.longPollingConn = resp.Body
+		setPollState(true, nil)
 		if onFirstConnect != nil {
 			go onFirstConnect()
 			onFirstConnect = nil
 		}
-		cleanClose := c.readLongPoll(&log, resp.Body, background)
+		cleanClose, endReason := c.readLongPoll(&log, resp.Body, background)
 		c.longPollingConn = nil
+		setPollState(false, nil)
 		if background {
 			return cleanClose
+		} else if c.listenID == listenID {
+			c.metrics.LongPollReconnect(endReason)
 		}
 	}
 	return true
 }
 
-func (c *Client) readLongPoll(log *zerolog.Logger, rc io.ReadCloser, background bool) bool {
+type readNotifier struct {
+	r      io.Reader
+	onRead func()
+}
+
+func (rn *readNotifier) Read(p []byte) (int, error) {
+	n, err := rn.r.Read(p)
+	if n > 0 {
+		rn.onRead()
+	}
+	return n, err
+}
+
+func (c *Client) readLongPoll(log *zerolog.Logger, rc io.ReadCloser, background bool) (bool, LongPollReconnectReason) {
 	defer rc.Close()
 	c.disconnecting = false
-	reader := bufio.NewReader(rc)
-	buf := make([]byte, 2621440)
-	var accumulatedData []byte
-	n, err := reader.Read(buf[:2])
-	if err != nil {
-		log.Err(err).Msg("Error reading opening bytes")
-		return false
-	} else if n != 2 || string(buf[:2]) != "[[" {
-		log.Err(err).Msg("Opening is not [[")
-		return false
-	}
 	var closeIn *time.Timer
 	receivedEvents := false
 	onRead := func() {
@@ -441,40 +476,23 @@ func (c *Client) readLongPoll(log *zerolog.Logger, rc io.ReadCloser, background
 			c.closeLongPolling()
 		}()
 	}
-	var expectEOF bool
+	decoder := NewLongPollDecoder(&readNotifier{r: rc, onRead: onRead}, 0)
 	for {
-		n, err = reader.Read(buf)
+		frame, err := decoder.Next()
 		if err != nil {
 			var logEvt *zerolog.Event
-			if (errors.Is(err, io.EOF) && expectEOF) || c.disconnecting {
+			reason := LongPollReconnectReadError
+			if errors.Is(err, io.EOF) || c.disconnecting {
 				logEvt = log.Trace()
+				reason = LongPollReconnectStreamEnded
 			} else {
 				logEvt = log.Warn()
 			}
 			logEvt.Err(err).Msg("Stopped reading data from server")
-			return receivedEvents
-		} else if expectEOF {
-			log.Warn().Msg("Didn't get EOF after stream end marker")
-		}
-		onRead()
-		chunk := buf[:n]
-		if len(accumulatedData) == 0 {
-			if len(chunk) == 2 && string(chunk) == "]]" {
-				log.Trace().Msg("Got stream end marker")
-				expectEOF = true
-				continue
-			}
-			chunk = bytes.TrimPrefix(chunk, []byte{','})
-		}
-		accumulatedData = append(accumulatedData, chunk...)
-		if !json.Valid(accumulatedData) {
-			log.Trace().Msg("Invalid JSON, reading next chunk")
-			continue
+			return receivedEvents, reason
 		}
-		currentBlock := accumulatedData
-		accumulatedData = accumulatedData[:0]
 		msg := &gmproto.LongPollingPayload{}
-		err = pblite.Unmarshal(currentBlock, msg)
+		err = pblite.Unmarshal(frame, msg)
 		if err != nil {
 			log.Err(err).Msg("Error deserializing pblite message")
 			continue
@@ -490,14 +508,14 @@ func (c *Client) readLongPoll(log *zerolog.Logger, rc io.ReadCloser, background
 				level = zerolog.DebugLevel
 			}
 			log.WithLevel(level).Int32("count", msg.GetAck().GetCount()).Msg("Got startup ack count message")
-			c.skipCount = int(msg.GetAck().GetCount())
+			c.skipCount.Store(msg.GetAck().GetCount())
 		case msg.GetStartRead() != nil:
 			log.Trace().Msg("Got startRead message")
 		case msg.GetHeartbeat() != nil:
 			log.Trace().Msg("Got heartbeat message")
 		default:
 			log.Warn().
-				Str("data", base64.StdEncoding.EncodeToString(currentBlock)).
+				Str("data", base64.StdEncoding.EncodeToString(frame)).
 				Msg("Got unknown message")
 		}
 	}
@@ -0,0 +1,207 @@
+package libgm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLongPollFrameSize is the default limit for the size of a single frame in the long-polling stream.
+const DefaultMaxLongPollFrameSize = 64 * 1024 * 1024
+
+const longPollReadSize = 32 * 1024
+
+var (
+	ErrLongPollFrameTooLarge = errors.New("long poll frame too large")
+	ErrInvalidLongPollStream = errors.New("invalid long poll stream")
+)
+
+// LongPollDecoder splits the long-polling response stream into individual frames.
+//
+// The stream is one big JSON array that is written incrementally: it starts with [[, contains
+// comma-separated pblite arrays and ends with ]]. The decoder only tracks the nesting depth and
+// string state of the current frame, so each byte is looked at once and memory use is bounded
+// by the size of the largest frame.
+type LongPollDecoder struct {
+	r            io.Reader
+	maxFrameSize int
+
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+	err   error
+
+	frame     []byte
+	openSeen  int
+	firstDone bool
+	endSeen   bool
+}
+
+// NewLongPollDecoder creates a new decoder that reads from the given reader.
+// Frames larger than maxFrameSize bytes cause an error. If maxFrameSize is zero, [DefaultMaxLongPollFrameSize] is used.
+func NewLongPollDecoder(r io.Reader, maxFrameSize int) *LongPollDecoder {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxLongPollFrameSize
+	}
+	return &LongPollDecoder{
+		r:            r,
+		maxFrameSize: maxFrameSize,
+		buf:          make([]byte, longPollReadSize),
+	}
+}
+
+func (d *LongPollDecoder) fill() error {
+	if d.start < d.end {
+		return nil
+	} else if d.err != nil {
+		return d.err
+	} else if d.eof {
+		return io.ErrUnexpectedEOF
+	}
+	for {
+		n, err := d.r.Read(d.buf)
+		d.start, d.end = 0, n
+		if errors.Is(err, io.EOF) {
+			d.eof = true
+		} else if err != nil {
+			d.err = err
+		}
+		if n > 0 {
+			return nil
+		} else if d.eof {
+			return io.ErrUnexpectedEOF
+		} else if d.err != nil {
+			return d.err
+		}
+	}
+}
+
+func isJSONSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t'
+}
+
+// nextDelimiter skips whitespace outside frames and returns the next byte.
+func (d *LongPollDecoder) nextDelimiter() (byte, error) {
+	for {
+		if err := d.fill(); err != nil {
+			return 0, err
+		}
+		b := d.buf[d.start]
+		d.start++
+		if !isJSONSpace(b) {
+			return b, nil
+		}
+	}
+}
+
+// Next returns the next frame in the stream. The returned slice is only valid until the next call.
+//
+// After the end marker of the stream, Next returns [io.EOF]. If the stream ends before the end marker,
+// [io.ErrUnexpectedEOF] is returned instead.
+func (d *LongPollDecoder) Next() ([]byte, error) {
+	for d.openSeen < 2 {
+		b, err := d.nextDelimiter()
+		if err != nil {
+			return nil, err
+		} else if b != '[' {
+			return nil, fmt.Errorf("%w: expected [ at start of stream, got %q", ErrInvalidLongPollStream, b)
+		}
+		d.openSeen++
+	}
+	if d.endSeen {
+		return nil, io.EOF
+	}
+	b, err := d.nextDelimiter()
+	if err != nil {
+		return nil, err
+	}
+	if b == ']' {
+		b, err = d.nextDelimiter()
+		if err != nil {
+			return nil, err
+		} else if b != ']' {
+			return nil, fmt.Errorf("%w: expected ] after end of frames, got %q", ErrInvalidLongPollStream, b)
+		}
+		d.endSeen = true
+		return nil, io.EOF
+	}
+	if d.firstDone {
+		if b != ',' {
+			return nil, fmt.Errorf("%w: expected , between frames, got %q", ErrInvalidLongPollStream, b)
+		}
+		b, err = d.nextDelimiter()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b != '[' {
+		return nil, fmt.Errorf("%w: expected [ at start of frame, got %q", ErrInvalidLongPollStream, b)
+	}
+	d.firstDone = true
+	d.start--
+	return d.readFrame()
+}
+
+// readFrame reads a single JSON array starting at the current position.
+func (d *LongPollDecoder) readFrame() ([]byte, error) {
+	d.frame = d.frame[:0]
+	depth := 0
+	inString := false
+	escaped := false
+	for {
+		if err := d.fill(); err != nil {
+			return nil, err
+		}
+		chunk := d.buf[d.start:d.end]
+		i := 0
+		done := false
+	Scan:
+		for i < len(chunk) {
+			if inString {
+				if escaped {
+					escaped = false
+					i++
+					continue
+				}
+				// Strings are most of the data (base64 payloads), so skip to the next interesting byte directly
+				idx := bytes.IndexAny(chunk[i:], `"\`)
+				if idx < 0 {
+					i = len(chunk)
+					break
+				}
+				i += idx
+				if chunk[i] == '\\' {
+					escaped = true
+				} else {
+					inString = false
+				}
+				i++
+				continue
+			}
+			switch chunk[i] {
+			case '"':
+				inString = true
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					i++
+					done = true
+					break Scan
+				}
+			}
+			i++
+		}
+		if len(d.frame)+i > d.maxFrameSize {
+			return nil, fmt.Errorf("%w (over %d bytes)", ErrLongPollFrameTooLarge, d.maxFrameSize)
+		}
+		d.frame = append(d.frame, chunk[:i]...)
+		d.start += i
+		if done {
+			return d.frame, nil
+		}
+	}
+}
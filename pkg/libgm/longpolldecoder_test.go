@@ -0,0 +1,152 @@
+package libgm_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mau.fi/util/pblite"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm"
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+// readSampleStreams reads the long poll streams in testdata/longpoll. They're synthetic: the framing follows
+// the real long poll response format, but the contents were written by hand rather than captured from a phone.
+func readSampleStreams(t testing.TB) map[string][]byte {
+	files, err := filepath.Glob("testdata/longpoll/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	streams := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		streams[filepath.Base(file)] = data
+	}
+	return streams
+}
+
+// chunkedReader returns the data in chunks of varying sizes, like reads from a network connection.
+type chunkedReader struct {
+	data  []byte
+	sizes []byte
+	i     int
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if len(cr.data) == 0 {
+		return 0, io.EOF
+	}
+	size := 16 * 1024
+	if len(cr.sizes) > 0 {
+		size = int(cr.sizes[cr.i%len(cr.sizes)]) + 1
+		cr.i++
+	}
+	size = min(size, len(p), len(cr.data))
+	n := copy(p, cr.data[:size])
+	cr.data = cr.data[n:]
+	return n, nil
+}
+
+func decodeAll(r io.Reader, maxFrameSize int) (frames [][]byte, err error) {
+	decoder := libgm.NewLongPollDecoder(r, maxFrameSize)
+	for {
+		frame, err := decoder.Next()
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, bytes.Clone(frame))
+	}
+}
+
+func TestLongPollDecoder_SampleStreams(t *testing.T) {
+	for name, data := range readSampleStreams(t) {
+		t.Run(name, func(t *testing.T) {
+			frames, err := decodeAll(bytes.NewReader(data), 0)
+			require.ErrorIs(t, err, io.EOF)
+			require.NotEmpty(t, frames)
+			for _, frame := range frames {
+				var payload gmproto.LongPollingPayload
+				require.NoError(t, pblite.Unmarshal(frame, &payload))
+			}
+			oneByteFrames, err := decodeAll(iotest.OneByteReader(bytes.NewReader(data)), 0)
+			require.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, frames, oneByteFrames)
+		})
+	}
+}
+
+func TestLongPollDecoder(t *testing.T) {
+	frames, err := decodeAll(bytes.NewReader([]byte(` [[ [1,"a]\"[",{"b":[]}] , [null]
+]]`)), 0)
+	require.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, [][]byte{[]byte(`[1,"a]\"[",{"b":[]}]`), []byte(`[null]`)}, frames)
+
+	frames, err = decodeAll(bytes.NewReader([]byte(`[[]]`)), 0)
+	require.ErrorIs(t, err, io.EOF)
+	assert.Empty(t, frames)
+
+	frames, err = decodeAll(bytes.NewReader([]byte(`[[[1],[2`)), 0)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Len(t, frames, 1)
+
+	_, err = decodeAll(bytes.NewReader([]byte(`{"error":1}`)), 0)
+	require.ErrorIs(t, err, libgm.ErrInvalidLongPollStream)
+
+	_, err = decodeAll(bytes.NewReader([]byte(`[[[1][2]]]`)), 0)
+	require.ErrorIs(t, err, libgm.ErrInvalidLongPollStream)
+
+	frames, err = decodeAll(bytes.NewReader([]byte(`[[[1],["`+string(bytes.Repeat([]byte{'a'}, 100))+`"]]]`)), 64)
+	require.ErrorIs(t, err, libgm.ErrLongPollFrameTooLarge)
+	assert.Len(t, frames, 1)
+}
+
+func FuzzLongPollDecoder(f *testing.F) {
+	for _, data := range readSampleStreams(f) {
+		if len(data) < 64*1024 {
+			f.Add(data, []byte{0})
+		}
+	}
+	f.Add([]byte(`[[[1,"]\\\"",[{}]],[2]]]`), []byte{3, 1, 7})
+	f.Add([]byte(`[[]]`), []byte{})
+	f.Fuzz(func(t *testing.T, data, chunkSizes []byte) {
+		const maxFrameSize = 1024 * 1024
+		frames, err := decodeAll(bytes.NewReader(data), maxFrameSize)
+		require.Error(t, err)
+		for _, frame := range frames {
+			require.LessOrEqual(t, len(frame), maxFrameSize)
+			require.Equal(t, byte('['), frame[0])
+			require.Contains(t, "]}", string(frame[len(frame)-1]))
+		}
+		// The result must not depend on how the data is split into reads
+		chunkedFrames, chunkedErr := decodeAll(&chunkedReader{data: data, sizes: chunkSizes}, maxFrameSize)
+		require.Equal(t, frames, chunkedFrames)
+		require.Equal(t, err.Error(), chunkedErr.Error())
+	})
+}
+
+func BenchmarkLongPollDecoder(b *testing.B) {
+	for name, data := range readSampleStreams(b) {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				decoder := libgm.NewLongPollDecoder(&chunkedReader{data: data}, 0)
+				for {
+					_, err := decoder.Next()
+					if errors.Is(err, io.EOF) {
+						break
+					} else if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"go.mau.fi/util/pblite"
 	"google.golang.org/protobuf/proto"
 
+	"go.mau.fi/mautrix-gmessages/pkg/libgm"
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/crypto"
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 )
@@ -31,30 +32,6 @@ func mustNoReturn(err error) {
 	}
 }
 
-var requestType = map[gmproto.ActionType]proto.Message{
-	gmproto.ActionType_LIST_CONVERSATIONS:         &gmproto.ListConversationsRequest{},
-	gmproto.ActionType_NOTIFY_DITTO_ACTIVITY:      &gmproto.NotifyDittoActivityRequest{},
-	gmproto.ActionType_GET_CONVERSATION_TYPE:      &gmproto.GetConversationTypeRequest{},
-	gmproto.ActionType_GET_CONVERSATION:           &gmproto.GetConversationRequest{},
-	gmproto.ActionType_LIST_MESSAGES:              &gmproto.ListMessagesRequest{},
-	gmproto.ActionType_SEND_MESSAGE:               &gmproto.SendMessageRequest{},
-	gmproto.ActionType_SEND_REACTION:              &gmproto.SendReactionRequest{},
-	gmproto.ActionType_DELETE_MESSAGE:             &gmproto.DeleteMessageRequest{},
-	gmproto.ActionType_GET_PARTICIPANTS_THUMBNAIL: &gmproto.GetThumbnailRequest{},
-	gmproto.ActionType_GET_CONTACTS_THUMBNAIL:     &gmproto.GetThumbnailRequest{},
-	gmproto.ActionType_LIST_CONTACTS:              &gmproto.ListContactsRequest{},
-	gmproto.ActionType_LIST_TOP_CONTACTS:          &gmproto.ListTopContactsRequest{},
-	gmproto.ActionType_GET_OR_CREATE_CONVERSATION: &gmproto.GetOrCreateConversationRequest{},
-	gmproto.ActionType_UPDATE_CONVERSATION:        &gmproto.UpdateConversationRequest{},
-	gmproto.ActionType_RESEND_MESSAGE:             &gmproto.ResendMessageRequest{},
-	gmproto.ActionType_TYPING_UPDATES:             &gmproto.TypingUpdateRequest{},
-	gmproto.ActionType_GET_FULL_SIZE_IMAGE:        &gmproto.GetFullSizeImageRequest{},
-	gmproto.ActionType_SETTINGS_UPDATE:            &gmproto.SettingsUpdateRequest{},
-
-	gmproto.ActionType_CREATE_GAIA_PAIRING_CLIENT_INIT:     &gmproto.GaiaPairingRequestContainer{},
-	gmproto.ActionType_CREATE_GAIA_PAIRING_CLIENT_FINISHED: &gmproto.GaiaPairingRequestContainer{},
-}
-
 func main() {
 	var x crypto.AESCTRHelper
 	file, err := os.Open("config.json")
@@ -124,7 +101,7 @@ func main() {
 		_, _ = fmt.Fprintln(os.Stderr, "------------------------------ RAW DECRYPTED DATA ------------------------------")
 		fmt.Println(base64.StdEncoding.EncodeToString(decrypted))
 		_, _ = fmt.Fprintln(os.Stderr, "--------------------------------- DECODED DATA ---------------------------------")
-		respType, ok := requestType[ord.Action]
+		respType, ok := libgm.RequestTypes[ord.Action]
 		var cmd *exec.Cmd
 		if ok {
 			cmd = exec.Command("protoc", "--proto_path=../gmproto", "--decode", string(respType.ProtoReflect().Type().Descriptor().FullName()), "client.proto")
@@ -2,13 +2,17 @@ package libgm
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"google.golang.org/protobuf/proto"
@@ -88,7 +92,24 @@ func init() {
 	}
 }
 
-func (c *Client) UploadMedia(data []byte, fileName, mime string) (*gmproto.MediaContent, error) {
+// uploadChunkSize is the approximate size of each request when uploading media.
+// The actual size is rounded down to a multiple of the chunk granularity reported by the server.
+const uploadChunkSize = 8 * 1024 * 1024
+
+const (
+	maxUploadRetries      = 5
+	uploadRetryBackoff    = 1 * time.Second
+	maxUploadRetryBackoff = 30 * time.Second
+)
+
+func (c *Client) UploadMedia(ctx context.Context, data []byte, fileName, mime string) (*gmproto.MediaContent, error) {
+	return c.UploadMediaStream(ctx, bytes.NewReader(data), int64(len(data)), fileName, mime)
+}
+
+// UploadMediaStream encrypts and uploads media from the given reader. The data is encrypted as it's read,
+// and uploaded in chunks aligned to the chunk granularity reported by the server, so the whole file is never
+// held in memory. The reader must produce exactly size bytes.
+func (c *Client) UploadMediaStream(ctx context.Context, r io.Reader, size int64, fileName, mime string) (*gmproto.MediaContent, error) {
 	mediaType := MimeToMediaType[mime]
 	if mediaType.Type == 0 {
 		mediaType = MimeToMediaType[strings.Split(mime, "/")[0]]
@@ -98,23 +119,20 @@ func (c *Client) UploadMedia(data []byte, fileName, mime string) (*gmproto.Media
 	if err != nil {
 		return nil, err
 	}
-	encryptedBytes, err := cryptor.EncryptData(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt media: %w", err)
-	}
-	startUploadImage, err := c.StartUploadMedia(encryptedBytes, mime)
+	encryptedSize := cryptor.EncryptedSize(size)
+	startUpload, err := c.startUploadMedia(ctx, encryptedSize, mime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start upload: %w", err)
 	}
-	upload, err := c.FinalizeUploadMedia(startUploadImage)
+	upload, err := c.uploadMediaChunks(ctx, startUpload, cryptor.EncryptStream(r), encryptedSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to finalize upload: %w", err)
+		return nil, fmt.Errorf("failed to upload media: %w", err)
 	}
 	return &gmproto.MediaContent{
 		Format:        mediaType.Type,
 		MediaID:       upload.MediaID,
 		MediaName:     fileName,
-		Size:          int64(len(data)),
+		Size:          size,
 		DecryptionKey: decryptionKey,
 		MimeType:      mime,
 	}, nil
@@ -152,15 +170,168 @@ func isStandardBase64(data []byte) bool {
 	return true
 }
 
-func (c *Client) FinalizeUploadMedia(upload *StartGoogleUpload) (*MediaUpload, error) {
-	encryptedImageSize := strconv.Itoa(len(upload.EncryptedMediaBytes))
+func (c *Client) uploadMediaChunks(ctx context.Context, upload *StartGoogleUpload, r io.Reader, totalSize int64) (*MediaUpload, error) {
+	granularity := max(upload.ChunkGranularity, 1)
+	chunkSize := max(uploadChunkSize/granularity, 1) * granularity
+	buf := make([]byte, min(chunkSize, totalSize))
+	var offset int64
+	for {
+		chunk := buf[:min(int64(len(buf)), totalSize-offset)]
+		n, err := io.ReadFull(r, chunk)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("media ended after %d bytes, expected %d", offset+int64(n), totalSize)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read media: %w", err)
+		}
+		isLast := offset+int64(n) >= totalSize
+		result, err := c.uploadMediaChunkWithRetry(ctx, upload, chunk, offset, totalSize, isLast)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload chunk at offset %d: %w", offset, err)
+		} else if isLast {
+			return result, nil
+		}
+		offset += int64(n)
+	}
+}
+
+// UploadStatusError is returned when the media upload server responds with an unexpected HTTP status.
+type UploadStatusError struct {
+	StatusCode int
+}
+
+func (use UploadStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", use.StatusCode)
+}
+
+// isRetryableUploadError checks if an upload request should be retried: connection errors,
+// timeouts, rate limits and server errors are retried, while other errors are returned immediately.
+func isRetryableUploadError(err error) bool {
+	var statusErr UploadStatusError
+	var urlErr *url.Error
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 ||
+			statusErr.StatusCode == http.StatusRequestTimeout ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return errors.As(err, &urlErr)
+}
+
+// uploadMediaChunkWithRetry uploads a part of the encrypted media. If a request fails with a transient error,
+// it asks the server how much data it has committed and resumes from there after a delay.
+func (c *Client) uploadMediaChunkWithRetry(ctx context.Context, upload *StartGoogleUpload, chunk []byte, offset, totalSize int64, finalize bool) (*MediaUpload, error) {
+	log := c.Logger.With().Str("upload_id", upload.UploadID).Logger()
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if attempt > maxUploadRetries {
+				return nil, lastErr
+			}
+			backoff := min(uploadRetryBackoff<<(attempt-1), maxUploadRetryBackoff)
+			log.Warn().Err(lastErr).
+				Int64("offset", offset).
+				Int("attempt", attempt).
+				Stringer("retry_in", backoff).
+				Msg("Media upload request failed, retrying")
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			status, err := c.QueryUploadStatus(ctx, upload)
+			if err != nil {
+				lastErr = fmt.Errorf("failed to query upload status: %w", err)
+				if !isRetryableUploadError(err) {
+					return nil, lastErr
+				}
+				continue
+			} else if status.Status == "final" {
+				if !finalize {
+					return nil, fmt.Errorf("upload was finalized unexpectedly")
+				}
+				log.Debug().Msg("Upload was already finalized")
+				return status.Result, nil
+			} else if status.Status != "active" {
+				return nil, fmt.Errorf("upload is no longer active (status: %q)", status.Status)
+			} else if status.ReceivedSize < offset || status.ReceivedSize > offset+int64(len(chunk)) {
+				return nil, fmt.Errorf("can't resume upload: server has %d bytes, but the current chunk covers %d-%d", status.ReceivedSize, offset, offset+int64(len(chunk)))
+			}
+			log.Debug().
+				Int64("offset", offset).
+				Int64("received_size", status.ReceivedSize).
+				Msg("Resuming upload")
+			chunk = chunk[status.ReceivedSize-offset:]
+			offset = status.ReceivedSize
+			if len(chunk) == 0 && !finalize {
+				return nil, nil
+			}
+		}
+		result, err := c.uploadMediaChunk(ctx, upload, chunk, offset, totalSize, finalize)
+		if err == nil {
+			return result, nil
+		} else if ctx.Err() != nil || !isRetryableUploadError(err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+}
+
+// UploadStatus is the state of a resumable upload returned by [Client.QueryUploadStatus].
+type UploadStatus struct {
+	// Status is "active" if more data can be uploaded, "final" if the upload is complete,
+	// or something else if the server has given up on the upload.
+	Status string
+	// ReceivedSize is the number of bytes the server has committed. Uploads must continue from this offset.
+	ReceivedSize int64
+	// Result is the uploaded media if the upload has been finalized.
+	Result *MediaUpload
+}
 
-	finalizeUploadHeaders := util.NewMediaUploadHeaders(encryptedImageSize, "upload, finalize", "0", upload.MimeType, "")
-	req, err := http.NewRequest(http.MethodPost, upload.UploadURL, bytes.NewBuffer(upload.EncryptedMediaBytes))
+// QueryUploadStatus asks the server how much of a resumable upload it has received.
+func (c *Client) QueryUploadStatus(ctx context.Context, upload *StartGoogleUpload) (*UploadStatus, error) {
+	headers := util.NewMediaUploadHeaders("", "query", "", "", "")
+	headers.Del("x-goog-upload-header-content-length")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cmp.Or(upload.ControlURL, upload.UploadURL), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
-	req.Header = *finalizeUploadHeaders
+	req.Header = *headers
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, UploadStatusError{StatusCode: res.StatusCode}
+	}
+	status := &UploadStatus{Status: res.Header.Get("x-goog-upload-status")}
+	if status.Status == "final" {
+		status.Result, err = c.parseUploadResponse(res)
+		if err != nil {
+			return nil, err
+		}
+	} else if receivedSize := res.Header.Get("x-goog-upload-size-received"); receivedSize != "" {
+		status.ReceivedSize, err = strconv.ParseInt(receivedSize, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse received size: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// uploadMediaChunk uploads a part of the encrypted media. If finalize is true, the upload is completed
+// and the resulting media ID is returned.
+func (c *Client) uploadMediaChunk(ctx context.Context, upload *StartGoogleUpload, chunk []byte, offset, totalSize int64, finalize bool) (*MediaUpload, error) {
+	command := "upload"
+	if finalize {
+		command = "upload, finalize"
+	}
+	headers := util.NewMediaUploadHeaders(strconv.FormatInt(totalSize, 10), command, strconv.FormatInt(offset, 10), upload.MimeType, "")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upload.UploadURL, bytes.NewReader(chunk))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+	req.Header = *headers
 
 	res, err := c.http.Do(req)
 	if err != nil {
@@ -169,8 +340,15 @@ func (c *Client) FinalizeUploadMedia(upload *StartGoogleUpload) (*MediaUpload, e
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
+		return nil, UploadStatusError{StatusCode: res.StatusCode}
+	} else if !finalize {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil, nil
 	}
+	return c.parseUploadResponse(res)
+}
+
+func (c *Client) parseUploadResponse(res *http.Response) (*MediaUpload, error) {
 	respData, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -198,16 +376,28 @@ func (c *Client) FinalizeUploadMedia(upload *StartGoogleUpload) (*MediaUpload, e
 	}, nil
 }
 
-func (c *Client) StartUploadMedia(encryptedImageBytes []byte, mime string) (*StartGoogleUpload, error) {
-	encryptedImageSize := strconv.Itoa(len(encryptedImageBytes))
+func (c *Client) FinalizeUploadMedia(ctx context.Context, upload *StartGoogleUpload) (*MediaUpload, error) {
+	size := int64(len(upload.EncryptedMediaBytes))
+	return c.uploadMediaChunks(ctx, upload, bytes.NewReader(upload.EncryptedMediaBytes), size)
+}
+
+func (c *Client) StartUploadMedia(ctx context.Context, encryptedImageBytes []byte, mime string) (*StartGoogleUpload, error) {
+	upload, err := c.startUploadMedia(ctx, int64(len(encryptedImageBytes)), mime)
+	if err != nil {
+		return nil, err
+	}
+	upload.EncryptedMediaBytes = encryptedImageBytes
+	return upload, nil
+}
 
-	startUploadHeaders := util.NewMediaUploadHeaders(encryptedImageSize, "start", "", mime, "resumable")
+func (c *Client) startUploadMedia(ctx context.Context, encryptedSize int64, mime string) (*StartGoogleUpload, error) {
+	startUploadHeaders := util.NewMediaUploadHeaders(strconv.FormatInt(encryptedSize, 10), "start", "", mime, "resumable")
 	startUploadPayload, err := c.buildStartUploadPayload()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build payload: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, util.UploadMediaURL, bytes.NewBuffer([]byte(startUploadPayload)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoints.UploadMediaURL(), bytes.NewBuffer([]byte(startUploadPayload)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
@@ -235,8 +425,6 @@ func (c *Client) StartUploadMedia(encryptedImageBytes []byte, mime string) (*Sta
 		ChunkGranularity: int64(chunkGranularity),
 		ControlURL:       res.Header.Get("x-goog-upload-control-url"),
 		MimeType:         mime,
-
-		EncryptedMediaBytes: encryptedImageBytes,
 	}
 	return uploadResponse, nil
 }
@@ -262,7 +450,26 @@ func (c *Client) buildStartUploadPayload() (string, error) {
 	return protoDataEncoded, nil
 }
 
-func (c *Client) DownloadMedia(mediaID string, key []byte) ([]byte, error) {
+func (c *Client) DownloadMedia(ctx context.Context, mediaID string, key []byte) ([]byte, error) {
+	stream, err := c.DownloadMediaStream(ctx, mediaID, key)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	return data, nil
+}
+
+// DownloadMediaStream downloads media and returns a reader that decrypts it on the fly.
+// Errors from decrypting are returned from Read. The caller must close the reader.
+func (c *Client) DownloadMediaStream(ctx context.Context, mediaID string, key []byte) (io.ReadCloser, error) {
+	cryptor, err := crypto.NewAESGCMHelper(key)
+	if err != nil {
+		return nil, err
+	}
 	downloadMetadata := &gmproto.DownloadAttachmentRequest{
 		Info: &gmproto.AttachmentInfo{
 			AttachmentID: mediaID,
@@ -280,7 +487,7 @@ func (c *Client) DownloadMedia(mediaID string, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to marshal download request: %w", err)
 	}
 	downloadMetadataEncoded := base64.StdEncoding.EncodeToString(downloadMetadataBytes)
-	req, err := http.NewRequest(http.MethodGet, util.UploadMediaURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoints.UploadMediaURL(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
@@ -288,21 +495,16 @@ func (c *Client) DownloadMedia(mediaID string, key []byte) ([]byte, error) {
 	res, err := c.http.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
+	} else if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
 	}
-	defer res.Body.Close()
-	respData, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	cryptor, err := crypto.NewAESGCMHelper(key)
-	if err != nil {
-		return nil, err
-	}
-	decryptedImageBytes, err := cryptor.DecryptData(respData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt media: %w", err)
-	}
-	return decryptedImageBytes, nil
+	return &decryptingReadCloser{Reader: cryptor.DecryptStream(res.Body), Closer: res.Body}, nil
+}
+
+type decryptingReadCloser struct {
+	io.Reader
+	io.Closer
 }
 
 func (c *Client) DownloadAvatar(ctx context.Context, url string) ([]byte, error) {
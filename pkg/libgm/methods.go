@@ -1,24 +1,75 @@
 package libgm
 
 import (
+	"context"
+	"errors"
+	"iter"
+
+	"google.golang.org/protobuf/proto"
+
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 )
 
-func (c *Client) ListConversations(count int, folder gmproto.ListConversationsRequest_Folder) (*gmproto.ListConversationsResponse, error) {
+// ErrUpdateConversationFailed is returned when the phone responds to a conversation update with success=false.
+var ErrUpdateConversationFailed = errors.New("phone reported failure updating conversation")
+
+func (c *Client) ListConversations(ctx context.Context, count int, folder gmproto.ListConversationsRequest_Folder) (*gmproto.ListConversationsResponse, error) {
+	return c.ListConversationsPage(ctx, count, folder, nil)
+}
+
+// ListConversationsPage fetches a page of conversations in the given folder, newest first.
+// To get the next page, pass the cursor from the previous response. A nil cursor fetches the first page.
+func (c *Client) ListConversationsPage(ctx context.Context, count int, folder gmproto.ListConversationsRequest_Folder, cursor *gmproto.Cursor) (*gmproto.ListConversationsResponse, error) {
 	msgType := gmproto.MessageType_BUGLE_MESSAGE
 	if !c.conversationsFetchedOnce {
 		msgType = gmproto.MessageType_BUGLE_ANNOTATION
 		c.conversationsFetchedOnce = true
 	}
-	return typedResponse[*gmproto.ListConversationsResponse](c.sessionHandler.sendMessageWithParams(SendMessageParams{
+	return typedResponse[*gmproto.ListConversationsResponse](c.sessionHandler.sendMessageWithParams(ctx, SendMessageParams{
 		Action:      gmproto.ActionType_LIST_CONVERSATIONS,
-		Data:        &gmproto.ListConversationsRequest{Count: int64(count), Folder: folder},
+		Data:        &gmproto.ListConversationsRequest{Count: int64(count), Folder: folder, Cursor: cursor},
 		MessageType: msgType,
 	}))
 }
 
-func (c *Client) DeleteConversation(conversationID, phone string) error {
-	_, err := c.UpdateConversation(&gmproto.UpdateConversationRequest{
+// PaginateConversations returns an iterator over all pages of conversations in the given folder,
+// starting after the given cursor (or from the newest conversation if the cursor is nil).
+//
+// The cursor of each yielded page can be stored to resume the pagination later.
+// The iterator stops after the last page or after yielding an error.
+func (c *Client) PaginateConversations(ctx context.Context, folder gmproto.ListConversationsRequest_Folder, pageSize int, cursor *gmproto.Cursor) iter.Seq2[*gmproto.ListConversationsResponse, error] {
+	return paginate(cursor, func(cursor *gmproto.Cursor) (*gmproto.ListConversationsResponse, int, error) {
+		resp, err := c.ListConversationsPage(ctx, pageSize, folder, cursor)
+		return resp, len(resp.GetConversations()), err
+	})
+}
+
+// paginate returns an iterator that calls fetch with the cursor of the previous page, starting from the given cursor.
+// fetch returns the page along with the number of items in it. The iterator stops after yielding an error,
+// or when a page is empty, has no cursor, or has the same cursor that was sent, so a phone that ignores
+// the cursor can't make it loop over the same page forever.
+func paginate[T interface{ GetCursor() *gmproto.Cursor }](cursor *gmproto.Cursor, fetch func(cursor *gmproto.Cursor) (T, int, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			resp, count, err := fetch(cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			} else if !yield(resp, nil) {
+				return
+			}
+			next := resp.GetCursor()
+			if next == nil || count == 0 || proto.Equal(next, cursor) {
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+func (c *Client) DeleteConversation(ctx context.Context, conversationID, phone string) error {
+	_, err := c.UpdateConversation(ctx, &gmproto.UpdateConversationRequest{
 		Action:         gmproto.ConversationActionStatus_DELETE,
 		ConversationID: conversationID,
 		Data: &gmproto.UpdateConversationRequest_DeleteData{
@@ -31,134 +82,291 @@ func (c *Client) DeleteConversation(conversationID, phone string) error {
 	return err
 }
 
-func (c *Client) ListContacts() (*gmproto.ListContactsResponse, error) {
+// SetConversationMuted mutes or unmutes notifications for a conversation on the phone.
+func (c *Client) SetConversationMuted(ctx context.Context, conversationID string, muted bool) error {
+	muteStatus := gmproto.ConversationMuteStatus_UNMUTE
+	if muted {
+		muteStatus = gmproto.ConversationMuteStatus_MUTE
+	}
+	resp, err := c.UpdateConversation(ctx, &gmproto.UpdateConversationRequest{
+		ConversationID: conversationID,
+		Data: &gmproto.UpdateConversationRequest_UpdateData{
+			UpdateData: &gmproto.UpdateConversationData{
+				ConversationID: conversationID,
+				Data:           &gmproto.UpdateConversationData_Mute{Mute: muteStatus},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	} else if !resp.GetSuccess() {
+		return ErrUpdateConversationFailed
+	}
+	return nil
+}
+
+// SetConversationStatus moves a conversation to another folder on the phone, e.g. to archive or unarchive it.
+func (c *Client) SetConversationStatus(ctx context.Context, conversationID string, status gmproto.ConversationStatus) error {
+	resp, err := c.UpdateConversation(ctx, &gmproto.UpdateConversationRequest{
+		ConversationID: conversationID,
+		Data: &gmproto.UpdateConversationRequest_UpdateData{
+			UpdateData: &gmproto.UpdateConversationData{
+				ConversationID: conversationID,
+				Data:           &gmproto.UpdateConversationData_Status{Status: status},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	} else if !resp.GetSuccess() {
+		return ErrUpdateConversationFailed
+	}
+	return nil
+}
+
+// BlockConversation blocks the other participant of a conversation and moves it to the blocked folder.
+func (c *Client) BlockConversation(ctx context.Context, conversationID string) error {
+	return c.updateConversationAction(ctx, conversationID, gmproto.ConversationActionStatus_BLOCK)
+}
+
+// ReportSpam blocks the other participant of a conversation like BlockConversation, and also reports the conversation as spam.
+func (c *Client) ReportSpam(ctx context.Context, conversationID string) error {
+	return c.updateConversationAction(ctx, conversationID, gmproto.ConversationActionStatus_BLOCK_AND_REPORT)
+}
+
+// UnblockConversation unblocks the other participant of a conversation and moves it back to the inbox.
+func (c *Client) UnblockConversation(ctx context.Context, conversationID string) error {
+	return c.updateConversationAction(ctx, conversationID, gmproto.ConversationActionStatus_UNBLOCK)
+}
+
+func (c *Client) updateConversationAction(ctx context.Context, conversationID string, action gmproto.ConversationActionStatus) error {
+	resp, err := c.UpdateConversation(ctx, &gmproto.UpdateConversationRequest{
+		Action:         action,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		return err
+	} else if !resp.GetSuccess() {
+		return ErrUpdateConversationFailed
+	}
+	return nil
+}
+
+// DefaultContactPageSize is the number of contacts the web app requests at once.
+const DefaultContactPageSize = 350
+
+// ListContacts fetches the first page of contacts in the phone's address book.
+func (c *Client) ListContacts(ctx context.Context) (*gmproto.ListContactsResponse, error) {
+	return c.ListContactsPage(ctx, DefaultContactPageSize, nil)
+}
+
+// ListContactsPage fetches a page of contacts. To get the next page, pass the cursor from the previous response.
+func (c *Client) ListContactsPage(ctx context.Context, count int, cursor *gmproto.Cursor) (*gmproto.ListContactsResponse, error) {
 	payload := &gmproto.ListContactsRequest{
-		I1: 1,
-		I2: 350,
-		I3: 50,
+		I1:     1,
+		Count:  int32(count),
+		I3:     50,
+		Cursor: cursor,
 	}
 	actionType := gmproto.ActionType_LIST_CONTACTS
-	return typedResponse[*gmproto.ListContactsResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.ListContactsResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
+}
+
+// PaginateContacts returns an iterator over all pages of contacts, starting after the given cursor.
+// The iterator stops after the last page or after yielding an error.
+func (c *Client) PaginateContacts(ctx context.Context, pageSize int, cursor *gmproto.Cursor) iter.Seq2[*gmproto.ListContactsResponse, error] {
+	return paginate(cursor, func(cursor *gmproto.Cursor) (*gmproto.ListContactsResponse, int, error) {
+		resp, err := c.ListContactsPage(ctx, pageSize, cursor)
+		if err == nil && resp.GetCursor() == nil && len(resp.GetContacts()) >= pageSize {
+			// The cursor fields haven't been verified against a real phone, so this may mean the phone ignored them
+			c.Logger.Warn().
+				Int("page_size", pageSize).
+				Msg("Got a full page of contacts without a cursor, contact list may be incomplete")
+		}
+		return resp, len(resp.GetContacts()), err
+	})
+}
+
+// ListAllContacts fetches the entire address book of the phone.
+func (c *Client) ListAllContacts(ctx context.Context) ([]*gmproto.Contact, error) {
+	var contacts []*gmproto.Contact
+	for page, err := range c.PaginateContacts(ctx, DefaultContactPageSize, nil) {
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, page.GetContacts()...)
+	}
+	return contacts, nil
 }
 
-func (c *Client) ListTopContacts() (*gmproto.ListTopContactsResponse, error) {
+func (c *Client) ListTopContacts(ctx context.Context) (*gmproto.ListTopContactsResponse, error) {
 	payload := &gmproto.ListTopContactsRequest{
 		Count: 8,
 	}
 	actionType := gmproto.ActionType_LIST_TOP_CONTACTS
-	return typedResponse[*gmproto.ListTopContactsResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.ListTopContactsResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) GetOrCreateConversation(req *gmproto.GetOrCreateConversationRequest) (*gmproto.GetOrCreateConversationResponse, error) {
+func (c *Client) GetOrCreateConversation(ctx context.Context, req *gmproto.GetOrCreateConversationRequest) (*gmproto.GetOrCreateConversationResponse, error) {
 	actionType := gmproto.ActionType_GET_OR_CREATE_CONVERSATION
-	return typedResponse[*gmproto.GetOrCreateConversationResponse](c.sessionHandler.sendMessage(actionType, req))
+	return typedResponse[*gmproto.GetOrCreateConversationResponse](c.sessionHandler.sendMessage(ctx, actionType, req))
+}
+
+// LeaveRCSGroup leaves an RCS group chat. The conversation stays on the phone, but becomes read-only.
+//
+// Experimental: the payload format hasn't been verified against a real phone.
+func (c *Client) LeaveRCSGroup(ctx context.Context, conversationID string) (*gmproto.LeaveRCSGroupResponse, error) {
+	payload := &gmproto.LeaveRCSGroupRequest{ConversationID: conversationID}
+	actionType := gmproto.ActionType_LEAVE_RCS_GROUP
+	return typedResponse[*gmproto.LeaveRCSGroupResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
+}
+
+// AddParticipantsToRCSGroup adds the given phone numbers to an RCS group chat.
+//
+// Experimental: the payload format hasn't been verified against a real phone.
+func (c *Client) AddParticipantsToRCSGroup(ctx context.Context, conversationID string, numbers ...*gmproto.ContactNumber) (*gmproto.AddParticipantToRCSGroupResponse, error) {
+	payload := &gmproto.AddParticipantToRCSGroupRequest{ConversationID: conversationID, Numbers: numbers}
+	actionType := gmproto.ActionType_ADD_PARTICIPANT_TO_RCS_GROUP
+	return typedResponse[*gmproto.AddParticipantToRCSGroupResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
+}
+
+// GetContactRCSGroupStatus checks whether the given phone number can be added to RCS group chats.
+//
+// Experimental: the payload format hasn't been verified against a real phone.
+func (c *Client) GetContactRCSGroupStatus(ctx context.Context, number *gmproto.ContactNumber) (*gmproto.GetContactRCSGroupStatusResponse, error) {
+	payload := &gmproto.GetContactRCSGroupStatusRequest{Number: number}
+	actionType := gmproto.ActionType_GET_CONTACT_RCS_GROUP_STATUS
+	return typedResponse[*gmproto.GetContactRCSGroupStatusResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) GetConversationType(conversationID string) (*gmproto.GetConversationTypeResponse, error) {
+func (c *Client) GetConversationType(ctx context.Context, conversationID string) (*gmproto.GetConversationTypeResponse, error) {
 	payload := &gmproto.GetConversationTypeRequest{ConversationID: conversationID}
 	actionType := gmproto.ActionType_GET_CONVERSATION_TYPE
-	return typedResponse[*gmproto.GetConversationTypeResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.GetConversationTypeResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) GetConversation(conversationID string) (*gmproto.Conversation, error) {
+func (c *Client) GetConversation(ctx context.Context, conversationID string) (*gmproto.Conversation, error) {
 	payload := &gmproto.GetConversationRequest{ConversationID: conversationID}
 	actionType := gmproto.ActionType_GET_CONVERSATION
-	resp, err := typedResponse[*gmproto.GetConversationResponse](c.sessionHandler.sendMessage(actionType, payload))
+	resp, err := typedResponse[*gmproto.GetConversationResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 	if err != nil {
 		return nil, err
 	}
 	return resp.GetConversation(), nil
 }
 
-func (c *Client) FetchMessages(conversationID string, count int64, cursor *gmproto.Cursor) (*gmproto.ListMessagesResponse, error) {
+func (c *Client) FetchMessages(ctx context.Context, conversationID string, count int64, cursor *gmproto.Cursor) (*gmproto.ListMessagesResponse, error) {
 	payload := &gmproto.ListMessagesRequest{ConversationID: conversationID, Count: count, Cursor: cursor}
 	actionType := gmproto.ActionType_LIST_MESSAGES
-	return typedResponse[*gmproto.ListMessagesResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.ListMessagesResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) SendMessage(payload *gmproto.SendMessageRequest) (*gmproto.SendMessageResponse, error) {
+func (c *Client) SendMessage(ctx context.Context, payload *gmproto.SendMessageRequest) (*gmproto.SendMessageResponse, error) {
 	actionType := gmproto.ActionType_SEND_MESSAGE
-	return typedResponse[*gmproto.SendMessageResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.SendMessageResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) GetParticipantThumbnail(participantIDs ...string) (*gmproto.GetThumbnailResponse, error) {
+func (c *Client) GetParticipantThumbnail(ctx context.Context, participantIDs ...string) (*gmproto.GetThumbnailResponse, error) {
 	payload := &gmproto.GetThumbnailRequest{Identifiers: participantIDs}
 	actionType := gmproto.ActionType_GET_PARTICIPANTS_THUMBNAIL
-	return typedResponse[*gmproto.GetThumbnailResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.GetThumbnailResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) GetContactThumbnail(contactIDs ...string) (*gmproto.GetThumbnailResponse, error) {
+func (c *Client) GetContactThumbnail(ctx context.Context, contactIDs ...string) (*gmproto.GetThumbnailResponse, error) {
 	payload := &gmproto.GetThumbnailRequest{Identifiers: contactIDs}
 	actionType := gmproto.ActionType_GET_CONTACTS_THUMBNAIL
-	return typedResponse[*gmproto.GetThumbnailResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.GetThumbnailResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) UpdateConversation(payload *gmproto.UpdateConversationRequest) (*gmproto.UpdateConversationResponse, error) {
+func (c *Client) UpdateConversation(ctx context.Context, payload *gmproto.UpdateConversationRequest) (*gmproto.UpdateConversationResponse, error) {
 	actionType := gmproto.ActionType_UPDATE_CONVERSATION
-	return typedResponse[*gmproto.UpdateConversationResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.UpdateConversationResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) SendReaction(payload *gmproto.SendReactionRequest) (*gmproto.SendReactionResponse, error) {
+func (c *Client) SendReaction(ctx context.Context, payload *gmproto.SendReactionRequest) (*gmproto.SendReactionResponse, error) {
 	actionType := gmproto.ActionType_SEND_REACTION
-	return typedResponse[*gmproto.SendReactionResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.SendReactionResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
 
-func (c *Client) DeleteMessage(messageID string) (*gmproto.DeleteMessageResponse, error) {
+func (c *Client) DeleteMessage(ctx context.Context, messageID string) (*gmproto.DeleteMessageResponse, error) {
 	payload := &gmproto.DeleteMessageRequest{MessageID: messageID}
 	actionType := gmproto.ActionType_DELETE_MESSAGE
 
-	return typedResponse[*gmproto.DeleteMessageResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.DeleteMessageResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
+}
+
+// ResendMessage asks the phone to retry sending a failed outgoing message. The message keeps its ID,
+// and the result is reported through the usual message update events.
+func (c *Client) ResendMessage(ctx context.Context, messageID string) error {
+	payload := &gmproto.ResendMessageRequest{MessageID: messageID}
+	actionType := gmproto.ActionType_RESEND_MESSAGE
+
+	_, err := c.sessionHandler.sendMessage(ctx, actionType, payload)
+	return err
+}
+
+// DownloadMessage asks the phone to download an MMS that is waiting for a manual download
+// (status INCOMING_YET_TO_MANUAL_DOWNLOAD). The downloaded content is sent as a normal message update.
+// Experimental: the payload format hasn't been verified against a real phone.
+func (c *Client) DownloadMessage(ctx context.Context, messageID string) error {
+	payload := &gmproto.DownloadMessageRequest{MessageID: messageID}
+	actionType := gmproto.ActionType_DOWNLOAD_MESSAGE
+
+	_, err := c.sessionHandler.sendMessage(ctx, actionType, payload)
+	return err
 }
 
-func (c *Client) MarkRead(conversationID, messageID string) error {
+func (c *Client) MarkRead(ctx context.Context, conversationID, messageID string) error {
 	payload := &gmproto.MessageReadRequest{ConversationID: conversationID, MessageID: messageID}
 	actionType := gmproto.ActionType_MESSAGE_READ
 
-	_, err := c.sessionHandler.sendMessage(actionType, payload)
+	_, err := c.sessionHandler.sendMessage(ctx, actionType, payload)
 	return err
 }
 
-func (c *Client) SetTyping(convID string, simPayload *gmproto.SIMPayload) error {
-	return c.sessionHandler.sendMessageNoResponse(SendMessageParams{
+// SetTyping starts or stops showing a typing notification to the other participants of a conversation.
+func (c *Client) SetTyping(ctx context.Context, convID string, typing bool, simPayload *gmproto.SIMPayload) error {
+	return c.sessionHandler.sendMessageNoResponse(ctx, SendMessageParams{
 		Action: gmproto.ActionType_TYPING_UPDATES,
 		Data: &gmproto.TypingUpdateRequest{
-			Data:       &gmproto.TypingUpdateRequest_Data{ConversationID: convID, Typing: true},
+			Data:       &gmproto.TypingUpdateRequest_Data{ConversationID: convID, Typing: typing},
 			SIMPayload: simPayload,
 		},
 	})
 }
 
-func (c *Client) UpdateSettings(payload *gmproto.SettingsUpdateRequest) error {
-	return c.sessionHandler.sendMessageNoResponse(SendMessageParams{
+func (c *Client) UpdateSettings(ctx context.Context, payload *gmproto.SettingsUpdateRequest) error {
+	return c.sessionHandler.sendMessageNoResponse(ctx, SendMessageParams{
 		Action: gmproto.ActionType_SETTINGS_UPDATE,
 		Data:   payload,
 	})
 }
 
-func (c *Client) SetActiveSession() error {
+func (c *Client) SetActiveSession(ctx context.Context) error {
 	c.sessionHandler.ResetSessionID()
-	return c.sessionHandler.sendMessageNoResponse(SendMessageParams{
+	return c.sessionHandler.sendMessageNoResponse(ctx, SendMessageParams{
 		Action:    gmproto.ActionType_GET_UPDATES,
 		OmitTTL:   true,
 		RequestID: c.sessionHandler.sessionID,
 	})
 }
 
-func (c *Client) IsBugleDefault() (*gmproto.IsBugleDefaultResponse, error) {
+func (c *Client) IsBugleDefault(ctx context.Context) (*gmproto.IsBugleDefaultResponse, error) {
 	actionType := gmproto.ActionType_IS_BUGLE_DEFAULT
-	return typedResponse[*gmproto.IsBugleDefaultResponse](c.sessionHandler.sendMessage(actionType, nil))
+	return typedResponse[*gmproto.IsBugleDefaultResponse](c.sessionHandler.sendMessage(ctx, actionType, nil))
 }
 
-func (c *Client) NotifyDittoActivity() (<-chan *IncomingRPCMessage, error) {
-	return c.sessionHandler.sendAsyncMessage(SendMessageParams{
+func (c *Client) NotifyDittoActivity(ctx context.Context) (<-chan *IncomingRPCMessage, error) {
+	_, ch, err := c.sessionHandler.sendAsyncMessage(ctx, SendMessageParams{
 		Action: gmproto.ActionType_NOTIFY_DITTO_ACTIVITY,
 		Data:   &gmproto.NotifyDittoActivityRequest{Success: true},
 	})
+	return ch, err
 }
 
-func (c *Client) GetFullSizeImage(messageID, actionMessageID string) (*gmproto.GetFullSizeImageResponse, error) {
+func (c *Client) GetFullSizeImage(ctx context.Context, messageID, actionMessageID string) (*gmproto.GetFullSizeImageResponse, error) {
 	payload := &gmproto.GetFullSizeImageRequest{MessageID: messageID, ActionMessageID: actionMessageID}
 	actionType := gmproto.ActionType_GET_FULL_SIZE_IMAGE
 
-	return typedResponse[*gmproto.GetFullSizeImageResponse](c.sessionHandler.sendMessage(actionType, payload))
+	return typedResponse[*gmproto.GetFullSizeImageResponse](c.sessionHandler.sendMessage(ctx, actionType, payload))
 }
@@ -0,0 +1,67 @@
+package libgm
+
+import (
+	"time"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+// LongPollReconnectReason describes why a long-polling connection had to be reopened.
+type LongPollReconnectReason string
+
+const (
+	// The long-polling request couldn't be sent or the response headers never arrived.
+	LongPollReconnectRequestError LongPollReconnectReason = "request_error"
+	// The server responded to the long-polling request with a non-auth HTTP error.
+	LongPollReconnectHTTPError LongPollReconnectReason = "http_error"
+	// The server ended the stream cleanly, which normally happens every few minutes.
+	LongPollReconnectStreamEnded LongPollReconnectReason = "stream_ended"
+	// Reading the stream failed before the server ended it.
+	LongPollReconnectReadError LongPollReconnectReason = "read_error"
+)
+
+// Metrics receives measurements about the protocol layer. Use [Client.SetMetrics] to enable it.
+//
+// Methods are called synchronously from the goroutines doing the work, so they must be cheap
+// and safe for concurrent use. Embed [NoopMetrics] to only implement some of the methods.
+type Metrics interface {
+	// RPCResponse is called when the phone responds to a request, with the time since it was sent.
+	RPCResponse(action gmproto.ActionType, duration time.Duration)
+	// RPCTimeout is called when a request is abandoned because its context ended before the phone responded.
+	RPCTimeout(action gmproto.ActionType)
+	// LongPollReconnect is called when the long-polling connection is reopened.
+	LongPollReconnect(reason LongPollReconnectReason)
+	// PingResponse is called when the phone responds to a ditto ping, with the round-trip time.
+	PingResponse(rtt time.Duration)
+	// PingFailure is called when a ditto ping can't be sent (timeout=false) or doesn't get a response in time (timeout=true).
+	PingFailure(timeout bool)
+	// AckBatch is called after trying to send an ack request with the given number of message IDs.
+	AckBatch(size int, err error)
+	// DedupHit is called when an update is dropped because an identical one was already handled.
+	DedupHit()
+	// TokenRefresh is called after trying to refresh the tachyon auth token.
+	TokenRefresh(err error)
+}
+
+// NoopMetrics is a [Metrics] implementation that discards everything.
+type NoopMetrics struct{}
+
+var _ Metrics = NoopMetrics{}
+
+func (NoopMetrics) RPCResponse(gmproto.ActionType, time.Duration) {}
+func (NoopMetrics) RPCTimeout(gmproto.ActionType)                 {}
+func (NoopMetrics) LongPollReconnect(LongPollReconnectReason)     {}
+func (NoopMetrics) PingResponse(time.Duration)                    {}
+func (NoopMetrics) PingFailure(bool)                              {}
+func (NoopMetrics) AckBatch(int, error)                           {}
+func (NoopMetrics) DedupHit()                                     {}
+func (NoopMetrics) TokenRefresh(error)                            {}
+
+// SetMetrics sets the receiver for protocol metrics. Passing nil disables metrics.
+// It must be called before connecting.
+func (c *Client) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = NoopMetrics{}
+	}
+	c.metrics = metrics
+}
@@ -1,6 +1,7 @@
 package libgm
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
@@ -14,8 +15,8 @@ import (
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/util"
 )
 
-func (c *Client) StartLogin() (string, error) {
-	registered, err := c.RegisterPhoneRelay()
+func (c *Client) StartLogin(ctx context.Context) (string, error) {
+	registered, err := c.RegisterPhoneRelay(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -47,6 +48,7 @@ func (c *Client) handlePairingEvent(msg *IncomingRPCMessage) {
 	case *gmproto.RPCPairData_Paired:
 		c.completePairing(evt.Paired)
 	case *gmproto.RPCPairData_Revoked:
+		c.setLoggedOut(events.ErrPairingRevoked)
 		c.triggerEvent(evt.Revoked)
 	default:
 		c.Logger.Debug().Any("evt", evt).Msg("Unknown pair event type")
@@ -76,7 +78,7 @@ func (c *Client) completePairing(data *gmproto.PairedData) {
 	}
 }
 
-func (c *Client) RegisterPhoneRelay() (*gmproto.RegisterPhoneRelayResponse, error) {
+func (c *Client) RegisterPhoneRelay(ctx context.Context) (*gmproto.RegisterPhoneRelayResponse, error) {
 	key, err := x509.MarshalPKIXPublicKey(c.AuthData.RefreshKey.GetPublicKey())
 	if err != nil {
 		return nil, err
@@ -99,11 +101,11 @@ func (c *Client) RegisterPhoneRelay() (*gmproto.RegisterPhoneRelayResponse, erro
 		},
 	}
 	return typedHTTPResponse[*gmproto.RegisterPhoneRelayResponse](
-		c.makeProtobufHTTPRequest(util.RegisterPhoneRelayURL, payload, ContentTypeProtobuf),
+		c.makeProtobufHTTPRequest(ctx, c.endpoints.RegisterPhoneRelayURL(), payload, ContentTypeProtobuf),
 	)
 }
 
-func (c *Client) RefreshPhoneRelay() (string, error) {
+func (c *Client) RefreshPhoneRelay(ctx context.Context) (string, error) {
 	payload := &gmproto.AuthenticationContainer{
 		AuthMessage: &gmproto.AuthMessage{
 			RequestID:        uuid.NewString(),
@@ -113,7 +115,7 @@ func (c *Client) RefreshPhoneRelay() (string, error) {
 		},
 	}
 	res, err := typedHTTPResponse[*gmproto.RefreshPhoneRelayResponse](
-		c.makeProtobufHTTPRequest(util.RefreshPhoneRelayURL, payload, ContentTypeProtobuf),
+		c.makeProtobufHTTPRequest(ctx, c.endpoints.RefreshPhoneRelayURL(), payload, ContentTypeProtobuf),
 	)
 	if err != nil {
 		return "", err
@@ -125,7 +127,7 @@ func (c *Client) RefreshPhoneRelay() (string, error) {
 	return qr, nil
 }
 
-func (c *Client) GetWebEncryptionKey() (*gmproto.WebEncryptionKeyResponse, error) {
+func (c *Client) GetWebEncryptionKey(ctx context.Context) (*gmproto.WebEncryptionKeyResponse, error) {
 	payload := &gmproto.AuthenticationContainer{
 		AuthMessage: &gmproto.AuthMessage{
 			RequestID:        uuid.NewString(),
@@ -134,11 +136,11 @@ func (c *Client) GetWebEncryptionKey() (*gmproto.WebEncryptionKeyResponse, error
 		},
 	}
 	return typedHTTPResponse[*gmproto.WebEncryptionKeyResponse](
-		c.makeProtobufHTTPRequest(util.GetWebEncryptionKeyURL, payload, ContentTypeProtobuf),
+		c.makeProtobufHTTPRequest(ctx, c.endpoints.GetWebEncryptionKeyURL(), payload, ContentTypeProtobuf),
 	)
 }
 
-func (c *Client) UnpairBugle() (*gmproto.RevokeRelayPairingResponse, error) {
+func (c *Client) UnpairBugle(ctx context.Context) (*gmproto.RevokeRelayPairingResponse, error) {
 	if c.AuthData.TachyonAuthToken == nil || c.AuthData.Browser == nil {
 		return nil, nil
 	}
@@ -151,15 +153,15 @@ func (c *Client) UnpairBugle() (*gmproto.RevokeRelayPairingResponse, error) {
 		Browser: c.AuthData.Browser,
 	}
 	return typedHTTPResponse[*gmproto.RevokeRelayPairingResponse](
-		c.makeProtobufHTTPRequest(util.RevokeRelayPairingURL, payload, ContentTypeProtobuf),
+		c.makeProtobufHTTPRequest(ctx, c.endpoints.RevokeRelayPairingURL(), payload, ContentTypeProtobuf),
 	)
 }
 
-func (c *Client) Unpair() (err error) {
+func (c *Client) Unpair(ctx context.Context) (err error) {
 	if c.AuthData.HasCookies() {
-		err = c.UnpairGaia()
+		err = c.UnpairGaia(ctx)
 	} else {
-		_, err = c.UnpairBugle()
+		_, err = c.UnpairBugle(ctx)
 	}
 	return
 }
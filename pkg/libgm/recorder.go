@@ -0,0 +1,210 @@
+package libgm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
+)
+
+type CaptureDirection string
+
+const (
+	CaptureOutgoing CaptureDirection = "outgoing"
+	CaptureIncoming CaptureDirection = "incoming"
+)
+
+// CaptureEntry is a single line in a capture file written by a [Recorder].
+type CaptureEntry struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Direction CaptureDirection `json:"direction"`
+	// RequestID is the ID of the request for outgoing messages and responses.
+	// For updates from the phone, it's the session ID of the GET_UPDATES request.
+	RequestID string `json:"request_id,omitempty"`
+	// MessageID is the server-assigned ID of incoming messages, which is also used for acking them.
+	MessageID  string             `json:"message_id,omitempty"`
+	Action     gmproto.ActionType `json:"action"`
+	BugleRoute gmproto.BugleRoute `json:"bugle_route,omitempty"`
+	// Raw is the serialized OutgoingRPCData for outgoing entries and IncomingRPCMessage for incoming ones.
+	Raw []byte `json:"raw"`
+	// Decrypted is the plaintext protobuf payload, if there is one.
+	Decrypted []byte `json:"decrypted,omitempty"`
+	// Error is set if decoding or decrypting an incoming message failed.
+	Error string `json:"error,omitempty"`
+}
+
+// RequestTypes maps action types to the protobuf message type of their request payloads.
+var RequestTypes = map[gmproto.ActionType]proto.Message{
+	gmproto.ActionType_LIST_CONVERSATIONS:           &gmproto.ListConversationsRequest{},
+	gmproto.ActionType_NOTIFY_DITTO_ACTIVITY:        &gmproto.NotifyDittoActivityRequest{},
+	gmproto.ActionType_GET_CONVERSATION_TYPE:        &gmproto.GetConversationTypeRequest{},
+	gmproto.ActionType_GET_CONVERSATION:             &gmproto.GetConversationRequest{},
+	gmproto.ActionType_LIST_MESSAGES:                &gmproto.ListMessagesRequest{},
+	gmproto.ActionType_SEND_MESSAGE:                 &gmproto.SendMessageRequest{},
+	gmproto.ActionType_SEND_REACTION:                &gmproto.SendReactionRequest{},
+	gmproto.ActionType_DELETE_MESSAGE:               &gmproto.DeleteMessageRequest{},
+	gmproto.ActionType_GET_PARTICIPANTS_THUMBNAIL:   &gmproto.GetThumbnailRequest{},
+	gmproto.ActionType_GET_CONTACTS_THUMBNAIL:       &gmproto.GetThumbnailRequest{},
+	gmproto.ActionType_LIST_CONTACTS:                &gmproto.ListContactsRequest{},
+	gmproto.ActionType_LIST_TOP_CONTACTS:            &gmproto.ListTopContactsRequest{},
+	gmproto.ActionType_GET_OR_CREATE_CONVERSATION:   &gmproto.GetOrCreateConversationRequest{},
+	gmproto.ActionType_UPDATE_CONVERSATION:          &gmproto.UpdateConversationRequest{},
+	gmproto.ActionType_RESEND_MESSAGE:               &gmproto.ResendMessageRequest{},
+	gmproto.ActionType_DOWNLOAD_MESSAGE:             &gmproto.DownloadMessageRequest{},
+	gmproto.ActionType_TYPING_UPDATES:               &gmproto.TypingUpdateRequest{},
+	gmproto.ActionType_GET_FULL_SIZE_IMAGE:          &gmproto.GetFullSizeImageRequest{},
+	gmproto.ActionType_SETTINGS_UPDATE:              &gmproto.SettingsUpdateRequest{},
+	gmproto.ActionType_LEAVE_RCS_GROUP:              &gmproto.LeaveRCSGroupRequest{},
+	gmproto.ActionType_ADD_PARTICIPANT_TO_RCS_GROUP: &gmproto.AddParticipantToRCSGroupRequest{},
+	gmproto.ActionType_GET_CONTACT_RCS_GROUP_STATUS: &gmproto.GetContactRCSGroupStatusRequest{},
+
+	gmproto.ActionType_CREATE_GAIA_PAIRING_CLIENT_INIT:     &gmproto.GaiaPairingRequestContainer{},
+	gmproto.ActionType_CREATE_GAIA_PAIRING_CLIENT_FINISHED: &gmproto.GaiaPairingRequestContainer{},
+}
+
+// DecodePayload parses the decrypted payload into the request or response type of the entry's action.
+// It returns nil if there's no payload or the type for the action isn't known.
+func (entry *CaptureEntry) DecodePayload() (proto.Message, error) {
+	if entry.Decrypted == nil {
+		return nil, nil
+	}
+	typeMap := responseType
+	if entry.Direction == CaptureOutgoing {
+		typeMap = RequestTypes
+	}
+	msgType, ok := typeMap[entry.Action]
+	if !ok {
+		return nil, nil
+	}
+	msg := msgType.ProtoReflect().New().Interface()
+	err := proto.Unmarshal(entry.Decrypted, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s payload: %w", entry.Action, err)
+	}
+	return msg, nil
+}
+
+// Recorder writes all RPC messages sent and received by a client into a JSONL capture file.
+//
+// Captures contain decrypted message content, so they should be handled as carefully as the messages themselves.
+type Recorder struct {
+	lock   sync.Mutex
+	w      io.Writer
+	enc    *json.Encoder
+	closed bool
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) Record(entry *CaptureEntry) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return nil
+	}
+	return r.enc.Encode(entry)
+}
+
+// Close stops recording and closes the underlying writer if it's an [io.Closer].
+// Entries recorded after closing are dropped.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if closer, ok := r.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// SetRecorder enables recording all RPC traffic into the given recorder. Passing nil disables recording.
+// It must be called before connecting.
+func (c *Client) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
+func (c *Client) recordCapture(entry *CaptureEntry) {
+	if c.recorder == nil {
+		return
+	}
+	entry.Timestamp = time.Now()
+	err := c.recorder.Record(entry)
+	if err != nil {
+		c.Logger.Err(err).Str("request_id", entry.RequestID).Msg("Failed to write capture entry")
+	}
+}
+
+// ReadCapture reads entries from a capture file written by a [Recorder].
+func ReadCapture(r io.Reader) iter.Seq2[*CaptureEntry, error] {
+	return func(yield func(*CaptureEntry, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 64*1024*1024)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var entry CaptureEntry
+			err := json.Unmarshal(scanner.Bytes(), &entry)
+			if err != nil {
+				err = fmt.Errorf("failed to parse line %d: %w", lineNum, err)
+			}
+			if !yield(&entry, err) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Replay dispatches events from a captured incoming message to the client's event handlers
+// as if it had been received from the server. Other entries are ignored.
+//
+// The decrypted payload from the capture is used, so the client doesn't need any keys.
+// Responses to requests and pairing events don't produce events.
+func (c *Client) Replay(entry *CaptureEntry) error {
+	if entry.Direction != CaptureIncoming {
+		return nil
+	}
+	var raw gmproto.IncomingRPCMessage
+	err := proto.Unmarshal(entry.Raw, &raw)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal raw message: %w", err)
+	} else if raw.GetBugleRoute() != gmproto.BugleRoute_DataEvent {
+		return nil
+	}
+	msg := &IncomingRPCMessage{
+		IncomingRPCMessage: &raw,
+		Message:            &gmproto.RPCMessageData{},
+		DecryptedData:      entry.Decrypted,
+	}
+	err = proto.Unmarshal(raw.GetMessageData(), msg.Message)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal data event: %w", err)
+	}
+	if msg.Message.EncryptedData != nil && msg.DecryptedData != nil {
+		responseStruct, ok := responseType[msg.Message.GetAction()]
+		if ok {
+			msg.DecryptedMessage = responseStruct.ProtoReflect().New().Interface()
+			err = proto.Unmarshal(msg.DecryptedData, msg.DecryptedMessage)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal decrypted data: %w", err)
+			}
+		}
+	}
+	c.handleUpdatesEvent(msg)
+	return nil
+}
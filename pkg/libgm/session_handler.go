@@ -1,6 +1,7 @@
 package libgm
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"sync"
@@ -8,9 +9,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
-	"golang.org/x/exp/slices"
 	"google.golang.org/protobuf/proto"
 
+	"go.mau.fi/mautrix-gmessages/pkg/libgm/events"
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/gmproto"
 	"go.mau.fi/mautrix-gmessages/pkg/libgm/util"
 )
@@ -18,12 +19,18 @@ import (
 type SessionHandler struct {
 	client *Client
 
-	responseWaiters     map[string]chan<- *IncomingRPCMessage
+	responseWaiters     map[string]*responseWaiter
 	responseWaitersLock sync.Mutex
 
-	ackMapLock sync.Mutex
-	ackMap     []string
-	ackTicker  *time.Ticker
+	ackMapLock    sync.Mutex
+	ackMap        []string
+	ackTicker     *time.Ticker
+	ackCancel     context.CancelFunc
+	ackDone       chan struct{}
+	ackFailures   int
+	ackRetryAfter time.Time
+	ackStore      AckStore
+	ackStoreRead  bool
 
 	sessionID string
 }
@@ -32,49 +39,43 @@ func (s *SessionHandler) ResetSessionID() {
 	s.sessionID = uuid.NewString()
 }
 
-func (s *SessionHandler) sendMessageNoResponse(params SendMessageParams) error {
+func (s *SessionHandler) sendMessageNoResponse(ctx context.Context, params SendMessageParams) error {
 	requestID, payload, err := s.buildMessage(params)
 	if err != nil {
 		return err
 	}
 
-	url := util.SendMessageURL
-	if s.client.AuthData.HasCookies() {
-		url = util.SendMessageURLGoogle
-	}
+	url := s.client.endpoints.SendMessageURL(s.client.AuthData.HasCookies())
 	s.client.Logger.Debug().
 		Stringer("message_action", params.Action).
 		Str("message_id", requestID).
 		Msg("Sending request to phone (not expecting response)")
 	_, err = typedHTTPResponse[*gmproto.OutgoingRPCResponse](
-		s.client.makeProtobufHTTPRequest(url, payload, ContentTypePBLite),
+		s.client.makeProtobufHTTPRequest(ctx, url, payload, ContentTypePBLite),
 	)
 	return err
 }
 
-func (s *SessionHandler) sendAsyncMessage(params SendMessageParams) (<-chan *IncomingRPCMessage, error) {
+func (s *SessionHandler) sendAsyncMessage(ctx context.Context, params SendMessageParams) (string, chan *IncomingRPCMessage, error) {
 	requestID, payload, err := s.buildMessage(params)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	ch := s.waitResponse(requestID)
-	url := util.SendMessageURL
-	if s.client.AuthData.HasCookies() {
-		url = util.SendMessageURLGoogle
-	}
+	ch := s.waitResponse(requestID, params.Action)
+	url := s.client.endpoints.SendMessageURL(s.client.AuthData.HasCookies())
 	s.client.Logger.Debug().
 		Stringer("message_action", params.Action).
 		Str("message_id", requestID).
 		Msg("Sending request to phone")
 	_, err = typedHTTPResponse[*gmproto.OutgoingRPCResponse](
-		s.client.makeProtobufHTTPRequest(url, payload, ContentTypePBLite),
+		s.client.makeProtobufHTTPRequest(ctx, url, payload, ContentTypePBLite),
 	)
 	if err != nil {
 		s.cancelResponse(requestID, ch)
-		return nil, err
+		return "", nil, err
 	}
-	return ch, nil
+	return requestID, ch, nil
 }
 
 func typedResponse[T proto.Message](resp *IncomingRPCMessage, err error) (casted T, retErr error) {
@@ -90,18 +91,27 @@ func typedResponse[T proto.Message](resp *IncomingRPCMessage, err error) (casted
 	return
 }
 
-func (s *SessionHandler) waitResponse(requestID string) chan *IncomingRPCMessage {
+type responseWaiter struct {
+	ch     chan<- *IncomingRPCMessage
+	action gmproto.ActionType
+	sentAt time.Time
+}
+
+func (s *SessionHandler) waitResponse(requestID string, action gmproto.ActionType) chan *IncomingRPCMessage {
 	ch := make(chan *IncomingRPCMessage, 1)
 	s.responseWaitersLock.Lock()
-	s.responseWaiters[requestID] = ch
+	s.responseWaiters[requestID] = &responseWaiter{ch: ch, action: action, sentAt: time.Now()}
 	s.responseWaitersLock.Unlock()
 	return ch
 }
 
 func (s *SessionHandler) cancelResponse(requestID string, ch chan *IncomingRPCMessage) {
 	s.responseWaitersLock.Lock()
-	close(ch)
-	delete(s.responseWaiters, requestID)
+	// The channel isn't closed, as receiveResponse may have already taken it out of the map.
+	// It's buffered, so a late response won't block anything.
+	if waiter, ok := s.responseWaiters[requestID]; ok && waiter.ch == ch {
+		delete(s.responseWaiters, requestID)
+	}
 	s.responseWaitersLock.Unlock()
 }
 
@@ -122,13 +132,14 @@ func (s *SessionHandler) receiveResponse(msg *IncomingRPCMessage) bool {
 	}
 	requestID := msg.Message.SessionID
 	s.responseWaitersLock.Lock()
-	ch, ok := s.responseWaiters[requestID]
+	waiter, ok := s.responseWaiters[requestID]
 	if !ok {
 		s.responseWaitersLock.Unlock()
 		return false
 	}
 	delete(s.responseWaiters, requestID)
 	s.responseWaitersLock.Unlock()
+	s.client.metrics.RPCResponse(waiter.action, time.Since(waiter.sentAt))
 	evt := s.client.Logger.Debug().
 		Str("request_message_id", requestID).
 		Str("response_message_id", msg.ResponseID)
@@ -144,32 +155,56 @@ func (s *SessionHandler) receiveResponse(msg *IncomingRPCMessage) bool {
 		}
 	}
 	evt.Msg("Received response")
-	ch <- msg
+	waiter.ch <- msg
 	return true
 }
 
-func (s *SessionHandler) sendMessageWithParams(params SendMessageParams) (*IncomingRPCMessage, error) {
-	ch, err := s.sendAsyncMessage(params)
+// DefaultRequestTimeout is the maximum time to wait for a response from the phone
+// if the context passed to a request method doesn't have a deadline.
+const DefaultRequestTimeout = 2 * time.Minute
+
+func (s *SessionHandler) sendMessageWithParams(ctx context.Context, params SendMessageParams) (*IncomingRPCMessage, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+	}
+	requestID, ch, err := s.sendAsyncMessage(ctx, params)
 	if err != nil {
 		return nil, err
 	}
 
-	select {
-	case resp := <-ch:
-		return resp, nil
-	case <-time.After(5 * time.Second):
-		// Notify the pinger in order to trigger an event that the phone isn't responding
+	slowTimer := time.NewTimer(5 * time.Second)
+	defer slowTimer.Stop()
+	for {
 		select {
-		case s.client.pingShortCircuit <- struct{}{}:
-		default:
+		case resp := <-ch:
+			return resp, nil
+		case <-slowTimer.C:
+			// Notify the pinger in order to trigger an event that the phone isn't responding
+			select {
+			case s.client.pingShortCircuit <- struct{}{}:
+			default:
+			}
+		case <-ctx.Done():
+			s.cancelResponse(requestID, ch)
+			s.client.metrics.RPCTimeout(params.Action)
+			s.client.Logger.Warn().
+				Stringer("message_action", params.Action).
+				Str("message_id", requestID).
+				Err(ctx.Err()).
+				Msg("Gave up waiting for response from phone")
+			return nil, events.RequestTimeoutError{
+				Action:    params.Action,
+				RequestID: requestID,
+				Err:       ctx.Err(),
+			}
 		}
 	}
-	// TODO hard timeout?
-	return <-ch, nil
 }
 
-func (s *SessionHandler) sendMessage(actionType gmproto.ActionType, encryptedData proto.Message) (*IncomingRPCMessage, error) {
-	return s.sendMessageWithParams(SendMessageParams{
+func (s *SessionHandler) sendMessage(ctx context.Context, actionType gmproto.ActionType, encryptedData proto.Message) (*IncomingRPCMessage, error) {
+	return s.sendMessageWithParams(ctx, SendMessageParams{
 		Action: actionType,
 		Data:   encryptedData,
 	})
@@ -224,9 +259,8 @@ func (s *SessionHandler) buildMessage(params SendMessageParams) (string, proto.M
 	} else if !params.OmitTTL {
 		message.TTL = s.client.AuthData.TachyonTTL
 	}
-	var encryptedData, unencryptedData []byte
+	var encryptedData, unencryptedData, serializedData []byte
 	if params.Data != nil {
-		var serializedData []byte
 		serializedData, err = proto.Marshal(params.Data)
 		if err != nil {
 			return "", nil, err
@@ -250,70 +284,14 @@ func (s *SessionHandler) buildMessage(params SendMessageParams) (string, proto.M
 	if err != nil {
 		return "", nil, err
 	}
+	s.client.recordCapture(&CaptureEntry{
+		Direction:  CaptureOutgoing,
+		RequestID:  requestID,
+		Action:     params.Action,
+		BugleRoute: message.Data.BugleRoute,
+		Raw:        message.Data.MessageData,
+		Decrypted:  serializedData,
+	})
 
 	return requestID, message, err
 }
-
-func (s *SessionHandler) queueMessageAck(messageID string) {
-	s.ackMapLock.Lock()
-	defer s.ackMapLock.Unlock()
-	if !slices.Contains(s.ackMap, messageID) {
-		s.ackMap = append(s.ackMap, messageID)
-		s.client.Logger.Trace().Any("message_id", messageID).Msg("Queued ack for message")
-	} else {
-		s.client.Logger.Trace().Any("message_id", messageID).Msg("Ack for message was already queued")
-	}
-}
-
-func (s *SessionHandler) startAckInterval() {
-	if s.ackTicker != nil {
-		return
-	}
-	ticker := time.NewTicker(5 * time.Second)
-	s.ackTicker = ticker
-	go func() {
-		for range ticker.C {
-			s.sendAckRequest()
-		}
-	}()
-}
-
-func (s *SessionHandler) sendAckRequest() {
-	s.ackMapLock.Lock()
-	dataToAck := s.ackMap
-	s.ackMap = nil
-	s.ackMapLock.Unlock()
-	if len(dataToAck) == 0 {
-		return
-	}
-	ackMessages := make([]*gmproto.AckMessageRequest_Message, len(dataToAck))
-	for i, reqID := range dataToAck {
-		ackMessages[i] = &gmproto.AckMessageRequest_Message{
-			RequestID: reqID,
-			Device:    s.client.AuthData.Browser,
-		}
-	}
-	payload := &gmproto.AckMessageRequest{
-		AuthData: &gmproto.AuthMessage{
-			RequestID:        uuid.NewString(),
-			TachyonAuthToken: s.client.AuthData.TachyonAuthToken,
-			Network:          s.client.AuthData.AuthNetwork(),
-			ConfigVersion:    util.ConfigMessage,
-		},
-		EmptyArr: &gmproto.EmptyArr{},
-		Acks:     ackMessages,
-	}
-	url := util.AckMessagesURL
-	if s.client.AuthData.HasCookies() {
-		url = util.AckMessagesURLGoogle
-	}
-	_, err := typedHTTPResponse[*gmproto.OutgoingRPCResponse](
-		s.client.makeProtobufHTTPRequest(url, payload, ContentTypePBLite),
-	)
-	if err != nil {
-		// TODO retry?
-		s.client.Logger.Err(err).Strs("message_ids", dataToAck).Msg("Failed to send acks")
-	} else {
-		s.client.Logger.Trace().Strs("message_ids", dataToAck).Msg("Sent acks")
-	}
-}
@@ -5,28 +5,100 @@ const MessagesBaseURL = "https://messages.google.com"
 const GoogleAuthenticationURL = MessagesBaseURL + "/web/authentication"
 const GoogleTimesourceURL = MessagesBaseURL + "/web/timesource"
 
-const instantMessagingBaseURL = "https://instantmessaging-pa.googleapis.com"
-const instantMessagingBaseURLGoogle = "https://instantmessaging-pa.clients6.google.com"
-
-const UploadMediaURL = instantMessagingBaseURL + "/upload"
-
-const pairingBaseURL = instantMessagingBaseURL + "/$rpc/google.internal.communications.instantmessaging.v1.Pairing"
-const RegisterPhoneRelayURL = pairingBaseURL + "/RegisterPhoneRelay"
-const RefreshPhoneRelayURL = pairingBaseURL + "/RefreshPhoneRelay"
-const GetWebEncryptionKeyURL = pairingBaseURL + "/GetWebEncryptionKey"
-const RevokeRelayPairingURL = pairingBaseURL + "/RevokeRelayPairing"
-
-const messagingBaseURL = instantMessagingBaseURL + "/$rpc/google.internal.communications.instantmessaging.v1.Messaging"
-const messagingBaseURLGoogle = instantMessagingBaseURLGoogle + "/$rpc/google.internal.communications.instantmessaging.v1.Messaging"
-const ReceiveMessagesURL = messagingBaseURL + "/ReceiveMessages"
-const SendMessageURL = messagingBaseURL + "/SendMessage"
-const AckMessagesURL = messagingBaseURL + "/AckMessages"
-const ReceiveMessagesURLGoogle = messagingBaseURLGoogle + "/ReceiveMessages"
-const SendMessageURLGoogle = messagingBaseURLGoogle + "/SendMessage"
-const AckMessagesURLGoogle = messagingBaseURLGoogle + "/AckMessages"
-
-const registrationBaseURL = instantMessagingBaseURLGoogle + "/$rpc/google.internal.communications.instantmessaging.v1.Registration"
-const SignInGaiaURL = registrationBaseURL + "/SignInGaia"
-const RegisterRefreshURL = registrationBaseURL + "/RegisterRefresh"
-
-const ConfigURL = "https://messages.google.com/web/config"
+const InstantMessagingBaseURL = "https://instantmessaging-pa.googleapis.com"
+const InstantMessagingBaseURLGoogle = "https://instantmessaging-pa.clients6.google.com"
+
+const pairingService = "/$rpc/google.internal.communications.instantmessaging.v1.Pairing"
+const messagingService = "/$rpc/google.internal.communications.instantmessaging.v1.Messaging"
+const registrationService = "/$rpc/google.internal.communications.instantmessaging.v1.Registration"
+
+// Endpoints contains the URLs of all the services that libgm talks to.
+//
+// The URLs of individual methods are derived from these by appending the method name,
+// so each field must not have a trailing slash.
+type Endpoints struct {
+	// Messaging service used by sessions paired with a QR code.
+	Messaging string
+	// Messaging service used by sessions paired with a Google account.
+	MessagingGoogle string
+	// Pairing service used for QR pairing and unpairing.
+	Pairing string
+	// Registration service used for Google account sign-in and refreshing tachyon tokens.
+	Registration string
+	// Media upload endpoint. Downloads also go through this URL.
+	UploadMedia string
+	// Messages for web config endpoint.
+	Config string
+}
+
+// NewEndpoints creates an endpoint set where every service lives under the given base URLs.
+//
+// instantMessaging is the base for the QR messaging, pairing and media endpoints,
+// instantMessagingGoogle is the base for Google account messaging and registration,
+// and messagesWeb is the base of the Messages for web site used for fetching config.
+func NewEndpoints(instantMessaging, instantMessagingGoogle, messagesWeb string) *Endpoints {
+	return &Endpoints{
+		Messaging:       instantMessaging + messagingService,
+		MessagingGoogle: instantMessagingGoogle + messagingService,
+		Pairing:         instantMessaging + pairingService,
+		Registration:    instantMessagingGoogle + registrationService,
+		UploadMedia:     instantMessaging + "/upload",
+		Config:          messagesWeb + "/web/config",
+	}
+}
+
+// DefaultEndpoints returns the endpoint set for the production Google servers.
+func DefaultEndpoints() *Endpoints {
+	return NewEndpoints(InstantMessagingBaseURL, InstantMessagingBaseURLGoogle, MessagesBaseURL)
+}
+
+func (e *Endpoints) messaging(google bool) string {
+	if google {
+		return e.MessagingGoogle
+	}
+	return e.Messaging
+}
+
+func (e *Endpoints) RegisterPhoneRelayURL() string {
+	return e.Pairing + "/RegisterPhoneRelay"
+}
+
+func (e *Endpoints) RefreshPhoneRelayURL() string {
+	return e.Pairing + "/RefreshPhoneRelay"
+}
+
+func (e *Endpoints) GetWebEncryptionKeyURL() string {
+	return e.Pairing + "/GetWebEncryptionKey"
+}
+
+func (e *Endpoints) RevokeRelayPairingURL() string {
+	return e.Pairing + "/RevokeRelayPairing"
+}
+
+func (e *Endpoints) ReceiveMessagesURL(google bool) string {
+	return e.messaging(google) + "/ReceiveMessages"
+}
+
+func (e *Endpoints) SendMessageURL(google bool) string {
+	return e.messaging(google) + "/SendMessage"
+}
+
+func (e *Endpoints) AckMessagesURL(google bool) string {
+	return e.messaging(google) + "/AckMessages"
+}
+
+func (e *Endpoints) SignInGaiaURL() string {
+	return e.Registration + "/SignInGaia"
+}
+
+func (e *Endpoints) RegisterRefreshURL() string {
+	return e.Registration + "/RegisterRefresh"
+}
+
+func (e *Endpoints) UploadMediaURL() string {
+	return e.UploadMedia
+}
+
+func (e *Endpoints) ConfigURL() string {
+	return e.Config
+}